@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// runCommand dispatches the CLI subcommands. Without arguments the binary runs the server.
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	switch args[0] {
+	case "rules":
+		return runRules(args[1:], stdout, stderr)
+	case "dashboards":
+		return runDashboards(args[1:], stdout, stderr)
+	case "help", "-h", "--help":
+		printUsage(stdout)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+		printUsage(stderr)
+		return 2
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, `Usage: %s [command]
+
+Without a command, the HTTP server is started.
+
+Commands:
+  rules generate    Generate a PrometheusRule manifest from the app's metrics and SLOs
+  dashboards generate
+                    Generate the Grafana dashboards from the app's metric and log catalogs
+  dashboards lint [files...]
+                    Check dashboards for metrics, labels, log attributes and datasource
+                    UIDs the app no longer emits or provisions
+  help              Show this help
+`, os.Args[0])
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/dashboards"
+)
+
+// defaultDashboardDirs are where the generated dashboards live in this repo
+var defaultDashboardDirs = []string{"grafana", "chart/dm-nkp-gitops-custom-app/files/grafana"}
+
+// runDashboards implements `dashboards generate` and `dashboards lint`
+func runDashboards(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: dashboards generate|lint [flags]")
+		return 2
+	}
+
+	opts := dashboards.DefaultOptions()
+	fs := flag.NewFlagSet("dashboards "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.ServiceName, "service", opts.ServiceName, "OTel service.name of the app")
+	fs.StringVar(&opts.PrometheusUID, "prometheus-uid", opts.PrometheusUID, "Prometheus datasource UID")
+	fs.StringVar(&opts.LokiUID, "loki-uid", opts.LokiUID, "Loki datasource UID")
+	fs.StringVar(&opts.TempoUID, "tempo-uid", opts.TempoUID, "Tempo datasource UID")
+
+	switch args[0] {
+	case "generate":
+		var dirs stringsFlag
+		fs.Var(&dirs, "o", "output directory (repeatable, defaults to grafana/ and the Helm chart)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if len(dirs) == 0 {
+			dirs = defaultDashboardDirs
+		}
+		return generateDashboards(opts, dirs, stdout, stderr)
+
+	case "lint":
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		files := fs.Args()
+		if len(files) == 0 {
+			for _, dir := range defaultDashboardDirs {
+				matches, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+				files = append(files, matches...)
+			}
+		}
+		return lintDashboards(opts, files, stdout, stderr)
+
+	default:
+		fmt.Fprintf(stderr, "unknown dashboards command %q\n", args[0])
+		return 2
+	}
+}
+
+func generateDashboards(opts dashboards.Options, dirs []string, stdout, stderr io.Writer) int {
+	generated := dashboards.Generate(opts)
+	names := make([]string, 0, len(generated))
+	for name := range generated {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, dir := range dirs {
+		for _, name := range names {
+			data, err := generated[name].Marshal()
+			if err != nil {
+				fmt.Fprintf(stderr, "error: %v\n", err)
+				return 1
+			}
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				fmt.Fprintf(stderr, "error: %v\n", err)
+				return 1
+			}
+			fmt.Fprintf(stdout, "wrote %s\n", path)
+		}
+	}
+	return 0
+}
+
+func lintDashboards(opts dashboards.Options, files []string, stdout, stderr io.Writer) int {
+	linter := dashboards.NewLinter(opts)
+	failed := false
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		issues, err := linter.Lint(file, data)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			failed = true
+			continue
+		}
+		for _, issue := range issues {
+			fmt.Fprintln(stdout, issue)
+			failed = true
+		}
+	}
+	if failed {
+		return 1
+	}
+	fmt.Fprintf(stdout, "%d dashboard(s) OK\n", len(files))
+	return 0
+}
+
+// stringsFlag collects a repeatable string flag
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return fmt.Sprint(*s) }
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
+)
+
+// watchLogLevelSignal toggles debug logging on every SIGUSR2 (kill -USR2 <pid>).
+// Debug logging reverts on its own after telemetry.DefaultLogLevelTTL.
+// The returned function stops watching.
+func watchLogLevelSignal(ctx context.Context) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR2)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				telemetry.ToggleDebugLogging(ctx)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -0,0 +1,8 @@
+package main
+
+import "context"
+
+// watchLogLevelSignal is a no-op: Windows has no SIGUSR2. Use /admin/loglevel instead.
+func watchLogLevelSignal(context.Context) func() {
+	return func() {}
+}
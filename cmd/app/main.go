@@ -7,15 +7,23 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/loadshed"
 	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/ratelimit"
 	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/server"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/slo"
 	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	ctx := context.Background()
 	port := getEnv("PORT", "8080")
 	serviceName := getEnv("OTEL_SERVICE_NAME", "dm-nkp-gitops-custom-app")
@@ -29,8 +37,7 @@ func main() {
 	if err := telemetry.InitializeLogger(); err != nil {
 		log.Printf("[WARN] Failed to initialize logger: %v (continuing with stdout logging)", err)
 	} else {
-		log.Printf("[INFO] Logger initialized successfully")
-		// Now we can use OTLP logger
+		// Now we can use OTLP logger; log.Printf lines are bridged into it as well
 		telemetry.LogInfo(ctx, fmt.Sprintf("OpenTelemetry telemetry initialization started for service: %s", serviceName))
 		telemetry.LogInfo(ctx, fmt.Sprintf("OTLP Endpoint: %s", getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "otel-collector:4317")))
 		telemetry.LogInfo(ctx, "Logger initialized successfully")
@@ -38,47 +45,121 @@ func main() {
 
 	// Initialize tracing
 	if err := telemetry.InitializeTracer(); err != nil {
-		log.Printf("[WARN] Failed to initialize tracer: %v (tracing disabled)", err)
-		log.Printf("[INFO] Traces will not be exported, but instrumentation will continue")
 		telemetry.LogWarn(ctx, fmt.Sprintf("Failed to initialize tracer: %v (tracing disabled)", err))
 		telemetry.LogInfo(ctx, "Traces will not be exported, but instrumentation will continue")
 	} else {
-		log.Printf("[INFO] Tracer initialized successfully")
 		telemetry.LogInfo(ctx, "Tracer initialized successfully")
 	}
 
 	// Initialize metrics (now returns error)
 	if err := metrics.Initialize(); err != nil {
-		log.Printf("[WARN] Failed to initialize metrics: %v", err)
-		log.Printf("[INFO] Metrics will not be exported, but instrumentation will continue")
-		log.Printf("[INFO] This is normal if OTel Collector is not available (e.g., in e2e tests)")
 		telemetry.LogWarn(ctx, fmt.Sprintf("Failed to initialize metrics: %v", err))
 		telemetry.LogInfo(ctx, "Metrics will not be exported, but instrumentation will continue")
 		telemetry.LogInfo(ctx, "This is normal if OTel Collector is not available (e.g., in e2e tests)")
 		// Don't fail - allow app to run without collector for testing
 	} else {
-		log.Printf("[INFO] Metrics initialized successfully")
 		telemetry.LogInfo(ctx, "Metrics initialized successfully")
 	}
 
-	// Create HTTP server
-	srv := server.New(port)
+	// Initialize SLO tracking (after metrics so the SLO gauges are exported too)
+	if err := slo.Initialize(); err != nil {
+		telemetry.LogWarn(ctx, fmt.Sprintf("Failed to initialize SLO tracking: %v", err))
+	} else {
+		telemetry.LogInfo(ctx, "SLO tracking initialized successfully")
+	}
+
+	// Create HTTP server, serving HTTPS when TLS_CERT_FILE is set
+	opts := []server.Option{server.WithPort(port)}
+	scheme := "http"
+	tlsConfig, err := server.TLSConfigFromEnv()
+	if err != nil {
+		log.Fatalf("[FATAL] Invalid TLS configuration: %v", err)
+	}
+	if tlsConfig != nil {
+		opts = append(opts, server.WithTLS(*tlsConfig))
+		scheme = "https"
+	}
+	// ADMIN_ADDR moves the probes, admin and debug routes to an internal listener
+	adminBase := fmt.Sprintf("%s://localhost:%s", scheme, port)
+	adminAddr := os.Getenv("ADMIN_ADDR")
+	if adminAddr != "" {
+		opts = append(opts, server.WithAdminAddr(adminAddr))
+		adminBase = "http://" + adminAddr
+	}
+	// DEBUG_TOKEN guards the profiling endpoints (and serves them on PORT without
+	// an admin listener)
+	debugToken, err := readDebugToken()
+	if err != nil {
+		log.Fatalf("[FATAL] Invalid debug token: %v", err)
+	}
+	if debugToken != "" {
+		opts = append(opts, server.WithDebugToken(debugToken))
+	}
+	// RATE_LIMIT_* set the initial rate limits; /admin/ratelimits changes them
+	rateLimit, err := ratelimit.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("[FATAL] Invalid rate limit configuration: %v", err)
+	}
+	opts = append(opts, server.WithRateLimit(rateLimit))
+	// CONCURRENCY_LIMIT_MAX sheds requests beyond an adaptive concurrency limit
+	loadShedding, err := loadshed.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("[FATAL] Invalid concurrency limit configuration: %v", err)
+	}
+	if loadShedding.Enabled() {
+		opts = append(opts, server.WithLoadShedding(loadShedding))
+	}
+	// ROUTE_TIMEOUT and ROUTE_TIMEOUTS set the deadlines of the routes
+	routeTimeouts, err := server.RouteTimeoutsFromEnv()
+	if err != nil {
+		log.Fatalf("[FATAL] Invalid route timeouts: %v", err)
+	}
+	opts = append(opts, routeTimeouts...)
+	srv := server.New(opts...)
 
 	// Start server in a goroutine
 	go func() {
 		serverCtx := context.Background()
-		telemetry.LogInfo(serverCtx, fmt.Sprintf("Starting HTTP server on port %s", port))
+		telemetry.LogInfo(serverCtx, fmt.Sprintf("Starting %s server on port %s", strings.ToUpper(scheme), port))
 		telemetry.LogInfo(serverCtx, "Server endpoints:")
-		telemetry.LogInfo(serverCtx, fmt.Sprintf("  - Root: http://localhost:%s/", port))
-		telemetry.LogInfo(serverCtx, fmt.Sprintf("  - Health: http://localhost:%s/health", port))
-		telemetry.LogInfo(serverCtx, fmt.Sprintf("  - Ready: http://localhost:%s/ready", port))
+		telemetry.LogInfo(serverCtx, fmt.Sprintf("  - Root: %s://localhost:%s/", scheme, port))
+		telemetry.LogInfo(serverCtx, fmt.Sprintf("  - Health: %s/health", adminBase))
+		telemetry.LogInfo(serverCtx, fmt.Sprintf("  - Ready: %s/ready", adminBase))
+		telemetry.LogInfo(serverCtx, fmt.Sprintf("  - SLO: %s/slo", adminBase))
+		telemetry.LogInfo(serverCtx, fmt.Sprintf("  - Metrics flush: POST %s/admin/metrics/flush", adminBase))
+		telemetry.LogInfo(serverCtx, fmt.Sprintf("  - Metrics catalog: %s/debug/metrics/catalog", adminBase))
+		telemetry.LogInfo(serverCtx, fmt.Sprintf("  - Log levels: GET/PUT/DELETE %s/admin/loglevel", adminBase))
+		telemetry.LogInfo(serverCtx, fmt.Sprintf("  - Rate limits: GET/PUT %s/admin/ratelimits", adminBase))
+		if adminAddr != "" || debugToken != "" {
+			telemetry.LogInfo(serverCtx, fmt.Sprintf("  - Profiling: %s/debug/pprof/", adminBase))
+		}
 		telemetry.LogInfo(serverCtx, "Telemetry data will be sent to OpenTelemetry Collector")
 		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
-			telemetry.LogError(serverCtx, "Server failed to start", err)
+			// log.Fatalf is bridged into the structured logger and flushes OTLP before exiting
 			log.Fatalf("[FATAL] Server failed to start: %v", err)
 		}
 	}()
 
+	// Start the gRPC server when GRPC_PORT is set, with the same TLS settings
+	var grpcSrv *server.GRPCServer
+	if grpcPort := os.Getenv("GRPC_PORT"); grpcPort != "" {
+		grpcOpts := []server.Option{server.WithPort(grpcPort)}
+		if tlsConfig != nil {
+			grpcOpts = append(grpcOpts, server.WithTLS(*tlsConfig))
+		}
+		grpcSrv = server.NewGRPCServer(grpcOpts...)
+		go func() {
+			telemetry.LogInfo(context.Background(), fmt.Sprintf("Starting gRPC server on port %s (health, reflection, greeter)", grpcPort))
+			if err := grpcSrv.Start(); err != nil {
+				log.Fatalf("[FATAL] gRPC server failed to start: %v", err)
+			}
+		}()
+	}
+
+	// SIGUSR2 toggles debug logging
+	stopLogLevelSignal := watchLogLevelSignal(ctx)
+	defer stopLogLevelSignal()
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -97,6 +178,23 @@ func main() {
 		telemetry.LogInfo(shutdownCtx, "HTTP server shutdown complete")
 	}
 
+	if grpcSrv != nil {
+		telemetry.LogInfo(shutdownCtx, "Shutting down gRPC server...")
+		if err := grpcSrv.Shutdown(shutdownCtx); err != nil {
+			telemetry.LogError(shutdownCtx, "Error shutting down gRPC server", err)
+		} else {
+			telemetry.LogInfo(shutdownCtx, "gRPC server shutdown complete")
+		}
+	}
+
+	// Flush the last window of metrics before the exporters go away
+	telemetry.LogInfo(shutdownCtx, "Flushing metrics...")
+	if err := metrics.ForceFlush(shutdownCtx); err != nil {
+		telemetry.LogWarn(shutdownCtx, fmt.Sprintf("Error flushing metrics: %v", err))
+	} else {
+		telemetry.LogInfo(shutdownCtx, "Metrics flush complete")
+	}
+
 	// Then shutdown telemetry components
 	telemetry.LogInfo(shutdownCtx, "Shutting down telemetry components...")
 	if err := metrics.Shutdown(shutdownCtx); err != nil {
@@ -118,6 +216,23 @@ func main() {
 	telemetry.LogInfo(shutdownCtx, "Server exited gracefully")
 }
 
+// readDebugToken returns DEBUG_TOKEN, or the contents of DEBUG_TOKEN_FILE (a
+// mounted secret) without surrounding whitespace
+func readDebugToken() (string, error) {
+	if file := os.Getenv("DEBUG_TOKEN_FILE"); file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		token := strings.TrimSpace(string(b))
+		if token == "" {
+			return "", fmt.Errorf("%s is empty", file)
+		}
+		return token, nil
+	}
+	return os.Getenv("DEBUG_TOKEN"), nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/rules"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/slo"
+)
+
+// runRules implements `rules generate`
+func runRules(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "generate" {
+		fmt.Fprintln(stderr, "usage: rules generate [flags]")
+		return 2
+	}
+
+	opts := rules.DefaultOptions()
+	labels := labelFlag(opts.Labels)
+
+	fs := flag.NewFlagSet("rules generate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("o", "", "write the manifest to this file instead of stdout")
+	sloConfig := fs.String("slo-config", os.Getenv("SLO_CONFIG_FILE"), "SLO config file (defaults to the built-in SLOs)")
+	fs.StringVar(&opts.Name, "name", opts.Name, "PrometheusRule name")
+	fs.StringVar(&opts.Namespace, "namespace", opts.Namespace, "PrometheusRule namespace")
+	fs.StringVar(&opts.Job, "job", opts.Job, "Prometheus job label of the app's metrics")
+	fs.Float64Var(&opts.ErrorRatioThreshold, "error-ratio", opts.ErrorRatioThreshold, "5xx ratio above which HTTPHighErrorRatio fires")
+	fs.DurationVar(&opts.LatencyP99Threshold, "latency-p99", opts.LatencyP99Threshold, "p99 latency above which HTTPHighLatencyP99 fires")
+	fs.Var(labels, "label", "extra PrometheusRule label as key=value (repeatable)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	if *sloConfig != "" {
+		defs, err := slo.LoadConfig(*sloConfig)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		opts.SLOs = defs
+	}
+
+	out := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := rules.Render(out, rules.Generate(opts)); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// labelFlag collects repeated key=value flags into a map
+type labelFlag map[string]string
+
+func (l labelFlag) String() string {
+	pairs := make([]string, 0, len(l))
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (l labelFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	l[k] = v
+	return nil
+}
@@ -0,0 +1,137 @@
+// Package dashboards generates the app's Grafana dashboards from its metric and
+// log catalogs, and lints existing dashboards against those catalogs.
+package dashboards
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Dashboard is the subset of the Grafana dashboard model the generator writes
+type Dashboard struct {
+	Annotations   Annotations       `json:"annotations"`
+	Description   string            `json:"description,omitempty"`
+	Editable      bool              `json:"editable"`
+	GraphTooltip  int               `json:"graphTooltip"`
+	ID            *int              `json:"id"`
+	Links         []interface{}     `json:"links"`
+	Panels        []Panel           `json:"panels"`
+	Refresh       string            `json:"refresh"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Tags          []string          `json:"tags"`
+	Templating    Templating        `json:"templating"`
+	Time          TimeRange         `json:"time"`
+	Timepicker    map[string]string `json:"timepicker"`
+	Timezone      string            `json:"timezone"`
+	Title         string            `json:"title"`
+	UID           string            `json:"uid"`
+	Version       int               `json:"version"`
+}
+
+// Annotations holds the dashboard annotation queries
+type Annotations struct {
+	List []map[string]interface{} `json:"list"`
+}
+
+// Templating holds the dashboard variables
+type Templating struct {
+	List []interface{} `json:"list"`
+}
+
+// TimeRange is the default time range of a dashboard
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// DatasourceRef references a datasource by type and UID
+type DatasourceRef struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+// GridPos is the position and size of a panel
+type GridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Panel is a single dashboard panel
+type Panel struct {
+	Datasource  *DatasourceRef         `json:"datasource,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	FieldConfig map[string]interface{} `json:"fieldConfig,omitempty"`
+	GridPos     GridPos                `json:"gridPos"`
+	ID          int                    `json:"id"`
+	Options     map[string]interface{} `json:"options,omitempty"`
+	Targets     []Target               `json:"targets"`
+	Title       string                 `json:"title"`
+	Type        string                 `json:"type"`
+}
+
+// Target is a panel query
+type Target struct {
+	Datasource   *DatasourceRef `json:"datasource,omitempty"`
+	Expr         string         `json:"expr,omitempty"`
+	Query        string         `json:"query,omitempty"`
+	QueryType    string         `json:"queryType,omitempty"`
+	LegendFormat string         `json:"legendFormat,omitempty"`
+	Limit        int            `json:"limit,omitempty"`
+	RefID        string         `json:"refId"`
+}
+
+// newDashboard returns a dashboard with the defaults shared by every generated dashboard
+func newDashboard(uid, title, description string, tags []string) Dashboard {
+	return Dashboard{
+		Annotations: Annotations{List: []map[string]interface{}{{
+			"builtIn":    1,
+			"datasource": map[string]string{"type": "grafana", "uid": "-- Grafana --"},
+			"enable":     true,
+			"hide":       true,
+			"iconColor":  "rgba(0, 211, 255, 1)",
+			"name":       "Annotations & Alerts",
+			"type":       "dashboard",
+		}}},
+		Description:   description,
+		Editable:      true,
+		Links:         []interface{}{},
+		Panels:        []Panel{},
+		Refresh:       "30s",
+		SchemaVersion: 38,
+		Tags:          tags,
+		Templating:    Templating{List: []interface{}{}},
+		Time:          TimeRange{From: "now-1h", To: "now"},
+		Timepicker:    map[string]string{},
+		Timezone:      "",
+		Title:         title,
+		UID:           uid,
+		Version:       1,
+	}
+}
+
+// addPanel appends a panel, assigning its ID and laying panels out left to right
+func (d *Dashboard) addPanel(p Panel) {
+	p.ID = len(d.Panels) + 1
+	if len(d.Panels) > 0 {
+		last := d.Panels[len(d.Panels)-1].GridPos
+		p.GridPos.X, p.GridPos.Y = last.X+last.W, last.Y
+		if p.GridPos.X+p.GridPos.W > 24 {
+			p.GridPos.X, p.GridPos.Y = 0, last.Y+last.H
+		}
+	}
+	for i := range p.Targets {
+		p.Targets[i].RefID = string(rune('A' + i))
+	}
+	d.Panels = append(d.Panels, p)
+}
+
+// Marshal renders the dashboard as indented JSON with a trailing newline
+func (d Dashboard) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode dashboard %s: %w", d.UID, err)
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,214 @@
+package dashboards
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+)
+
+func TestDashboards(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Dashboards Suite")
+}
+
+// dashboardJSON builds a single-panel dashboard with the given panel datasource and query
+func dashboardJSON(datasource interface{}, expr string) []byte {
+	data, err := json.Marshal(map[string]interface{}{
+		"title": "test",
+		"panels": []interface{}{map[string]interface{}{
+			"title":      "Panel",
+			"datasource": datasource,
+			"targets":    []interface{}{map[string]interface{}{"refId": "A", "expr": expr}},
+		}},
+	})
+	Expect(err).NotTo(HaveOccurred())
+	return data
+}
+
+var (
+	prometheusDS = map[string]string{"type": "prometheus", "uid": "prometheus"}
+	lokiDS       = map[string]string{"type": "loki", "uid": "loki"}
+)
+
+var _ = Describe("Dashboards", func() {
+	var linter *Linter
+
+	BeforeEach(func() {
+		linter = NewLinter(DefaultOptions())
+	})
+
+	lint := func(data []byte) []string {
+		issues, err := linter.Lint("test.json", data)
+		Expect(err).NotTo(HaveOccurred())
+		messages := make([]string, len(issues))
+		for i, issue := range issues {
+			messages[i] = issue.Message
+		}
+		return messages
+	}
+
+	Describe("Generate", func() {
+		It("should generate the metrics, logs and traces dashboards", func() {
+			generated := Generate(DefaultOptions())
+			Expect(generated).To(HaveKey("dashboard-metrics.json"))
+			Expect(generated).To(HaveKey("dashboard-logs.json"))
+			Expect(generated).To(HaveKey("dashboard-traces.json"))
+			Expect(generated["dashboard-metrics.json"].UID).To(Equal("dm-nkp-custom-app-metrics"))
+		})
+
+		It("should have a panel per catalog instrument", func() {
+			d := Generate(DefaultOptions())["dashboard-metrics.json"]
+			Expect(d.Panels).To(HaveLen(len(metrics.Catalog())))
+			for i, p := range d.Panels {
+				Expect(p.ID).To(Equal(i + 1))
+				Expect(p.GridPos.X + p.GridPos.W).To(BeNumerically("<=", 24))
+				Expect(p.Targets).NotTo(BeEmpty())
+			}
+		})
+
+		It("should produce dashboards that pass the linter", func() {
+			for name, d := range Generate(DefaultOptions()) {
+				data, err := d.Marshal()
+				Expect(err).NotTo(HaveOccurred())
+				issues, err := linter.Lint(name, data)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(issues).To(BeEmpty(), name)
+			}
+		})
+
+		It("should use the configured datasource UIDs", func() {
+			opts := DefaultOptions()
+			opts.LokiUID = "custom-loki"
+			d := Generate(opts)["dashboard-logs.json"]
+			Expect(d.Panels[0].Datasource.UID).To(Equal("custom-loki"))
+		})
+	})
+
+	Describe("Lint", func() {
+		It("should flag metrics the app no longer emits", func() {
+			Expect(lint(dashboardJSON(prometheusDS, `rate(http_requests_count[5m])`))).To(ConsistOf(
+				`target A: metric "http_requests_count" is not emitted by the app`))
+		})
+
+		It("should flag labels the metric doesn't carry", func() {
+			Expect(lint(dashboardJSON(prometheusDS, `http_response_size_bytes_sum{quantile="0.5"}`))).To(ConsistOf(
+				`target A: label "quantile" is not emitted on http_response_size_bytes_sum`))
+			Expect(lint(dashboardJSON(prometheusDS, `rate(http_requests_by_method_total{path="/"}[5m])`))).To(ConsistOf(
+				`target A: label "path" is not emitted on http_requests_by_method_total`))
+		})
+
+		It("should flag unknown grouping labels", func() {
+			Expect(lint(dashboardJSON(prometheusDS, `sum by (route) (rate(http_requests_total[5m]))`))).To(ConsistOf(
+				`target A: grouping label "route" is not emitted on the queried metrics`))
+		})
+
+		It("should accept catalog labels, target labels and le on buckets", func() {
+			Expect(lint(dashboardJSON(prometheusDS,
+				`histogram_quantile(0.99, sum by (le, job) (rate(http_request_duration_seconds_bucket{namespace="$ns"}[5m])))`))).To(BeEmpty())
+			Expect(lint(dashboardJSON(prometheusDS,
+				`sum by (method, status) (rate(http_requests_by_method_total{status=~"5.."}[5m]))`))).To(BeEmpty())
+		})
+
+		It("should ignore metrics outside the app's namespaces", func() {
+			Expect(lint(dashboardJSON(prometheusDS, `sum by (pod) (container_memory_working_set_bytes{namespace="x"})`))).To(BeEmpty())
+		})
+
+		It("should flag datasources referenced by name", func() {
+			Expect(lint(dashboardJSON("Prometheus", `rate(http_requests_total[5m])`))).To(ConsistOf(
+				`datasource referenced by name "Prometheus"; use {"type": ..., "uid": ...}`))
+		})
+
+		It("should accept template variables as datasources", func() {
+			Expect(lint(dashboardJSON("$datasource", `up`))).To(BeEmpty())
+		})
+
+		It("should flag unexpected datasource UIDs", func() {
+			Expect(lint(dashboardJSON(map[string]string{"type": "prometheus", "uid": "prom-old"}, `up`))).To(ConsistOf(
+				`prometheus datasource uid "prom-old", expected "prometheus"`))
+		})
+
+		It("should flag log attributes the app doesn't emit", func() {
+			Expect(lint(dashboardJSON(lokiDS, `{service_name="dm-nkp-gitops-custom-app"} | severity="error"`))).To(ConsistOf(
+				`target A: log attribute "severity" is not emitted by the app`))
+			Expect(lint(dashboardJSON(lokiDS, `{service_name="x", namespace="y"}`))).To(BeEmpty())
+			Expect(lint(dashboardJSON(lokiDS, `{applabel="x"}`))).To(ConsistOf(
+				`target A: stream label "applabel" is not produced for the app's logs`))
+		})
+
+		It("should not check label filters after a parser stage", func() {
+			Expect(lint(dashboardJSON(lokiDS, `{service_name="x"} | json | anything="y"`))).To(BeEmpty())
+		})
+
+		It("should lint panels nested in rows and the API envelope", func() {
+			data, err := json.Marshal(map[string]interface{}{"dashboard": map[string]interface{}{
+				"panels": []interface{}{map[string]interface{}{
+					"title": "Row",
+					"type":  "row",
+					"panels": []interface{}{map[string]interface{}{
+						"title":      "Nested",
+						"datasource": prometheusDS,
+						"targets":    []interface{}{map[string]interface{}{"refId": "A", "expr": "http_gone_total"}},
+					}},
+				}},
+			}})
+			Expect(err).NotTo(HaveOccurred())
+			issues, err := linter.Lint("test.json", data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issues).To(HaveLen(1))
+			Expect(issues[0].Panel).To(Equal("Nested"))
+		})
+
+		It("should return an error for invalid JSON", func() {
+			_, err := linter.Lint("test.json", []byte("{"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should pass for the dashboards in the repository", func() {
+			files, err := filepath.Glob("../../grafana/*.json")
+			Expect(err).NotTo(HaveOccurred())
+			chartFiles, err := filepath.Glob("../../chart/dm-nkp-gitops-custom-app/files/grafana/*.json")
+			Expect(err).NotTo(HaveOccurred())
+			files = append(files, chartFiles...)
+			Expect(files).NotTo(BeEmpty())
+
+			for _, file := range files {
+				data, err := os.ReadFile(file)
+				Expect(err).NotTo(HaveOccurred())
+				issues, err := linter.Lint(file, data)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(issues).To(BeEmpty(), file)
+			}
+		})
+	})
+
+	Describe("parseQuery", func() {
+		It("should extract metrics, matchers and grouping labels", func() {
+			refs := parseQuery(`sum by (method) (rate(http_requests_by_method_total{status=~"5..", job="$job"}[5m])) / on(job) group_left sum(up)`)
+			Expect(refs.selectors).To(HaveLen(2))
+			Expect(refs.selectors[0].metric).To(Equal("http_requests_by_method_total"))
+			Expect(refs.selectors[0].labels).To(Equal([]string{"status", "job"}))
+			Expect(refs.selectors[1].metric).To(Equal("up"))
+			Expect(refs.grouping).To(Equal([]string{"method", "job"}))
+		})
+
+		It("should extract LogQL stream labels and label filters", func() {
+			refs := parseQuery(`sum(count_over_time({service_name="app"} |= "GET" | log_level="error" [1m]))`)
+			Expect(refs.selectors).To(HaveLen(1))
+			Expect(refs.selectors[0].metric).To(BeEmpty())
+			Expect(refs.selectors[0].labels).To(Equal([]string{"service_name"}))
+			Expect(refs.filters).To(Equal([]string{"log_level"}))
+		})
+
+		It("should not treat strings, durations or keywords as metrics", func() {
+			refs := parseQuery(`rate(http_requests_total{path="foo_bar"}[5m] offset 1h) > 0.5 and vector(1)`)
+			Expect(refs.selectors).To(HaveLen(1))
+			Expect(refs.selectors[0].metric).To(Equal("http_requests_total"))
+		})
+	})
+})
@@ -0,0 +1,262 @@
+package dashboards
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
+)
+
+// Options controls the generated dashboards
+type Options struct {
+	// ServiceName is the OTel service.name the app reports
+	ServiceName string
+	// PrometheusUID, LokiUID and TempoUID are the provisioned datasource UIDs
+	// (see chart/dm-nkp-gitops-custom-app/templates/grafana-datasources.yaml)
+	PrometheusUID string
+	LokiUID       string
+	TempoUID      string
+	// Routes get a dedicated trace search panel each
+	Routes []string
+	// SlowTraceThreshold is the TraceQL duration for the slow traces panel
+	SlowTraceThreshold string
+}
+
+// DefaultOptions returns the options matching the Helm chart's provisioned datasources
+func DefaultOptions() Options {
+	return Options{
+		ServiceName:        "dm-nkp-gitops-custom-app",
+		PrometheusUID:      "prometheus",
+		LokiUID:            "loki",
+		TempoUID:           "tempo",
+		Routes:             []string{"/", "/health", "/ready"},
+		SlowTraceThreshold: "50ms",
+	}
+}
+
+// Generate returns the generated dashboards keyed by file name
+func Generate(opts Options) map[string]Dashboard {
+	return map[string]Dashboard{
+		"dashboard-metrics.json": metricsDashboard(opts),
+		"dashboard-logs.json":    logsDashboard(opts),
+		"dashboard-traces.json":  tracesDashboard(opts),
+	}
+}
+
+// metricsDashboard has one panel per instrument in the metrics catalog
+func metricsDashboard(opts Options) Dashboard {
+	d := newDashboard("dm-nkp-custom-app-metrics", opts.ServiceName+" - Metrics",
+		"Generated from the metrics catalog - do not edit by hand (go run ./cmd/app dashboards generate)",
+		[]string{opts.ServiceName, "metrics", "generated"})
+	ds := &DatasourceRef{Type: "prometheus", UID: opts.PrometheusUID}
+
+	for _, inst := range metrics.Catalog() {
+		p := Panel{
+			Datasource:  ds,
+			Description: fmt.Sprintf("%s (%s %s)", inst.Description, inst.Kind, inst.Name),
+			GridPos:     GridPos{H: 8, W: 12},
+			Title:       inst.Description,
+			Type:        "timeseries",
+			FieldConfig: fieldConfig(panelUnit(inst)),
+			Options:     timeseriesOptions(),
+		}
+
+		by := ""
+		legend := "{{job}}"
+		if len(inst.Attributes) > 0 {
+			by = fmt.Sprintf(" by (%s) ", strings.Join(inst.Attributes, ", "))
+			legends := make([]string, len(inst.Attributes))
+			for i, attr := range inst.Attributes {
+				legends[i] = "{{" + attr + "}}"
+			}
+			legend = strings.Join(legends, " ")
+		}
+
+		switch inst.Kind {
+		case metrics.KindCounter:
+			p.Targets = []Target{{Expr: fmt.Sprintf("sum%s(rate(%s[5m]))", by, inst.Name), LegendFormat: legend}}
+		case metrics.KindHistogram:
+			for _, q := range []string{"0.50", "0.95", "0.99"} {
+				p.Targets = append(p.Targets, Target{
+					Expr:         fmt.Sprintf("histogram_quantile(%s, sum by (le) (rate(%s_bucket[5m])))", q, inst.Name),
+					LegendFormat: "p" + strings.TrimPrefix(strings.TrimRight(q, "0"), "0."),
+				})
+			}
+			p.Targets = append(p.Targets, Target{
+				Expr:         fmt.Sprintf("sum(rate(%[1]s_sum[5m])) / sum(rate(%[1]s_count[5m]))", inst.Name),
+				LegendFormat: "Average",
+			})
+		case metrics.KindGauge:
+			if by == "" {
+				p.Type = "gauge"
+				p.Options = map[string]interface{}{"showThresholdLabels": false, "showThresholdMarkers": true}
+			}
+			p.Targets = []Target{{Expr: fmt.Sprintf("sum%s(%s)", by, inst.Name), LegendFormat: legend}}
+		}
+		d.addPanel(p)
+	}
+	return d
+}
+
+// logsDashboard queries the OTLP logs by service and by the attributes in the log catalog
+func logsDashboard(opts Options) Dashboard {
+	d := newDashboard("dm-nkp-custom-app-logs", opts.ServiceName+" - Logs",
+		"Generated from the log catalog - do not edit by hand (go run ./cmd/app dashboards generate)",
+		[]string{opts.ServiceName, "logs", "generated"})
+	ds := &DatasourceRef{Type: "loki", UID: opts.LokiUID}
+	stream := fmt.Sprintf(`service_name=%q`, opts.ServiceName)
+	levelLabel := lokiKey(telemetry.LogLevelKey)
+
+	d.addPanel(Panel{
+		Datasource:  ds,
+		Description: "All application logs sent via OTLP (OTel SDK -> OTel Collector -> Loki)",
+		GridPos:     GridPos{H: 12, W: 24},
+		Options:     logsOptions(),
+		Targets:     []Target{{Datasource: ds, Expr: "{" + stream + "}"}},
+		Title:       "Application Logs (OTLP)",
+		Type:        "logs",
+	})
+
+	d.addPanel(Panel{
+		Datasource:  ds,
+		FieldConfig: fieldConfig("short"),
+		GridPos:     GridPos{H: 8, W: 12},
+		Options:     timeseriesOptions(),
+		Targets: []Target{{
+			Datasource:   ds,
+			Expr:         fmt.Sprintf("sum(count_over_time({%s}[1m]))", stream),
+			LegendFormat: "Log lines",
+		}},
+		Title: "Log Volume (per minute)",
+		Type:  "timeseries",
+	})
+
+	levels := Panel{
+		Datasource:  ds,
+		FieldConfig: fieldConfig("short"),
+		GridPos:     GridPos{H: 8, W: 12},
+		Options:     timeseriesOptions(),
+		Title:       "Log Levels (per minute)",
+		Type:        "timeseries",
+	}
+	for _, level := range telemetry.LogLevels() {
+		levels.Targets = append(levels.Targets, Target{
+			Datasource:   ds,
+			Expr:         fmt.Sprintf("sum(count_over_time({%s} | %s=%q [1m]))", stream, levelLabel, level),
+			LegendFormat: level,
+		})
+	}
+	d.addPanel(levels)
+
+	for _, level := range []struct{ value, title string }{{"error", "Error Logs"}, {"warn", "Warning Logs"}} {
+		d.addPanel(Panel{
+			Datasource: ds,
+			GridPos:    GridPos{H: 10, W: 12},
+			Options:    logsOptions(),
+			Targets:    []Target{{Datasource: ds, Expr: fmt.Sprintf("{%s} | %s=%q", stream, levelLabel, level.value)}},
+			Title:      level.title,
+			Type:       "logs",
+		})
+	}
+
+	d.addPanel(Panel{
+		Datasource:  ds,
+		Description: fmt.Sprintf("Log records carrying an exception (%q)", telemetry.ExceptionTypeKey),
+		GridPos:     GridPos{H: 10, W: 24},
+		Options:     logsOptions(),
+		Targets:     []Target{{Datasource: ds, Expr: fmt.Sprintf(`{%s} | %s != ""`, stream, lokiKey(telemetry.ExceptionTypeKey))}},
+		Title:       "Logs with Error Details",
+		Type:        "logs",
+	})
+	return d
+}
+
+// tracesDashboard searches the app's traces in Tempo with TraceQL
+func tracesDashboard(opts Options) Dashboard {
+	d := newDashboard("dm-nkp-custom-app-traces", opts.ServiceName+" - Traces",
+		"Generated - do not edit by hand (go run ./cmd/app dashboards generate)",
+		[]string{opts.ServiceName, "traces", "generated"})
+	ds := &DatasourceRef{Type: "tempo", UID: opts.TempoUID}
+	service := fmt.Sprintf(`resource.service.name = %q`, opts.ServiceName)
+
+	search := func(title, filter string, width int) {
+		query := "{ " + service + " }"
+		if filter != "" {
+			query = "{ " + service + " && " + filter + " }"
+		}
+		d.addPanel(Panel{
+			Datasource: ds,
+			GridPos:    GridPos{H: 12, W: width},
+			Options:    map[string]interface{}{},
+			Targets:    []Target{{Datasource: ds, QueryType: "traceql", Query: query, Limit: 20}},
+			Title:      title,
+			Type:       "traces",
+		})
+	}
+
+	search("All Application Traces", "", 24)
+	for _, route := range opts.Routes {
+		search(fmt.Sprintf("Traces - %s", route), fmt.Sprintf(`span.http.route = %q`, route), 12)
+	}
+	search("Successful Requests (HTTP 2xx)", "span.http.status_code < 400", 12)
+	search("Error Requests (HTTP 4xx/5xx)", "span.http.status_code >= 400", 12)
+	search("gRPC Requests", `span.rpc.system = "grpc"`, 12)
+	search("gRPC Errors (status code != OK)", `span.rpc.system = "grpc" && span.rpc.grpc.status_code != 0`, 12)
+	search("Failed Spans (status = error)", "status = error", 12)
+	search(fmt.Sprintf("Slow Traces (> %s)", opts.SlowTraceThreshold), "duration > "+opts.SlowTraceThreshold, 12)
+	return d
+}
+
+// panelUnit maps the catalog's UCUM unit to a Grafana unit
+func panelUnit(inst metrics.Instrument) string {
+	switch {
+	case inst.Kind == metrics.KindCounter && inst.Unit == "{request}":
+		return "reqps"
+	case inst.Kind == metrics.KindCounter:
+		return "ops"
+	case inst.Unit == "s":
+		return "s"
+	case inst.Unit == "By":
+		return "bytes"
+	case inst.Unit == "{ratio}":
+		return "percentunit"
+	default:
+		return "short"
+	}
+}
+
+func fieldConfig(unit string) map[string]interface{} {
+	return map[string]interface{}{
+		"defaults": map[string]interface{}{
+			"color": map[string]string{"mode": "palette-classic"},
+			"unit":  unit,
+		},
+		"overrides": []interface{}{},
+	}
+}
+
+func timeseriesOptions() map[string]interface{} {
+	return map[string]interface{}{
+		"legend":  map[string]interface{}{"calcs": []string{"mean", "lastNotNull", "max"}, "displayMode": "table", "placement": "bottom"},
+		"tooltip": map[string]string{"mode": "multi", "sort": "none"},
+	}
+}
+
+func logsOptions() map[string]interface{} {
+	return map[string]interface{}{
+		"dedupStrategy":      "none",
+		"enableLogDetails":   true,
+		"prettifyLogMessage": true,
+		"showCommonLabels":   false,
+		"showLabels":         true,
+		"showTime":           true,
+		"sortOrder":          "Descending",
+		"wrapLogMessage":     true,
+	}
+}
+
+// lokiKey is the name Loki gives an OTLP attribute (dots become underscores)
+func lokiKey(key string) string {
+	return strings.ReplaceAll(key, ".", "_")
+}
@@ -0,0 +1,295 @@
+package dashboards
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
+)
+
+// prometheusTargetLabels are added by the scrape/collector pipeline to every series
+var prometheusTargetLabels = []string{
+	"job", "instance", "namespace", "pod", "container", "service", "endpoint",
+	"service_name", "service_namespace", "service_version", "service_instance_id",
+	"otel_collector", "otel_collector_namespace", "otel_scope_name", "otel_scope_version",
+	"exported_job", "exported_instance",
+}
+
+// lokiLabels are stream labels and structured metadata Loki derives on its own
+// (OTLP ingestion and the Logging Operator), independent of what the app emits
+var lokiLabels = []string{
+	"job", "namespace", "pod", "container", "host", "app", "app_kubernetes_io_name", "app_kubernetes_io_instance",
+	"k8s_namespace_name", "k8s_pod_name", "k8s_container_name", "k8s_deployment_name",
+	"detected_level", "level", "severity_text", "severity_number", "trace_id", "span_id", "flags",
+	"observed_timestamp", "scope_name", "scope_version",
+}
+
+// Issue is a single lint finding
+type Issue struct {
+	File    string
+	Panel   string
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: panel %q: %s", i.File, i.Panel, i.Message)
+}
+
+// Linter checks dashboards against the metric and log catalogs
+type Linter struct {
+	opts Options
+	// series maps every Prometheus series name the app emits to its instrument
+	series map[string]metrics.Instrument
+	// namespaces are the metric name prefixes the app owns (e.g. "http_")
+	namespaces []string
+	lokiKeys   map[string]bool
+}
+
+// NewLinter creates a linter for the current catalogs.
+// Datasource UIDs are checked against the ones in opts.
+func NewLinter(opts Options) *Linter {
+	l := &Linter{
+		opts:     opts,
+		series:   make(map[string]metrics.Instrument),
+		lokiKeys: make(map[string]bool),
+	}
+
+	namespaces := make(map[string]bool)
+	for _, inst := range metrics.Catalog() {
+		for _, name := range inst.SeriesNames() {
+			l.series[name] = inst
+		}
+		prefix, _, _ := strings.Cut(inst.Name, "_")
+		namespaces[prefix+"_"] = true
+	}
+	for ns := range namespaces {
+		l.namespaces = append(l.namespaces, ns)
+	}
+	sort.Strings(l.namespaces)
+
+	for _, key := range append(telemetry.LogAttributeKeys(), telemetry.LogResourceKeys()...) {
+		l.lokiKeys[lokiKey(key)] = true
+	}
+	for _, label := range lokiLabels {
+		l.lokiKeys[label] = true
+	}
+	return l
+}
+
+// Lint checks one dashboard file. Both plain dashboards and the
+// {"dashboard": {...}} envelope used by the Grafana API are accepted.
+func (l *Linter) Lint(file string, data []byte) ([]Issue, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: invalid JSON: %w", file, err)
+	}
+	if inner, ok := raw["dashboard"]; ok {
+		if err := json.Unmarshal(inner, &raw); err != nil {
+			return nil, fmt.Errorf("%s: invalid dashboard: %w", file, err)
+		}
+	}
+
+	var panels []lintPanel
+	if p, ok := raw["panels"]; ok {
+		if err := json.Unmarshal(p, &panels); err != nil {
+			return nil, fmt.Errorf("%s: invalid panels: %w", file, err)
+		}
+	}
+
+	var issues []Issue
+	for _, p := range flattenPanels(panels) {
+		report := func(format string, args ...interface{}) {
+			issues = append(issues, Issue{File: file, Panel: p.Title, Message: fmt.Sprintf(format, args...)})
+		}
+
+		panelDS, msg := l.checkDatasource(p.Datasource)
+		if msg != "" {
+			report("%s", msg)
+		}
+		for _, t := range p.Targets {
+			ds := panelDS
+			if len(t.Datasource) > 0 && string(t.Datasource) != "null" {
+				var targetMsg string
+				ds, targetMsg = l.checkDatasource(t.Datasource)
+				if targetMsg != "" && targetMsg != msg {
+					report("%s", targetMsg)
+				}
+				if panelDS.Type == ds.Type && panelDS.UID != "" && ds.UID != "" && panelDS.UID != ds.UID {
+					report("target %s uses %s datasource %q but the panel uses %q", t.RefID, ds.Type, ds.UID, panelDS.UID)
+				}
+			}
+
+			switch ds.Type {
+			case "prometheus":
+				for _, m := range l.checkPromQL(t.Expr) {
+					report("target %s: %s", t.RefID, m)
+				}
+			case "loki":
+				for _, m := range l.checkLogQL(t.Expr) {
+					report("target %s: %s", t.RefID, m)
+				}
+			}
+		}
+	}
+	return issues, nil
+}
+
+type lintPanel struct {
+	Title      string          `json:"title"`
+	Datasource json.RawMessage `json:"datasource"`
+	Targets    []lintTarget    `json:"targets"`
+	Panels     []lintPanel     `json:"panels"`
+}
+
+type lintTarget struct {
+	Datasource json.RawMessage `json:"datasource"`
+	Expr       string          `json:"expr"`
+	RefID      string          `json:"refId"`
+}
+
+// flattenPanels includes the panels nested in collapsed rows
+func flattenPanels(panels []lintPanel) []lintPanel {
+	var flat []lintPanel
+	for _, p := range panels {
+		flat = append(flat, p)
+		flat = append(flat, flattenPanels(p.Panels)...)
+	}
+	return flat
+}
+
+// checkDatasource resolves a datasource reference and reports names and unexpected UIDs
+func (l *Linter) checkDatasource(raw json.RawMessage) (DatasourceRef, string) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return DatasourceRef{}, ""
+	}
+
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		if strings.HasPrefix(name, "$") || strings.HasPrefix(name, "-- ") {
+			return DatasourceRef{}, ""
+		}
+		return DatasourceRef{Type: strings.ToLower(name)},
+			fmt.Sprintf("datasource referenced by name %q; use {\"type\": ..., \"uid\": ...}", name)
+	}
+
+	var ref DatasourceRef
+	if err := json.Unmarshal(raw, &ref); err != nil {
+		return DatasourceRef{}, fmt.Sprintf("invalid datasource reference %s", raw)
+	}
+	if strings.HasPrefix(ref.UID, "$") || strings.HasPrefix(ref.UID, "-- ") {
+		return ref, ""
+	}
+
+	expected := map[string]string{
+		"prometheus": l.opts.PrometheusUID,
+		"loki":       l.opts.LokiUID,
+		"tempo":      l.opts.TempoUID,
+	}[ref.Type]
+	if expected != "" && ref.UID != expected {
+		return ref, fmt.Sprintf("%s datasource uid %q, expected %q", ref.Type, ref.UID, expected)
+	}
+	return ref, ""
+}
+
+// checkPromQL reports app metrics and labels the app doesn't emit
+func (l *Linter) checkPromQL(expr string) []string {
+	if expr == "" {
+		return nil
+	}
+	refs := parseQuery(expr)
+
+	var problems []string
+	allowed := make(map[string]bool)
+	for _, label := range prometheusTargetLabels {
+		allowed[label] = true
+	}
+
+	usesAppMetric := false
+	for _, sel := range refs.selectors {
+		if !l.ownsMetric(sel.metric) {
+			continue
+		}
+		usesAppMetric = true
+
+		inst, ok := l.series[sel.metric]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("metric %q is not emitted by the app", sel.metric))
+			continue
+		}
+		instLabels := l.instrumentLabels(inst, sel.metric)
+		for label := range instLabels {
+			allowed[label] = true
+		}
+		for _, label := range sel.labels {
+			if !instLabels[label] && !contains(prometheusTargetLabels, label) && label != "__name__" {
+				problems = append(problems, fmt.Sprintf("label %q is not emitted on %s", label, sel.metric))
+			}
+		}
+	}
+
+	if usesAppMetric {
+		for _, label := range refs.grouping {
+			if !allowed[label] {
+				problems = append(problems, fmt.Sprintf("grouping label %q is not emitted on the queried metrics", label))
+			}
+		}
+	}
+	return problems
+}
+
+// checkLogQL reports labels and log attributes the app (or Loki) doesn't produce
+func (l *Linter) checkLogQL(expr string) []string {
+	if expr == "" {
+		return nil
+	}
+	refs := parseQuery(expr)
+
+	var problems []string
+	for _, sel := range refs.selectors {
+		for _, label := range sel.labels {
+			if !l.lokiKeys[label] {
+				problems = append(problems, fmt.Sprintf("stream label %q is not produced for the app's logs", label))
+			}
+		}
+	}
+	for _, label := range append(refs.filters, refs.grouping...) {
+		if !l.lokiKeys[label] {
+			problems = append(problems, fmt.Sprintf("log attribute %q is not emitted by the app", label))
+		}
+	}
+	return problems
+}
+
+// ownsMetric reports whether the metric name is in one of the app's namespaces
+func (l *Linter) ownsMetric(name string) bool {
+	for _, ns := range l.namespaces {
+		if strings.HasPrefix(name, ns) {
+			return true
+		}
+	}
+	return false
+}
+
+// instrumentLabels returns the labels a series of the instrument carries
+func (l *Linter) instrumentLabels(inst metrics.Instrument, series string) map[string]bool {
+	labels := make(map[string]bool, len(inst.Attributes)+1)
+	for _, attr := range inst.Attributes {
+		labels[attr] = true
+	}
+	if strings.HasSuffix(series, "_bucket") {
+		labels["le"] = true
+	}
+	return labels
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
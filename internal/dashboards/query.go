@@ -0,0 +1,231 @@
+package dashboards
+
+import (
+	"strings"
+	"unicode"
+)
+
+// selector is a metric name or stream selector together with the labels it matches on
+type selector struct {
+	metric string
+	labels []string
+}
+
+// queryRefs is what a PromQL or LogQL query references
+type queryRefs struct {
+	selectors []selector
+	// grouping are labels used in by/without/on/ignoring clauses
+	grouping []string
+	// filters are labels used in LogQL label filter expressions (| key="value")
+	filters []string
+}
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokString
+	tokNumber
+	tokVariable
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits a PromQL/LogQL query into identifiers, strings, numbers,
+// Grafana template variables and punctuation/operators
+func tokenize(q string) []token {
+	var tokens []token
+	for i := 0; i < len(q); {
+		c := rune(q[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'' || c == '`':
+			j := i + 1
+			for j < len(q) && rune(q[j]) != c {
+				if q[j] == '\\' && c != '`' {
+					j++
+				}
+				j++
+			}
+			end := j
+			if end > len(q) {
+				end = len(q)
+			}
+			tokens = append(tokens, token{tokString, q[i+1 : end]})
+			i = end + 1
+		case c == '$':
+			j := i + 1
+			if j < len(q) && q[j] == '{' {
+				for j < len(q) && q[j] != '}' {
+					j++
+				}
+				j++
+			} else {
+				for j < len(q) && isIdentChar(rune(q[j])) {
+					j++
+				}
+			}
+			if j > len(q) {
+				j = len(q)
+			}
+			tokens = append(tokens, token{tokVariable, q[i:j]})
+			i = j
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(q) && unicode.IsDigit(rune(q[i+1]))):
+			j := i
+			for j < len(q) && (isIdentChar(rune(q[j])) || q[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokNumber, q[i:j]})
+			i = j
+		case isIdentStart(c):
+			j := i
+			for j < len(q) && (isIdentChar(rune(q[j])) || q[j] == ':') {
+				j++
+			}
+			tokens = append(tokens, token{tokIdent, q[i:j]})
+			i = j
+		default:
+			// Two-character operators: != =~ !~ >= <= == |= |~
+			if i+1 < len(q) && strings.Contains("!=~><|", string(c)) && strings.Contains("=~", string(q[i+1])) {
+				tokens = append(tokens, token{tokPunct, q[i : i+2]})
+				i += 2
+				continue
+			}
+			tokens = append(tokens, token{tokPunct, string(c)})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || c == ':' || unicode.IsLetter(c)
+}
+
+func isIdentChar(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// groupingKeywords introduce a parenthesised label list
+var groupingKeywords = map[string]bool{
+	"by": true, "without": true, "on": true, "ignoring": true, "group_left": true, "group_right": true,
+}
+
+// promKeywords are PromQL/LogQL words that are neither metric names nor functions
+var promKeywords = map[string]bool{
+	"and": true, "or": true, "unless": true, "bool": true, "offset": true, "inf": true, "nan": true,
+	"group_left": true, "group_right": true,
+}
+
+// logqlParsers make every extracted field a label, so label filters after them can't be checked
+var logqlParsers = map[string]bool{
+	"json": true, "logfmt": true, "regexp": true, "pattern": true, "unpack": true,
+}
+
+// parseQuery extracts selectors, grouping labels and label filters from a PromQL or LogQL query
+func parseQuery(q string) queryRefs {
+	var refs queryRefs
+	tokens := tokenize(q)
+	dynamicLabels := false
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		next := func(k int) token {
+			if i+k < len(tokens) {
+				return tokens[i+k]
+			}
+			return token{kind: tokPunct}
+		}
+
+		switch {
+		case t.kind == tokIdent && groupingKeywords[t.text] && next(1).text == "(":
+			labels, end := parseLabelList(tokens, i+2)
+			refs.grouping = append(refs.grouping, labels...)
+			i = end
+
+		case t.kind == tokIdent && (next(1).text == "(" || groupingKeywords[next(1).text]):
+			// function call, or aggregation with a leading by/without clause
+
+		case t.kind == tokIdent && promKeywords[t.text]:
+
+		case t.kind == tokIdent:
+			sel := selector{metric: t.text}
+			if next(1).text == "{" {
+				var end int
+				sel.labels, end = parseMatchers(tokens, i+2)
+				i = end
+			}
+			refs.selectors = append(refs.selectors, sel)
+
+		case t.text == "{":
+			sel := selector{}
+			var end int
+			sel.labels, end = parseMatchers(tokens, i+1)
+			i = end
+			refs.selectors = append(refs.selectors, sel)
+
+		case t.text == "[":
+			for i < len(tokens) && tokens[i].text != "]" {
+				i++
+			}
+
+		case t.text == "|":
+			// LogQL pipeline stage: parser, or label filter "key op value"
+			n := next(1)
+			if n.kind != tokIdent {
+				continue
+			}
+			if logqlParsers[n.text] {
+				dynamicLabels = true
+				i++
+				continue
+			}
+			if op := next(2).text; !dynamicLabels && isComparison(op) {
+				refs.filters = append(refs.filters, n.text)
+				i += 2
+				continue
+			}
+			// line_format, label_format, drop, keep, ...: skip their arguments
+			i++
+			for i+1 < len(tokens) && tokens[i+1].text != "|" && tokens[i+1].text != "[" && tokens[i+1].text != ")" {
+				i++
+			}
+		}
+	}
+	return refs
+}
+
+// parseMatchers reads `name op "value", ...}` and returns the label names and the index of "}"
+func parseMatchers(tokens []token, i int) ([]string, int) {
+	var labels []string
+	for ; i < len(tokens) && tokens[i].text != "}"; i++ {
+		if tokens[i].kind == tokIdent && i+1 < len(tokens) && isComparison(tokens[i+1].text) {
+			labels = append(labels, tokens[i].text)
+		}
+	}
+	return labels, i
+}
+
+// parseLabelList reads `a, b)` and returns the labels and the index of ")"
+func parseLabelList(tokens []token, i int) ([]string, int) {
+	var labels []string
+	for ; i < len(tokens) && tokens[i].text != ")"; i++ {
+		if tokens[i].kind == tokIdent {
+			labels = append(labels, tokens[i].text)
+		}
+	}
+	return labels, i
+}
+
+func isComparison(op string) bool {
+	switch op {
+	case "=", "!=", "=~", "!~", ">", "<", ">=", "<=", "==":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package loadshed
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+// Defaults of ConfigFromEnv
+const (
+	DefaultMinLimit         = 5
+	DefaultInitialLimit     = 20
+	DefaultLatencyTolerance = 2.0
+)
+
+// Config bounds the adaptive limit of a Limiter
+type Config struct {
+	// InitialLimit is the limit before any request has been measured
+	InitialLimit int
+	// MinLimit and MaxLimit bound the limit. A zero MaxLimit turns load
+	// shedding off.
+	MinLimit int
+	MaxLimit int
+	// LatencyTolerance is how many times the no-load latency a request may
+	// take before the limit shrinks
+	LatencyTolerance float64
+}
+
+// Enabled reports whether requests are limited
+func (c Config) Enabled() bool {
+	return c.MaxLimit > 0
+}
+
+// Validate checks that the limits are ordered and the tolerance is above one
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	var errs []error
+	if c.MinLimit < 1 || c.MinLimit > c.MaxLimit {
+		errs = append(errs, fmt.Errorf("min limit must be between 1 and the max limit %d, got %d", c.MaxLimit, c.MinLimit))
+	}
+	if c.InitialLimit < c.MinLimit || c.InitialLimit > c.MaxLimit {
+		errs = append(errs, fmt.Errorf("initial limit must be between the min and max limits, got %d", c.InitialLimit))
+	}
+	if !(c.LatencyTolerance > 1) || math.IsInf(c.LatencyTolerance, 0) {
+		errs = append(errs, fmt.Errorf("latency tolerance must be above 1, got %v", c.LatencyTolerance))
+	}
+	return errors.Join(errs...)
+}
+
+// ConfigFromEnv reads CONCURRENCY_LIMIT_MAX, which turns load shedding on,
+// CONCURRENCY_LIMIT_MIN (default 5), CONCURRENCY_LIMIT_INITIAL (default 20,
+// within the bounds) and CONCURRENCY_LIMIT_LATENCY_TOLERANCE (default 2)
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{MinLimit: DefaultMinLimit, LatencyTolerance: DefaultLatencyTolerance}
+	var errs []error
+	for _, env := range []struct {
+		name  string
+		value *int
+	}{
+		{"CONCURRENCY_LIMIT_MAX", &cfg.MaxLimit},
+		{"CONCURRENCY_LIMIT_MIN", &cfg.MinLimit},
+		{"CONCURRENCY_LIMIT_INITIAL", &cfg.InitialLimit},
+	} {
+		if value := os.Getenv(env.name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid limit %q", env.name, value))
+				continue
+			}
+			*env.value = n
+		}
+	}
+	if value := os.Getenv("CONCURRENCY_LIMIT_LATENCY_TOLERANCE"); value != "" {
+		tolerance, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("CONCURRENCY_LIMIT_LATENCY_TOLERANCE: invalid tolerance %q", value))
+		}
+		cfg.LatencyTolerance = tolerance
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, err
+	}
+	if !cfg.Enabled() {
+		return Config{}, nil
+	}
+	if cfg.InitialLimit == 0 {
+		cfg.InitialLimit = min(max(DefaultInitialLimit, cfg.MinLimit), cfg.MaxLimit)
+	}
+	return cfg, cfg.Validate()
+}
@@ -0,0 +1,152 @@
+// Package loadshed limits the requests a server handles at once. The limit
+// adapts to the observed latency (AIMD): it grows while latency stays near the
+// no-load latency and shrinks when requests start queuing, so excess requests
+// are shed at once instead of slowing every request down.
+package loadshed
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Priority is the class of a request. Critical requests are never shed; low
+// priority ones are shed first.
+type Priority int
+
+const (
+	PriorityNormal Priority = iota
+	PriorityLow
+	PriorityCritical
+)
+
+// String is the priority in the metric and span attributes
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityCritical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+// lowPriorityShare is the share of the limit low priority requests may fill,
+// leaving the rest to normal ones
+const lowPriorityShare = 0.8
+
+// backoff is the factor the limit shrinks by when latency rises
+const backoff = 0.9
+
+// congestionSlack is the latency above the no-load latency that is always
+// tolerated, so fast handlers don't shrink the limit over scheduling noise
+const congestionSlack = 5 * time.Millisecond
+
+// baselineWindow is the number of samples after which the no-load latency is
+// re-measured, so it follows lasting changes (a slower dependency, a new node)
+const baselineWindow = 500
+
+// Stats is a snapshot of a Limiter
+type Stats struct {
+	Limit    int
+	InFlight int
+	Shed     int64
+}
+
+// Limiter admits requests while fewer than its limit are in flight
+type Limiter struct {
+	mu  sync.Mutex
+	cfg Config
+	// limit is fractional so it can grow by 1/limit per sample
+	limit    float64
+	inFlight int
+	shed     int64
+	// baseline is the lowest latency of the previous window, windowMin that of
+	// the current one
+	baseline     time.Duration
+	windowMin    time.Duration
+	samples      int
+	lastDecrease time.Time
+	// now is the clock, replaced in tests
+	now func() time.Time
+}
+
+// New creates a limiter for an already validated config
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:   cfg,
+		limit: float64(cfg.InitialLimit),
+		now:   time.Now,
+	}
+}
+
+// Stats returns the current limit, the requests in flight and the requests
+// shed so far
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Stats{Limit: int(l.limit), InFlight: l.inFlight, Shed: l.shed}
+}
+
+// Acquire admits a request of priority p, or reports false when it must be
+// shed. The returned func must be called once the request is done; its
+// latency then adjusts the limit. Critical requests are admitted without
+// counting against the limit.
+func (l *Limiter) Acquire(p Priority) (release func(), ok bool) {
+	if p == PriorityCritical {
+		return func() {}, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	capacity := l.limit
+	if p == PriorityLow {
+		capacity *= lowPriorityShare
+	}
+	if float64(l.inFlight) >= math.Floor(capacity) {
+		l.shed++
+		return nil, false
+	}
+	l.inFlight++
+	start := l.now()
+	var once sync.Once
+	return func() {
+		once.Do(func() { l.release(start) })
+	}, true
+}
+
+// release ends a request started at start and adjusts the limit: it shrinks
+// when the latency exceeds the tolerated latency, at most once per latency so
+// a burst of slow requests counts once, and grows by one per limit requests
+// while at least half of it is in use
+func (l *Limiter) release(start time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	latency := now.Sub(start)
+	inUse := float64(l.inFlight)
+	l.inFlight--
+
+	l.samples++
+	if l.windowMin == 0 || latency < l.windowMin {
+		l.windowMin = latency
+	}
+	if l.baseline == 0 || l.windowMin < l.baseline {
+		l.baseline = l.windowMin
+	}
+	if l.samples%baselineWindow == 0 {
+		l.baseline, l.windowMin = l.windowMin, 0
+	}
+
+	tolerated := max(time.Duration(float64(l.baseline)*l.cfg.LatencyTolerance), l.baseline+congestionSlack)
+	switch {
+	case latency > tolerated:
+		if now.Sub(l.lastDecrease) >= latency {
+			l.limit = math.Max(float64(l.cfg.MinLimit), l.limit*backoff)
+			l.lastDecrease = now
+		}
+	case inUse >= l.limit/2:
+		l.limit = math.Min(float64(l.cfg.MaxLimit), l.limit+1/l.limit)
+	}
+}
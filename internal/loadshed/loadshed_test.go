@@ -0,0 +1,145 @@
+package loadshed
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestLoadShed(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "LoadShed Suite")
+}
+
+var _ = Describe("Limiter", func() {
+	var (
+		now     time.Time
+		limiter *Limiter
+	)
+
+	BeforeEach(func() {
+		now = time.Unix(1700000000, 0)
+		limiter = New(Config{InitialLimit: 10, MinLimit: 2, MaxLimit: 12, LatencyTolerance: 2})
+		limiter.now = func() time.Time { return now }
+	})
+
+	// serve runs n concurrent requests that each take latency
+	serve := func(n int, latency time.Duration) {
+		releases := make([]func(), 0, n)
+		for range n {
+			release, ok := limiter.Acquire(PriorityNormal)
+			Expect(ok).To(BeTrue())
+			releases = append(releases, release)
+		}
+		now = now.Add(latency)
+		for _, release := range releases {
+			release()
+		}
+	}
+
+	It("should shed requests beyond the limit by priority", func() {
+		var releases []func()
+		for range 8 {
+			release, ok := limiter.Acquire(PriorityNormal)
+			Expect(ok).To(BeTrue())
+			releases = append(releases, release)
+		}
+		_, ok := limiter.Acquire(PriorityLow)
+		Expect(ok).To(BeFalse())
+		for range 2 {
+			release, ok := limiter.Acquire(PriorityNormal)
+			Expect(ok).To(BeTrue())
+			releases = append(releases, release)
+		}
+		_, ok = limiter.Acquire(PriorityNormal)
+		Expect(ok).To(BeFalse())
+		_, ok = limiter.Acquire(PriorityCritical)
+		Expect(ok).To(BeTrue())
+		Expect(limiter.Stats()).To(Equal(Stats{Limit: 10, InFlight: 10, Shed: 2}))
+
+		releases[0]()
+		releases[0]()
+		Expect(limiter.Stats().InFlight).To(Equal(9))
+	})
+
+	It("should grow the limit while latency stays low and the limit is in use", func() {
+		for range 20 {
+			serve(10, 10*time.Millisecond)
+		}
+		Expect(limiter.Stats().Limit).To(Equal(12))
+
+		// Without load the limit stays
+		limiter.limit = 10
+		serve(1, 10*time.Millisecond)
+		Expect(limiter.limit).To(Equal(10.0))
+	})
+
+	It("should shrink the limit once per latency when latency rises", func() {
+		serve(1, 10*time.Millisecond)
+		serve(8, 50*time.Millisecond)
+		Expect(limiter.limit).To(BeNumerically("~", 9, 0.001))
+		serve(8, 50*time.Millisecond)
+		Expect(limiter.limit).To(BeNumerically("~", 8.1, 0.001))
+
+		for range 30 {
+			serve(2, time.Second)
+		}
+		Expect(limiter.Stats().Limit).To(Equal(2))
+	})
+
+	It("should tolerate small latency changes of fast requests", func() {
+		serve(1, 100*time.Microsecond)
+		serve(6, 2*time.Millisecond)
+		Expect(limiter.limit).To(BeNumerically(">", 10))
+	})
+
+	It("should re-measure the no-load latency", func() {
+		serve(1, 10*time.Millisecond)
+		for range baselineWindow * 2 {
+			serve(1, 100*time.Millisecond)
+		}
+		Expect(limiter.baseline).To(Equal(100 * time.Millisecond))
+	})
+})
+
+var _ = Describe("Config", func() {
+	setenv := func(name, value string) {
+		os.Setenv(name, value)
+		DeferCleanup(os.Unsetenv, name)
+	}
+
+	It("should be off without a max limit", func() {
+		cfg, err := ConfigFromEnv()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Enabled()).To(BeFalse())
+	})
+
+	It("should read the environment with defaults", func() {
+		setenv("CONCURRENCY_LIMIT_MAX", "200")
+		cfg, err := ConfigFromEnv()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg).To(Equal(Config{InitialLimit: 20, MinLimit: 5, MaxLimit: 200, LatencyTolerance: 2}))
+
+		setenv("CONCURRENCY_LIMIT_MAX", "10")
+		setenv("CONCURRENCY_LIMIT_LATENCY_TOLERANCE", "1.5")
+		cfg, err = ConfigFromEnv()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg).To(Equal(Config{InitialLimit: 10, MinLimit: 5, MaxLimit: 10, LatencyTolerance: 1.5}))
+	})
+
+	It("should reject invalid settings", func() {
+		setenv("CONCURRENCY_LIMIT_MAX", "lots")
+		_, err := ConfigFromEnv()
+		Expect(err).To(MatchError(ContainSubstring("CONCURRENCY_LIMIT_MAX")))
+
+		setenv("CONCURRENCY_LIMIT_MAX", "10")
+		setenv("CONCURRENCY_LIMIT_MIN", "20")
+		setenv("CONCURRENCY_LIMIT_LATENCY_TOLERANCE", "0.5")
+		_, err = ConfigFromEnv()
+		Expect(err).To(MatchError(ContainSubstring("min limit")))
+		Expect(err).To(MatchError(ContainSubstring("latency tolerance must be above 1")))
+	})
+})
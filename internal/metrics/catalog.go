@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// InstrumentKind is the kind of an OpenTelemetry instrument as seen by Prometheus
+type InstrumentKind string
+
+const (
+	KindCounter   InstrumentKind = "counter"
+	KindGauge     InstrumentKind = "gauge"
+	KindHistogram InstrumentKind = "histogram"
+)
+
+// SLO gauge names. They are registered by the slo package but listed here so the
+// catalog covers every metric the app emits.
+const (
+	SLOErrorBudgetRemainingName = "slo_error_budget_remaining"
+	SLOBurnRateName             = "slo_burn_rate"
+	SLOTargetName               = "slo_target"
+)
+
+// LogRecordsSuppressedName counts the log records dropped by the telemetry
+// package's sampler (see telemetry.SuppressedLogCounts)
+const LogRecordsSuppressedName = "log_records_suppressed_total"
+
+// Components owning the instruments
+const (
+	ComponentServer   = "server"
+	ComponentBusiness = "business"
+	ComponentSLO      = "slo"
+	ComponentLogging  = "logging"
+)
+
+// Instrument describes one metric the app emits
+type Instrument struct {
+	Name string         `json:"name"`
+	Kind InstrumentKind `json:"kind"`
+	// Unit is the UCUM unit passed to metric.WithUnit. Dimensionless instruments use
+	// a {annotation} unit: the Prometheus exporter would append _ratio for "1".
+	Unit        string `json:"unit"`
+	Description string `json:"description"`
+	// Attributes are the only attribute (label) keys the instrument is recorded with
+	Attributes []string `json:"attributes"`
+	// Component is the part of the app that records the instrument
+	Component string `json:"component"`
+}
+
+// catalog is the single definition of every instrument. Initialize (and the slo
+// package) take names, units and descriptions from here when registering.
+var catalog = []Instrument{
+	{Name: RequestsTotalName, Kind: KindCounter, Unit: "{request}", Component: ComponentServer,
+		Description: "Total number of HTTP requests"},
+	{Name: RequestsByMethodTotalName, Kind: KindCounter, Unit: "{request}", Component: ComponentServer,
+		Description: "Total number of HTTP requests by method", Attributes: []string{"method", "status"}},
+	{Name: PanicsTotalName, Kind: KindCounter, Unit: "{panic}", Component: ComponentServer,
+		Description: "Handler panics recovered by the HTTP server", Attributes: []string{"route"}},
+	{Name: RateLimitedTotalName, Kind: KindCounter, Unit: "{request}", Component: ComponentServer,
+		Description: "HTTP requests rejected by rate limiting", Attributes: []string{"route", "scope"}},
+	{Name: RequestsShedTotalName, Kind: KindCounter, Unit: "{request}", Component: ComponentServer,
+		Description: "HTTP requests shed by the adaptive concurrency limiter", Attributes: []string{"route", "priority"}},
+	{Name: ConcurrencyLimitName, Kind: KindGauge, Unit: "{request}", Component: ComponentServer,
+		Description: "Current adaptive limit of concurrent HTTP requests"},
+	{Name: RequestsInFlightName, Kind: KindGauge, Unit: "{request}", Component: ComponentServer,
+		Description: "HTTP requests in flight against the concurrency limit"},
+	{Name: RequestDurationName, Kind: KindHistogram, Unit: "s", Component: ComponentServer,
+		Description: "HTTP request duration in seconds"},
+	{Name: ResponseSizeName, Kind: KindHistogram, Unit: "By", Component: ComponentServer,
+		Description: "HTTP response size in bytes"},
+	{Name: ActiveConnectionsName, Kind: KindGauge, Unit: "{connection}", Component: ComponentServer,
+		Description: "Current number of active HTTP connections"},
+	{Name: BusinessMetricValueName, Kind: KindGauge, Unit: "{value}", Component: ComponentBusiness,
+		Description: "A custom business metric value", Attributes: []string{"type"}},
+	{Name: SLOErrorBudgetRemainingName, Kind: KindGauge, Unit: "{ratio}", Component: ComponentSLO,
+		Description: "Fraction of the SLO error budget remaining over the SLO window", Attributes: []string{"slo"}},
+	{Name: SLOBurnRateName, Kind: KindGauge, Unit: "{burn_rate}", Component: ComponentSLO,
+		Description: "Rate at which the SLO error budget is consumed over a window", Attributes: []string{"slo", "window"}},
+	{Name: SLOTargetName, Kind: KindGauge, Unit: "{ratio}", Component: ComponentSLO,
+		Description: "Configured SLO target ratio", Attributes: []string{"slo"}},
+	{Name: LogRecordsSuppressedName, Kind: KindCounter, Unit: "{record}", Component: ComponentLogging,
+		Description: "Log records dropped by log sampling", Attributes: []string{"level"}},
+}
+
+// Catalog lists every metric the app emits. Generators (rules, dashboards) and the
+// dashboard linter use it as the source of truth.
+func Catalog() []Instrument {
+	instruments := make([]Instrument, len(catalog))
+	for i, inst := range catalog {
+		inst.Attributes = append([]string{}, inst.Attributes...)
+		instruments[i] = inst
+	}
+	return instruments
+}
+
+// Lookup returns the catalog entry of the named instrument
+func Lookup(name string) (Instrument, error) {
+	for _, inst := range catalog {
+		if inst.Name == name {
+			return inst, nil
+		}
+	}
+	return Instrument{}, fmt.Errorf("instrument %q is not in the metrics catalog", name)
+}
+
+// WriteCatalogMarkdown renders the catalog as a Markdown table
+func WriteCatalogMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("| Name | Kind | Unit | Attributes | Component | Description |\n")
+	b.WriteString("|------|------|------|------------|-----------|-------------|\n")
+	for _, inst := range catalog {
+		attrs := "-"
+		if len(inst.Attributes) > 0 {
+			attrs = "`" + strings.Join(inst.Attributes, "`, `") + "`"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | `%s` | %s | %s | %s |\n",
+			inst.Name, inst.Kind, inst.Unit, attrs, inst.Component, inst.Description)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// SeriesNames returns the Prometheus series names the instrument is exported as
+func (i Instrument) SeriesNames() []string {
+	if i.Kind == KindHistogram {
+		return []string{i.Name + "_bucket", i.Name + "_sum", i.Name + "_count"}
+	}
+	return []string{i.Name}
+}
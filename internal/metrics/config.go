@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// Temporality preference values accepted by
+// OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE (OTel spec names)
+const (
+	TemporalityCumulative = "cumulative"
+	TemporalityDelta      = "delta"
+	TemporalityLowMemory  = "lowmemory"
+)
+
+// Exporter values accepted by OTEL_METRICS_EXPORTER
+const (
+	ExporterOTLP                  = "otlp"
+	ExporterPrometheusRemoteWrite = "prometheusremotewrite"
+)
+
+// ExportConfig controls how often and how metrics are pushed to the exporter
+type ExportConfig struct {
+	// Exporter is ExporterOTLP (to the collector) or ExporterPrometheusRemoteWrite
+	Exporter string
+	// Interval between two consecutive exports
+	Interval time.Duration
+	// Timeout for a single export
+	Timeout time.Duration
+	// Temporality is one of TemporalityCumulative, TemporalityDelta or TemporalityLowMemory
+	Temporality string
+}
+
+// DefaultExportConfig returns the export settings used when nothing is configured
+func DefaultExportConfig() ExportConfig {
+	return ExportConfig{
+		Exporter:    ExporterOTLP,
+		Interval:    30 * time.Second,
+		Timeout:     30 * time.Second,
+		Temporality: TemporalityCumulative,
+	}
+}
+
+// LoadExportConfig reads the export settings from the standard OTel environment variables:
+//   - OTEL_METRICS_EXPORTER: otlp or prometheusremotewrite
+//   - OTEL_METRIC_EXPORT_INTERVAL: milliseconds (or a Go duration such as "5s")
+//   - OTEL_METRIC_EXPORT_TIMEOUT: milliseconds (or a Go duration such as "5s")
+//   - OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE: cumulative, delta or lowmemory
+func LoadExportConfig() (ExportConfig, error) {
+	cfg := DefaultExportConfig()
+
+	var err error
+	if cfg.Interval, err = parseDurationEnv("OTEL_METRIC_EXPORT_INTERVAL", cfg.Interval); err != nil {
+		return cfg, err
+	}
+	if cfg.Timeout, err = parseDurationEnv("OTEL_METRIC_EXPORT_TIMEOUT", cfg.Timeout); err != nil {
+		return cfg, err
+	}
+
+	cfg.Exporter = strings.ToLower(getEnv("OTEL_METRICS_EXPORTER", cfg.Exporter))
+	if cfg.Exporter != ExporterOTLP && cfg.Exporter != ExporterPrometheusRemoteWrite {
+		return cfg, fmt.Errorf("unsupported OTEL_METRICS_EXPORTER %q (want %s or %s)",
+			cfg.Exporter, ExporterOTLP, ExporterPrometheusRemoteWrite)
+	}
+
+	cfg.Temporality = strings.ToLower(getEnv("OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE", cfg.Temporality))
+	if _, err := temporalitySelector(cfg.Temporality); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// temporalitySelector maps a temporality preference to the SDK selector
+func temporalitySelector(preference string) (sdkmetric.TemporalitySelector, error) {
+	switch preference {
+	case TemporalityCumulative:
+		return sdkmetric.CumulativeTemporalitySelector, nil
+	case TemporalityDelta:
+		return sdkmetric.DeltaTemporalitySelector, nil
+	case TemporalityLowMemory:
+		return sdkmetric.LowMemoryTemporalitySelector, nil
+	default:
+		return nil, fmt.Errorf("unsupported temporality preference %q (want %s, %s or %s)",
+			preference, TemporalityCumulative, TemporalityDelta, TemporalityLowMemory)
+	}
+}
+
+// parseDurationEnv parses a duration given either as plain milliseconds (as the
+// OTel spec defines it) or as a Go duration string
+func parseDurationEnv(key string, defaultValue time.Duration) (time.Duration, error) {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return defaultValue, nil
+	}
+
+	var d time.Duration
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		d = time.Duration(ms) * time.Millisecond
+	} else if d, err = time.ParseDuration(raw); err != nil {
+		return defaultValue, fmt.Errorf("invalid %s %q: %w", key, raw, err)
+	}
+
+	if d <= 0 {
+		return defaultValue, fmt.Errorf("invalid %s %q: must be positive", key, raw)
+	}
+	return d, nil
+}
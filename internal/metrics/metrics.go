@@ -3,11 +3,13 @@ package metrics
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"sync"
 	"time"
 
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/remotewrite"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
@@ -17,6 +19,22 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
 )
 
+// Metric names emitted by this package. They are shared with the generators
+// (e.g. PrometheusRule) so renaming a metric updates everything that queries it.
+const (
+	RequestsTotalName         = "http_requests_total"
+	RequestsByMethodTotalName = "http_requests_by_method_total"
+	RequestDurationName       = "http_request_duration_seconds"
+	ResponseSizeName          = "http_response_size_bytes"
+	ActiveConnectionsName     = "http_active_connections"
+	BusinessMetricValueName   = "business_metric_value"
+	PanicsTotalName           = "http_server_panics_total"
+	RateLimitedTotalName      = "http_requests_rate_limited_total"
+	ConcurrencyLimitName      = "http_server_concurrency_limit"
+	RequestsInFlightName      = "http_server_requests_in_flight"
+	RequestsShedTotalName     = "http_server_requests_shed_total"
+)
+
 var (
 	// MeterProvider holds the global meter provider
 	meterProvider *sdkmetric.MeterProvider
@@ -41,6 +59,20 @@ var (
 	// CounterVec: Requests by method and status
 	RequestCounterVec metric.Int64Counter
 
+	// Counter: Handler panics recovered by the server, by route
+	PanicCounter metric.Int64Counter
+
+	// Counter: Requests rejected by rate limiting, by route and bucket scope
+	RateLimitedCounter metric.Int64Counter
+
+	// Counter: Requests shed by the concurrency limiter, by route and priority
+	ShedCounter metric.Int64Counter
+
+	// Gauges: adaptive concurrency limit and requests counted against it. Nil
+	// (not observed) until load shedding reports them.
+	concurrencyLimitValue *float64Value
+	requestsInFlightValue *float64Value
+
 	// GaugeVec: Custom business metric values
 	businessMetricValues map[string]*float64Value
 )
@@ -82,18 +114,22 @@ func Initialize() error {
 		return fmt.Errorf("failed to create resource: %w", err)
 	}
 
-	// Create OTLP metric exporter
-	metricExporter, err := otlpmetricgrpc.New(ctx,
-		otlpmetricgrpc.WithEndpoint(otlpEndpoint),
-		otlpmetricgrpc.WithInsecure(), // For simplicity, use insecure in local/dev
-	)
+	// Load export interval, timeout and temporality preference
+	exportConfig, err := LoadExportConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load metric export config: %w", err)
+	}
+
+	// Create the OTLP (or remote-write) metric exporter
+	metricExporter, target, err := newExporter(ctx, exportConfig, otlpEndpoint)
 	if err != nil {
 		return fmt.Errorf("failed to create metric exporter: %w", err)
 	}
 
 	// Create meter provider with periodic reader
 	reader := sdkmetric.NewPeriodicReader(metricExporter,
-		sdkmetric.WithInterval(30*time.Second),
+		sdkmetric.WithInterval(exportConfig.Interval),
+		sdkmetric.WithTimeout(exportConfig.Timeout),
 	)
 
 	meterProvider = sdkmetric.NewMeterProvider(
@@ -110,79 +146,54 @@ func Initialize() error {
 	businessMetricValues = make(map[string]*float64Value)
 	activeConnectionsValue = &float64Value{value: 0.0}
 
-	// Create RequestCounter
-	RequestCounter, err = meter.Int64Counter(
-		"http_requests_total",
-		metric.WithDescription("Total number of HTTP requests"),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create RequestCounter: %w", err)
+	if err := registerInstruments(meter); err != nil {
+		return err
 	}
 
-	// Create RequestCounterVec (counter with labels)
-	RequestCounterVec, err = meter.Int64Counter(
-		"http_requests_by_method_total",
-		metric.WithDescription("Total number of HTTP requests by method"),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create RequestCounterVec: %w", err)
-	}
+	// Initialize with default values
+	UpdateActiveConnections(0)
+	UpdateBusinessMetric("demo", 42.0)
 
-	// Create RequestDuration histogram
-	RequestDuration, err = meter.Float64Histogram(
-		"http_request_duration_seconds",
-		metric.WithDescription("HTTP request duration in seconds"),
+	telemetry.ScopedLogger(telemetry.ScopeMetrics).Info("OpenTelemetry metrics initialized",
+		slog.String("exporter", exportConfig.Exporter),
+		slog.String("endpoint", target),
+		slog.Duration("interval", exportConfig.Interval),
+		slog.Duration("timeout", exportConfig.Timeout),
+		slog.String("temporality", exportConfig.Temporality),
 	)
-	if err != nil {
-		return fmt.Errorf("failed to create RequestDuration: %w", err)
-	}
+	return nil
+}
 
-	// Create ResponseSize histogram (replacing Summary)
-	ResponseSize, err = meter.Int64Histogram(
-		"http_response_size_bytes",
-		metric.WithDescription("HTTP response size in bytes"),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create ResponseSize: %w", err)
+// newExporter creates the exporter selected by OTEL_METRICS_EXPORTER and returns
+// it together with the endpoint it pushes to
+func newExporter(ctx context.Context, cfg ExportConfig, otlpEndpoint string) (sdkmetric.Exporter, string, error) {
+	if cfg.Exporter == ExporterPrometheusRemoteWrite {
+		rwConfig, err := remotewrite.LoadConfig()
+		if err != nil {
+			return nil, "", err
+		}
+		exporter, err := remotewrite.New(rwConfig)
+		return exporter, rwConfig.URL, err
 	}
 
-	// Create ActiveConnections observable gauge
-	_, err = meter.Float64ObservableGauge(
-		"http_active_connections",
-		metric.WithDescription("Current number of active HTTP connections"),
-		metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
-			o.Observe(activeConnectionsValue.get())
-			return nil
-		}),
-	)
+	selector, err := temporalitySelector(cfg.Temporality)
 	if err != nil {
-		return fmt.Errorf("failed to create ActiveConnections: %w", err)
+		return nil, "", err
 	}
-
-	// Register observable callback for business metrics
-	_, err = meter.Float64ObservableGauge(
-		"business_metric_value",
-		metric.WithDescription("A custom business metric value"),
-		metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
-			mu.RLock()
-			defer mu.RUnlock()
-			for metricType, val := range businessMetricValues {
-				o.Observe(val.get(), metric.WithAttributes(attribute.String("type", metricType)))
-			}
-			return nil
-		}),
+	exporter, err := otlpmetricgrpc.New(ctx,
+		otlpmetricgrpc.WithEndpoint(otlpEndpoint),
+		otlpmetricgrpc.WithInsecure(), // For simplicity, use insecure in local/dev
+		otlpmetricgrpc.WithTemporalitySelector(selector),
 	)
-	if err != nil {
-		return fmt.Errorf("failed to create BusinessMetric: %w", err)
-	}
-
-	// Initialize with default values
-	UpdateActiveConnections(0)
-	UpdateBusinessMetric("demo", 42.0)
+	return exporter, otlpEndpoint, err
+}
 
-	// Note: Use log.Printf here since telemetry logger may not be initialized yet
-	// This is called during application startup before telemetry logger is ready
-	log.Printf("OpenTelemetry metrics initialized with endpoint: %s", otlpEndpoint)
+// ForceFlush exports all metrics collected so far without waiting for the
+// next export interval. It is safe to call before Initialize.
+func ForceFlush(ctx context.Context) error {
+	if meterProvider != nil {
+		return meterProvider.ForceFlush(ctx)
+	}
 	return nil
 }
 
@@ -194,6 +205,17 @@ func Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// UseMeterProvider registers this package's instruments on mp instead of the
+// meter provider of Initialize, e.g. to read them in tests. Gauge values are
+// kept; the export pipeline of Initialize is left alone.
+func UseMeterProvider(mp metric.MeterProvider) error {
+	if activeConnectionsValue == nil {
+		activeConnectionsValue = &float64Value{}
+	}
+	meter = mp.Meter("dm-nkp-gitops-custom-app/metrics")
+	return registerInstruments(meter)
+}
+
 // IncrementRequestCounter increments the request counter
 func IncrementRequestCounter() {
 	if RequestCounter != nil {
@@ -212,6 +234,47 @@ func IncrementRequestCounterVec(method, status string) {
 	}
 }
 
+// IncrementPanicCounter counts a handler panic recovered on route
+func IncrementPanicCounter(route string) {
+	if PanicCounter != nil {
+		PanicCounter.Add(context.Background(), 1, metric.WithAttributes(attribute.String("route", route)))
+	}
+}
+
+// IncrementRateLimitedCounter counts a request to route rejected by the rate
+// limit of scope (global, ip or key)
+func IncrementRateLimitedCounter(route, scope string) {
+	if RateLimitedCounter != nil {
+		RateLimitedCounter.Add(context.Background(), 1, metric.WithAttributes(
+			attribute.String("route", route),
+			attribute.String("scope", scope),
+		))
+	}
+}
+
+// IncrementShedCounter counts a request to route shed by the concurrency limiter
+func IncrementShedCounter(route, priority string) {
+	if ShedCounter != nil {
+		ShedCounter.Add(context.Background(), 1, metric.WithAttributes(
+			attribute.String("route", route),
+			attribute.String("priority", priority),
+		))
+	}
+}
+
+// UpdateConcurrency records the current concurrency limit and the requests in
+// flight against it
+func UpdateConcurrency(limit, inFlight float64) {
+	mu.Lock()
+	defer mu.Unlock()
+	if concurrencyLimitValue == nil {
+		concurrencyLimitValue = &float64Value{}
+		requestsInFlightValue = &float64Value{}
+	}
+	concurrencyLimitValue.set(limit)
+	requestsInFlightValue.set(inFlight)
+}
+
 // UpdateActiveConnections updates the active connections gauge
 func UpdateActiveConnections(count float64) {
 	if activeConnectionsValue != nil {
@@ -250,6 +313,203 @@ func UpdateBusinessMetric(metricType string, value float64) {
 	}
 }
 
+// registerInstruments creates every instrument of this package on the meter,
+// taking names, units and descriptions from the catalog
+func registerInstruments(meter metric.Meter) error {
+	// Create RequestCounter
+	inst, err := catalogInstrument(RequestsTotalName, KindCounter)
+	if err != nil {
+		return err
+	}
+	RequestCounter, err = meter.Int64Counter(
+		inst.Name,
+		metric.WithDescription(inst.Description),
+		metric.WithUnit(inst.Unit),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create RequestCounter: %w", err)
+	}
+
+	// Create RequestCounterVec (counter with labels)
+	if inst, err = catalogInstrument(RequestsByMethodTotalName, KindCounter); err != nil {
+		return err
+	}
+	RequestCounterVec, err = meter.Int64Counter(
+		inst.Name,
+		metric.WithDescription(inst.Description),
+		metric.WithUnit(inst.Unit),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create RequestCounterVec: %w", err)
+	}
+
+	// Create PanicCounter
+	if inst, err = catalogInstrument(PanicsTotalName, KindCounter); err != nil {
+		return err
+	}
+	PanicCounter, err = meter.Int64Counter(
+		inst.Name,
+		metric.WithDescription(inst.Description),
+		metric.WithUnit(inst.Unit),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create PanicCounter: %w", err)
+	}
+
+	// Create RateLimitedCounter
+	if inst, err = catalogInstrument(RateLimitedTotalName, KindCounter); err != nil {
+		return err
+	}
+	RateLimitedCounter, err = meter.Int64Counter(
+		inst.Name,
+		metric.WithDescription(inst.Description),
+		metric.WithUnit(inst.Unit),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create RateLimitedCounter: %w", err)
+	}
+
+	// Create ShedCounter
+	if inst, err = catalogInstrument(RequestsShedTotalName, KindCounter); err != nil {
+		return err
+	}
+	ShedCounter, err = meter.Int64Counter(
+		inst.Name,
+		metric.WithDescription(inst.Description),
+		metric.WithUnit(inst.Unit),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create ShedCounter: %w", err)
+	}
+
+	// Create RequestDuration histogram
+	if inst, err = catalogInstrument(RequestDurationName, KindHistogram); err != nil {
+		return err
+	}
+	RequestDuration, err = meter.Float64Histogram(
+		inst.Name,
+		metric.WithDescription(inst.Description),
+		metric.WithUnit(inst.Unit),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create RequestDuration: %w", err)
+	}
+
+	// Create ResponseSize histogram (replacing Summary)
+	if inst, err = catalogInstrument(ResponseSizeName, KindHistogram); err != nil {
+		return err
+	}
+	ResponseSize, err = meter.Int64Histogram(
+		inst.Name,
+		metric.WithDescription(inst.Description),
+		metric.WithUnit(inst.Unit),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create ResponseSize: %w", err)
+	}
+
+	// Create ActiveConnections observable gauge
+	if inst, err = catalogInstrument(ActiveConnectionsName, KindGauge); err != nil {
+		return err
+	}
+	_, err = meter.Float64ObservableGauge(
+		inst.Name,
+		metric.WithDescription(inst.Description),
+		metric.WithUnit(inst.Unit),
+		metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
+			o.Observe(activeConnectionsValue.get())
+			return nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create ActiveConnections: %w", err)
+	}
+
+	// Create the load shedding gauges, observed once load shedding reports them
+	for _, gauge := range []struct {
+		name  string
+		value **float64Value
+	}{
+		{ConcurrencyLimitName, &concurrencyLimitValue},
+		{RequestsInFlightName, &requestsInFlightValue},
+	} {
+		if inst, err = catalogInstrument(gauge.name, KindGauge); err != nil {
+			return err
+		}
+		value := gauge.value
+		_, err = meter.Float64ObservableGauge(
+			inst.Name,
+			metric.WithDescription(inst.Description),
+			metric.WithUnit(inst.Unit),
+			metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
+				mu.RLock()
+				defer mu.RUnlock()
+				if *value != nil {
+					o.Observe((*value).get())
+				}
+				return nil
+			}),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", gauge.name, err)
+		}
+	}
+
+	// Register observable callback for business metrics
+	if inst, err = catalogInstrument(BusinessMetricValueName, KindGauge); err != nil {
+		return err
+	}
+	_, err = meter.Float64ObservableGauge(
+		inst.Name,
+		metric.WithDescription(inst.Description),
+		metric.WithUnit(inst.Unit),
+		metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
+			mu.RLock()
+			defer mu.RUnlock()
+			for metricType, val := range businessMetricValues {
+				o.Observe(val.get(), metric.WithAttributes(attribute.String("type", metricType)))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create BusinessMetric: %w", err)
+	}
+
+	// Records dropped by the log sampler, counted by the telemetry package
+	if inst, err = catalogInstrument(LogRecordsSuppressedName, KindCounter); err != nil {
+		return err
+	}
+	_, err = meter.Int64ObservableCounter(
+		inst.Name,
+		metric.WithDescription(inst.Description),
+		metric.WithUnit(inst.Unit),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			for level, n := range telemetry.SuppressedLogCounts() {
+				o.Observe(n, metric.WithAttributes(attribute.String("level", level)))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create LogRecordsSuppressed: %w", err)
+	}
+	return nil
+}
+
+// catalogInstrument returns the catalog entry an instrument is registered from,
+// checking that it is registered as the kind the catalog declares
+func catalogInstrument(name string, kind InstrumentKind) (Instrument, error) {
+	inst, err := Lookup(name)
+	if err != nil {
+		return Instrument{}, err
+	}
+	if inst.Kind != kind {
+		return Instrument{}, fmt.Errorf("instrument %q is a %s in the metrics catalog, not a %s", name, inst.Kind, kind)
+	}
+	return inst, nil
+}
+
 // getEnv gets environment variable or returns default
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
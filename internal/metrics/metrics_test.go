@@ -2,12 +2,17 @@ package metrics
 
 import (
 	"context"
+	"maps"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 func TestMetrics(t *testing.T) {
@@ -157,4 +162,229 @@ var _ = Describe("Metrics", func() {
 			_ = err
 		})
 	})
+
+	Describe("Export configuration", func() {
+		AfterEach(func() {
+			os.Unsetenv("OTEL_METRIC_EXPORT_INTERVAL")
+			os.Unsetenv("OTEL_METRIC_EXPORT_TIMEOUT")
+			os.Unsetenv("OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE")
+			os.Unsetenv("OTEL_METRICS_EXPORTER")
+			os.Unsetenv("PROMETHEUS_REMOTE_WRITE_URL")
+		})
+
+		It("should use defaults when nothing is configured", func() {
+			cfg, err := LoadExportConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg).To(Equal(DefaultExportConfig()))
+		})
+
+		It("should parse milliseconds and Go durations", func() {
+			os.Setenv("OTEL_METRIC_EXPORT_INTERVAL", "5000")
+			os.Setenv("OTEL_METRIC_EXPORT_TIMEOUT", "2s")
+
+			cfg, err := LoadExportConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Interval).To(Equal(5 * time.Second))
+			Expect(cfg.Timeout).To(Equal(2 * time.Second))
+		})
+
+		It("should reject invalid durations", func() {
+			os.Setenv("OTEL_METRIC_EXPORT_INTERVAL", "soon")
+			_, err := LoadExportConfig()
+			Expect(err).To(MatchError(ContainSubstring("OTEL_METRIC_EXPORT_INTERVAL")))
+
+			os.Setenv("OTEL_METRIC_EXPORT_INTERVAL", "0")
+			_, err = LoadExportConfig()
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should accept every temporality preference", func() {
+			for _, preference := range []string{"cumulative", "Delta", "lowmemory"} {
+				os.Setenv("OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE", preference)
+				cfg, err := LoadExportConfig()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cfg.Temporality).To(Equal(strings.ToLower(preference)))
+			}
+		})
+
+		It("should select delta temporality for counters and histograms", func() {
+			selector, err := temporalitySelector(TemporalityDelta)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(selector(sdkmetric.InstrumentKindCounter)).To(Equal(metricdata.DeltaTemporality))
+			Expect(selector(sdkmetric.InstrumentKindHistogram)).To(Equal(metricdata.DeltaTemporality))
+			Expect(selector(sdkmetric.InstrumentKindUpDownCounter)).To(Equal(metricdata.CumulativeTemporality))
+		})
+
+		It("should reject unknown temporality preferences", func() {
+			os.Setenv("OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE", "sometimes")
+			_, err := LoadExportConfig()
+			Expect(err).To(MatchError(ContainSubstring("unsupported temporality")))
+
+			err = Initialize()
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should select the remote-write exporter", func() {
+			os.Setenv("OTEL_METRICS_EXPORTER", "prometheusremotewrite")
+			cfg, err := LoadExportConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Exporter).To(Equal(ExporterPrometheusRemoteWrite))
+
+			// The remote-write URL is required
+			Expect(Initialize()).To(MatchError(ContainSubstring("URL is required")))
+
+			os.Setenv("PROMETHEUS_REMOTE_WRITE_URL", "http://127.0.0.1:1/api/v1/write")
+			Expect(Initialize()).To(Succeed())
+			// Nothing listens on the URL - shutdown's final export is expected to fail
+			_ = Shutdown(context.Background())
+		})
+
+		It("should reject unknown exporters", func() {
+			os.Setenv("OTEL_METRICS_EXPORTER", "console")
+			_, err := LoadExportConfig()
+			Expect(err).To(MatchError(ContainSubstring("unsupported OTEL_METRICS_EXPORTER")))
+		})
+	})
+
+	Describe("ForceFlush", func() {
+		It("should be a no-op when not initialized", func() {
+			meterProvider = nil
+			Expect(ForceFlush(context.Background())).To(Succeed())
+		})
+
+		It("should export pending measurements without waiting for the interval", func() {
+			exporter := &recordingExporter{}
+			meterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(
+				sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(time.Hour)),
+			))
+			defer func() {
+				_ = meterProvider.Shutdown(context.Background())
+				meterProvider = nil
+			}()
+
+			counter, err := meterProvider.Meter("test").Int64Counter(RequestsTotalName)
+			Expect(err).NotTo(HaveOccurred())
+			counter.Add(context.Background(), 3)
+			Expect(exporter.exported()).To(BeEmpty())
+
+			Expect(ForceFlush(context.Background())).To(Succeed())
+			Expect(exporter.exported()).To(HaveKeyWithValue(RequestsTotalName, int64(3)))
+		})
+	})
+
+	Describe("Catalog", func() {
+		It("should describe every instrument with a unit and a component", func() {
+			seen := make(map[string]bool)
+			for _, inst := range Catalog() {
+				Expect(seen).NotTo(HaveKey(inst.Name), "duplicate instrument")
+				seen[inst.Name] = true
+				Expect(inst.Kind).To(BeElementOf(KindCounter, KindGauge, KindHistogram))
+				Expect(inst.Unit).NotTo(BeEmpty(), inst.Name)
+				Expect(inst.Description).NotTo(BeEmpty(), inst.Name)
+				Expect(inst.Component).To(BeElementOf(ComponentServer, ComponentBusiness, ComponentSLO, ComponentLogging))
+			}
+		})
+
+		It("should look up instruments by name", func() {
+			inst, err := Lookup(RequestDurationName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inst.Unit).To(Equal("s"))
+
+			_, err = Lookup("http_unknown_total")
+			Expect(err).To(MatchError(ContainSubstring("not in the metrics catalog")))
+		})
+
+		It("should reject registering an instrument as another kind", func() {
+			_, err := catalogInstrument(RequestsTotalName, KindGauge)
+			Expect(err).To(MatchError(ContainSubstring("is a counter")))
+		})
+
+		It("should register instruments exactly as the catalog describes them", func() {
+			reader := sdkmetric.NewManualReader()
+			provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+			defer func() { _ = provider.Shutdown(context.Background()) }()
+
+			activeConnectionsValue = &float64Value{}
+			Expect(registerInstruments(provider.Meter("test"))).To(Succeed())
+			IncrementRequestCounter()
+			IncrementRequestCounterVec("GET", "200")
+			IncrementPanicCounter("/")
+			IncrementRateLimitedCounter("/", "ip")
+			IncrementShedCounter("/", "normal")
+			UpdateConcurrency(20, 3)
+			UpdateRequestDuration(time.Millisecond)
+			UpdateResponseSize(128)
+			UpdateBusinessMetric("demo", 1)
+
+			var rm metricdata.ResourceMetrics
+			Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+			registered := make(map[string]metricdata.Metrics)
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					registered[m.Name] = m
+				}
+			}
+
+			for _, inst := range Catalog() {
+				if inst.Component == ComponentSLO {
+					continue // registered by the slo package
+				}
+				Expect(registered).To(HaveKey(inst.Name))
+				Expect(registered[inst.Name].Unit).To(Equal(inst.Unit), inst.Name)
+				Expect(registered[inst.Name].Description).To(Equal(inst.Description), inst.Name)
+				delete(registered, inst.Name)
+			}
+			Expect(registered).To(BeEmpty(), "instruments missing from the catalog")
+		})
+
+		It("should render a Markdown table", func() {
+			var b strings.Builder
+			Expect(WriteCatalogMarkdown(&b)).To(Succeed())
+			lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+			Expect(lines).To(HaveLen(len(Catalog()) + 2))
+			Expect(lines[0]).To(HavePrefix("| Name | Kind | Unit |"))
+			Expect(b.String()).To(ContainSubstring("| `http_request_duration_seconds` | histogram | `s` | - | server |"))
+		})
+	})
 })
+
+// recordingExporter keeps the last exported value of every int64 sum
+type recordingExporter struct {
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+func (e *recordingExporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(k)
+}
+
+func (e *recordingExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+func (e *recordingExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.values == nil {
+		e.values = make(map[string]int64)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, dp := range sum.DataPoints {
+					e.values[m.Name] = dp.Value
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (e *recordingExporter) exported() map[string]int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return maps.Clone(e.values)
+}
+
+func (e *recordingExporter) ForceFlush(context.Context) error { return nil }
+func (e *recordingExporter) Shutdown(context.Context) error   { return nil }
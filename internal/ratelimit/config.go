@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultKeyHeader carries the API key (or tenant ID) requests are limited per
+const DefaultKeyHeader = "X-API-Key"
+
+// Limit is a token bucket: up to Burst requests at once, refilled at Rate
+// requests per second. A zero Rate means no limit.
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Enabled reports whether the limit applies
+func (l Limit) Enabled() bool {
+	return l.Rate > 0
+}
+
+// Limits are the buckets of a Limiter. Each API key and each client IP gets a
+// bucket of its own; the global bucket is shared by all requests.
+type Limits struct {
+	Global Limit `json:"global"`
+	PerIP  Limit `json:"per_ip"`
+	PerKey Limit `json:"per_key"`
+}
+
+// Enabled reports whether any of the limits applies
+func (l Limits) Enabled() bool {
+	return l.Global.Enabled() || l.PerIP.Enabled() || l.PerKey.Enabled()
+}
+
+// Validate checks that every enabled limit has a burst of at least one request
+func (l Limits) Validate() error {
+	var errs []error
+	for _, limit := range []struct {
+		scope string
+		Limit
+	}{{ScopeGlobal, l.Global}, {ScopeIP, l.PerIP}, {ScopeKey, l.PerKey}} {
+		switch {
+		case limit.Rate < 0 || math.IsNaN(limit.Rate) || math.IsInf(limit.Rate, 0):
+			errs = append(errs, fmt.Errorf("%s: invalid rate %v", limit.scope, limit.Rate))
+		case limit.Enabled() && limit.Burst < 1:
+			errs = append(errs, fmt.Errorf("%s: burst must be at least 1, got %d", limit.scope, limit.Burst))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Config is the rate limiting of the HTTP server (see ConfigFromEnv)
+type Config struct {
+	Limits
+	// TrustedProxies are the proxies whose X-Forwarded-For header is believed
+	// when finding the client IP (see ClientIP)
+	TrustedProxies []netip.Prefix
+	// KeyHeader carries the API key of PerKey (default DefaultKeyHeader)
+	KeyHeader string
+}
+
+// ConfigFromEnv reads RATE_LIMIT_GLOBAL, RATE_LIMIT_PER_IP and RATE_LIMIT_PER_KEY
+// ("rate:burst" in requests per second, or just "rate" for a burst of one
+// second), RATE_LIMIT_TRUSTED_PROXIES (comma-separated CIDRs or IPs) and
+// RATE_LIMIT_KEY_HEADER. Rate limiting is off when no limit is set.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{KeyHeader: os.Getenv("RATE_LIMIT_KEY_HEADER")}
+	if cfg.KeyHeader == "" {
+		cfg.KeyHeader = DefaultKeyHeader
+	}
+
+	var errs []error
+	for _, env := range []struct {
+		name  string
+		limit *Limit
+	}{
+		{"RATE_LIMIT_GLOBAL", &cfg.Global},
+		{"RATE_LIMIT_PER_IP", &cfg.PerIP},
+		{"RATE_LIMIT_PER_KEY", &cfg.PerKey},
+	} {
+		value := os.Getenv(env.name)
+		if value == "" {
+			continue
+		}
+		limit, err := ParseLimit(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", env.name, err))
+			continue
+		}
+		*env.limit = limit
+	}
+
+	for _, value := range strings.Split(os.Getenv("RATE_LIMIT_TRUSTED_PROXIES"), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		prefix, err := parsePrefix(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_TRUSTED_PROXIES: %w", err))
+			continue
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, prefix)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// ParseLimit parses "rate:burst" or "rate" (burst of one second's worth)
+func ParseLimit(s string) (Limit, error) {
+	rateValue, burstValue, hasBurst := strings.Cut(s, ":")
+	rate, err := strconv.ParseFloat(strings.TrimSpace(rateValue), 64)
+	if err != nil || rate < 0 {
+		return Limit{}, fmt.Errorf("invalid rate %q", rateValue)
+	}
+	limit := Limit{Rate: rate, Burst: int(math.Ceil(rate))}
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(strings.TrimSpace(burstValue)); err != nil {
+			return Limit{}, fmt.Errorf("invalid burst %q", burstValue)
+		}
+	}
+	return limit, nil
+}
+
+// parsePrefix parses a CIDR, or a single IP as a /32 or /128
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,262 @@
+// Package ratelimit limits requests with token buckets: one shared by all
+// requests, one per client IP and one per API key.
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bucket scopes, in the Scope of a Decision and the metric and span attributes
+const (
+	ScopeGlobal = "global"
+	ScopeIP     = "ip"
+	ScopeKey    = "key"
+)
+
+// maxBuckets bounds the per-IP and per-key buckets each. A new client beyond
+// it takes the bucket of the least recently seen one, so clients that rotate
+// their IP or key can't grow the limiter.
+const maxBuckets = 10000
+
+// sweepInterval is how often the buckets that have refilled completely are
+// dropped: they are the same as new ones
+const sweepInterval = time.Minute
+
+// Decision is the outcome of Limiter.Allow
+type Decision struct {
+	Allowed bool
+	// Scope is the bucket that rejected the request or, for allowed requests,
+	// the one with the fewest tokens left. Empty when no limit applies.
+	Scope string
+	// Limit is the burst of that bucket and Remaining the requests it still allows
+	Limit     int
+	Remaining int
+	// Reset is the time until that bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the rejecting bucket has a token again
+	RetryAfter time.Duration
+}
+
+// Limiter applies Limits, which can be changed while it runs (SetLimits)
+type Limiter struct {
+	mu     sync.Mutex
+	limits Limits
+	global *bucket
+	ips    *bucketSet
+	keys   *bucketSet
+	// now is the clock, replaced in tests
+	now func() time.Time
+}
+
+// bucket holds the tokens left at last
+type bucket struct {
+	id     string
+	tokens float64
+	last   time.Time
+}
+
+// bucketSet holds the buckets of one scope, at most maxBuckets, the most
+// recently used first
+type bucketSet struct {
+	byID      map[string]*list.Element
+	order     *list.List
+	nextSweep time.Time
+}
+
+func newBucketSet() *bucketSet {
+	return &bucketSet{byID: make(map[string]*list.Element), order: list.New()}
+}
+
+func (s *bucketSet) len() int {
+	return s.order.Len()
+}
+
+// get returns the bucket of id, creating a full one for new clients
+func (s *bucketSet) get(id string, limit Limit, now time.Time) *bucket {
+	if !now.Before(s.nextSweep) {
+		s.sweep(limit, now)
+		s.nextSweep = now.Add(sweepInterval)
+	}
+	if e, ok := s.byID[id]; ok {
+		s.order.MoveToFront(e)
+		return e.Value.(*bucket)
+	}
+	if s.order.Len() >= maxBuckets {
+		s.remove(s.order.Back())
+	}
+	b := &bucket{id: id, tokens: float64(limit.Burst), last: now}
+	s.byID[id] = s.order.PushFront(b)
+	return b
+}
+
+// sweep drops the buckets that have refilled completely
+func (s *bucketSet) sweep(limit Limit, now time.Time) {
+	for e := s.order.Front(); e != nil; {
+		next := e.Next()
+		b := e.Value.(*bucket)
+		if b.refill(limit, now); b.tokens >= float64(limit.Burst) {
+			s.remove(e)
+		}
+		e = next
+	}
+}
+
+func (s *bucketSet) remove(e *list.Element) {
+	s.order.Remove(e)
+	delete(s.byID, e.Value.(*bucket).id)
+}
+
+// New creates a limiter for already validated limits
+func New(limits Limits) *Limiter {
+	return &Limiter{
+		limits: limits,
+		ips:    newBucketSet(),
+		keys:   newBucketSet(),
+		now:    time.Now,
+	}
+}
+
+// Limits returns the limits in effect
+func (l *Limiter) Limits() Limits {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limits
+}
+
+// SetLimits replaces the limits. Clients keep the tokens they have left, up to
+// the new burst.
+func (l *Limiter) SetLimits(limits Limits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits = limits
+	return nil
+}
+
+// Allow takes a token from the global bucket and from the buckets of ip and
+// key (when not empty), or from none of them when any has run out
+func (l *Limiter) Allow(ip, key string) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+
+	type check struct {
+		scope string
+		limit Limit
+		b     *bucket
+	}
+	var checks []check
+	if l.limits.PerKey.Enabled() && key != "" {
+		checks = append(checks, check{ScopeKey, l.limits.PerKey, l.keys.get(key, l.limits.PerKey, now)})
+	}
+	if l.limits.PerIP.Enabled() && ip != "" {
+		checks = append(checks, check{ScopeIP, l.limits.PerIP, l.ips.get(ip, l.limits.PerIP, now)})
+	}
+	if l.limits.Global.Enabled() {
+		if l.global == nil {
+			l.global = &bucket{tokens: float64(l.limits.Global.Burst), last: now}
+		}
+		checks = append(checks, check{ScopeGlobal, l.limits.Global, l.global})
+	}
+
+	var rejected *Decision
+	for _, c := range checks {
+		c.b.refill(c.limit, now)
+		if c.b.tokens >= 1 {
+			continue
+		}
+		retryAfter := seconds((1 - c.b.tokens) / c.limit.Rate)
+		if rejected == nil || retryAfter > rejected.RetryAfter {
+			rejected = &Decision{
+				Scope:      c.scope,
+				Limit:      c.limit.Burst,
+				Reset:      c.b.untilFull(c.limit),
+				RetryAfter: retryAfter,
+			}
+		}
+	}
+	if rejected != nil {
+		return *rejected
+	}
+
+	decision := Decision{Allowed: true}
+	for _, c := range checks {
+		c.b.tokens--
+		if remaining := int(c.b.tokens); decision.Scope == "" || remaining < decision.Remaining {
+			decision.Scope = c.scope
+			decision.Limit = c.limit.Burst
+			decision.Remaining = remaining
+			decision.Reset = c.b.untilFull(c.limit)
+		}
+	}
+	return decision
+}
+
+// refill adds the tokens earned since the last refill, up to the burst
+func (b *bucket) refill(limit Limit, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * limit.Rate
+		b.last = now
+	}
+	b.tokens = math.Min(b.tokens, float64(limit.Burst))
+}
+
+// untilFull is the time until the bucket holds its burst again
+func (b *bucket) untilFull(limit Limit) time.Duration {
+	return seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// ClientIP is the IP of the client that sent r. Behind trusted proxies it is
+// the last address of X-Forwarded-For that isn't a trusted proxy itself, so
+// clients can't pick their bucket by sending the header themselves.
+func ClientIP(r *http.Request, trusted []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap()
+	if !isTrusted(addr, trusted) {
+		return addr.String()
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			// A malformed hop: the proxies before it can't be trusted
+			break
+		}
+		hop = hop.Unmap()
+		if !isTrusted(hop, trusted) {
+			return hop.String()
+		}
+		addr = hop
+	}
+	return addr.String()
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestRateLimit(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "RateLimit Suite")
+}
+
+var _ = Describe("Limiter", func() {
+	var (
+		now     time.Time
+		limiter *Limiter
+	)
+
+	newLimiter := func(limits Limits) *Limiter {
+		l := New(limits)
+		l.now = func() time.Time { return now }
+		return l
+	}
+
+	BeforeEach(func() {
+		now = time.Unix(1700000000, 0)
+	})
+
+	It("should allow the burst, then refill at the rate", func() {
+		limiter = newLimiter(Limits{PerIP: Limit{Rate: 2, Burst: 3}})
+		for i := 2; i >= 0; i-- {
+			d := limiter.Allow("192.0.2.1", "")
+			Expect(d.Allowed).To(BeTrue())
+			Expect(d.Scope).To(Equal(ScopeIP))
+			Expect(d.Limit).To(Equal(3))
+			Expect(d.Remaining).To(Equal(i))
+		}
+
+		d := limiter.Allow("192.0.2.1", "")
+		Expect(d.Allowed).To(BeFalse())
+		Expect(d.Scope).To(Equal(ScopeIP))
+		Expect(d.RetryAfter).To(Equal(500 * time.Millisecond))
+		Expect(d.Reset).To(Equal(1500 * time.Millisecond))
+
+		// Other clients have buckets of their own
+		Expect(limiter.Allow("192.0.2.2", "").Allowed).To(BeTrue())
+
+		now = now.Add(500 * time.Millisecond)
+		Expect(limiter.Allow("192.0.2.1", "").Allowed).To(BeTrue())
+		Expect(limiter.Allow("192.0.2.1", "").Allowed).To(BeFalse())
+	})
+
+	It("should take no token when any bucket has run out", func() {
+		limiter = newLimiter(Limits{
+			Global: Limit{Rate: 1, Burst: 10},
+			PerKey: Limit{Rate: 1, Burst: 1},
+		})
+		Expect(limiter.Allow("192.0.2.1", "tenant-a").Allowed).To(BeTrue())
+
+		d := limiter.Allow("192.0.2.1", "tenant-a")
+		Expect(d.Allowed).To(BeFalse())
+		Expect(d.Scope).To(Equal(ScopeKey))
+
+		// The rejected request left the global bucket alone
+		d = limiter.Allow("192.0.2.1", "tenant-b")
+		Expect(d.Allowed).To(BeTrue())
+		Expect(d.Scope).To(Equal(ScopeKey))
+		Expect(limiter.global.tokens).To(Equal(8.0))
+	})
+
+	It("should reject with the bucket that refills last", func() {
+		limiter = newLimiter(Limits{
+			Global: Limit{Rate: 0.5, Burst: 1},
+			PerIP:  Limit{Rate: 10, Burst: 1},
+		})
+		Expect(limiter.Allow("192.0.2.1", "").Allowed).To(BeTrue())
+		d := limiter.Allow("192.0.2.1", "")
+		Expect(d.Allowed).To(BeFalse())
+		Expect(d.Scope).To(Equal(ScopeGlobal))
+		Expect(d.RetryAfter).To(Equal(2 * time.Second))
+	})
+
+	It("should not limit requests without limits", func() {
+		limiter = newLimiter(Limits{PerKey: Limit{Rate: 1, Burst: 1}})
+		for range 3 {
+			d := limiter.Allow("192.0.2.1", "")
+			Expect(d.Allowed).To(BeTrue())
+			Expect(d.Scope).To(BeEmpty())
+		}
+	})
+
+	It("should change limits at runtime and reject invalid ones", func() {
+		limiter = newLimiter(Limits{})
+		Expect(limiter.Allow("192.0.2.1", "").Scope).To(BeEmpty())
+
+		Expect(limiter.SetLimits(Limits{PerIP: Limit{Rate: 1}})).To(MatchError(ContainSubstring("burst must be at least 1")))
+		Expect(limiter.SetLimits(Limits{PerIP: Limit{Rate: 1, Burst: 1}})).To(Succeed())
+		Expect(limiter.Limits().PerIP).To(Equal(Limit{Rate: 1, Burst: 1}))
+		Expect(limiter.Allow("192.0.2.1", "").Allowed).To(BeTrue())
+		Expect(limiter.Allow("192.0.2.1", "").Allowed).To(BeFalse())
+	})
+
+	It("should drop full buckets periodically", func() {
+		limiter = newLimiter(Limits{PerIP: Limit{Rate: 1, Burst: 2}})
+		for i := range 100 {
+			limiter.Allow(netip.AddrFrom4([4]byte{10, 0, 0, byte(i)}).String(), "")
+		}
+		Expect(limiter.ips.len()).To(Equal(100))
+
+		// 10.0.0.0 runs out just before the sweep, so it isn't full at the sweep
+		now = now.Add(sweepInterval - 500*time.Millisecond)
+		limiter.Allow("10.0.0.0", "")
+		limiter.Allow("10.0.0.0", "")
+		Expect(limiter.ips.len()).To(Equal(100))
+		now = now.Add(500 * time.Millisecond)
+		limiter.Allow("192.0.2.1", "")
+		Expect(limiter.ips.len()).To(Equal(2))
+		Expect(limiter.ips.byID).To(HaveKey("10.0.0.0"))
+	})
+
+	It("should keep at most maxBuckets when no bucket is full", func() {
+		limiter = newLimiter(Limits{PerKey: Limit{Rate: 0.001, Burst: 1}})
+		for i := range maxBuckets + 100 {
+			Expect(limiter.Allow("", fmt.Sprintf("key-%d", i)).Allowed).To(BeTrue())
+		}
+		Expect(limiter.keys.len()).To(BeNumerically("<=", maxBuckets))
+		Expect(limiter.keys.byID).To(HaveLen(limiter.keys.len()))
+		// The least recently seen clients went first
+		Expect(limiter.keys.byID).NotTo(HaveKey("key-0"))
+		Expect(limiter.keys.byID).To(HaveKey(fmt.Sprintf("key-%d", maxBuckets+99)))
+	})
+})
+
+var _ = Describe("ClientIP", func() {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	clientIP := func(remoteAddr string, forwardedFor ...string) string {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remoteAddr
+		for _, value := range forwardedFor {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		return ClientIP(r, trusted)
+	}
+
+	It("should ignore X-Forwarded-For from untrusted peers", func() {
+		Expect(clientIP("192.0.2.1:1234", "198.51.100.7")).To(Equal("192.0.2.1"))
+	})
+
+	It("should take the last untrusted hop behind trusted proxies", func() {
+		Expect(clientIP("10.0.0.1:1234", "203.0.113.9, 198.51.100.7, 10.0.0.2")).To(Equal("198.51.100.7"))
+		Expect(clientIP("10.0.0.1:1234", "203.0.113.9", "198.51.100.7")).To(Equal("198.51.100.7"))
+	})
+
+	It("should fall back to the proxies when every hop is trusted or malformed", func() {
+		Expect(clientIP("10.0.0.1:1234")).To(Equal("10.0.0.1"))
+		Expect(clientIP("10.0.0.1:1234", "10.0.0.2")).To(Equal("10.0.0.2"))
+		Expect(clientIP("10.0.0.1:1234", "198.51.100.7, bogus, 10.0.0.2")).To(Equal("10.0.0.2"))
+	})
+
+	It("should unmap IPv4-mapped addresses", func() {
+		Expect(clientIP("[::ffff:192.0.2.1]:1234")).To(Equal("192.0.2.1"))
+	})
+})
+
+var _ = Describe("Config", func() {
+	It("should parse limits", func() {
+		Expect(ParseLimit("10:20")).To(Equal(Limit{Rate: 10, Burst: 20}))
+		Expect(ParseLimit("0.5")).To(Equal(Limit{Rate: 0.5, Burst: 1}))
+		_, err := ParseLimit("fast")
+		Expect(err).To(MatchError(ContainSubstring("invalid rate")))
+		_, err = ParseLimit("10:lots")
+		Expect(err).To(MatchError(ContainSubstring("invalid burst")))
+	})
+
+	It("should read the environment", func() {
+		for name, value := range map[string]string{
+			"RATE_LIMIT_GLOBAL":          "100:200",
+			"RATE_LIMIT_PER_IP":          "5",
+			"RATE_LIMIT_TRUSTED_PROXIES": "10.0.0.0/8, 192.0.2.1",
+			"RATE_LIMIT_KEY_HEADER":      "X-Tenant-ID",
+		} {
+			os.Setenv(name, value)
+			DeferCleanup(os.Unsetenv, name)
+		}
+
+		cfg, err := ConfigFromEnv()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Global).To(Equal(Limit{Rate: 100, Burst: 200}))
+		Expect(cfg.PerIP).To(Equal(Limit{Rate: 5, Burst: 5}))
+		Expect(cfg.PerKey.Enabled()).To(BeFalse())
+		Expect(cfg.TrustedProxies).To(Equal([]netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("192.0.2.1/32"),
+		}))
+		Expect(cfg.KeyHeader).To(Equal("X-Tenant-ID"))
+	})
+
+	It("should reject invalid settings", func() {
+		os.Setenv("RATE_LIMIT_PER_KEY", "5:0")
+		DeferCleanup(os.Unsetenv, "RATE_LIMIT_PER_KEY")
+		os.Setenv("RATE_LIMIT_TRUSTED_PROXIES", "proxy")
+		DeferCleanup(os.Unsetenv, "RATE_LIMIT_TRUSTED_PROXIES")
+
+		_, err := ConfigFromEnv()
+		Expect(err).To(MatchError(ContainSubstring("RATE_LIMIT_TRUSTED_PROXIES")))
+
+		os.Unsetenv("RATE_LIMIT_TRUSTED_PROXIES")
+		_, err = ConfigFromEnv()
+		Expect(err).To(MatchError(ContainSubstring("key: burst must be at least 1")))
+	})
+})
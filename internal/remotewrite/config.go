@@ -0,0 +1,129 @@
+package remotewrite
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config controls where and how the exporter pushes samples
+type Config struct {
+	// URL of the remote-write endpoint, e.g. http://prometheus:9090/api/v1/write
+	URL string
+	// Username and Password enable HTTP basic auth
+	Username string
+	Password string
+	// BearerToken is sent as "Authorization: Bearer <token>"
+	BearerToken string
+	// BearerTokenFile is read on every request, so rotated (mounted) tokens are picked up
+	BearerTokenFile string
+	// Timeout of a single HTTP request
+	Timeout time.Duration
+	// MaxRetries is how often a failed request is retried (429, 5xx and network errors only)
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the exponential backoff between retries
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultConfig returns the settings used for everything not configured
+func DefaultConfig() Config {
+	return Config{
+		Timeout:    10 * time.Second,
+		MaxRetries: 3,
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: 5 * time.Second,
+	}
+}
+
+// LoadConfig reads the exporter settings from the environment:
+//   - PROMETHEUS_REMOTE_WRITE_URL (required)
+//   - PROMETHEUS_REMOTE_WRITE_USERNAME and PROMETHEUS_REMOTE_WRITE_PASSWORD
+//   - PROMETHEUS_REMOTE_WRITE_BEARER_TOKEN or PROMETHEUS_REMOTE_WRITE_BEARER_TOKEN_FILE
+//   - PROMETHEUS_REMOTE_WRITE_TIMEOUT, PROMETHEUS_REMOTE_WRITE_MIN_BACKOFF and
+//     PROMETHEUS_REMOTE_WRITE_MAX_BACKOFF (Go durations)
+//   - PROMETHEUS_REMOTE_WRITE_MAX_RETRIES
+func LoadConfig() (Config, error) {
+	cfg := DefaultConfig()
+	cfg.URL = os.Getenv("PROMETHEUS_REMOTE_WRITE_URL")
+	cfg.Username = os.Getenv("PROMETHEUS_REMOTE_WRITE_USERNAME")
+	cfg.Password = os.Getenv("PROMETHEUS_REMOTE_WRITE_PASSWORD")
+	cfg.BearerToken = os.Getenv("PROMETHEUS_REMOTE_WRITE_BEARER_TOKEN")
+	cfg.BearerTokenFile = os.Getenv("PROMETHEUS_REMOTE_WRITE_BEARER_TOKEN_FILE")
+
+	durations := []struct {
+		key   string
+		value *time.Duration
+	}{
+		{"PROMETHEUS_REMOTE_WRITE_TIMEOUT", &cfg.Timeout},
+		{"PROMETHEUS_REMOTE_WRITE_MIN_BACKOFF", &cfg.MinBackoff},
+		{"PROMETHEUS_REMOTE_WRITE_MAX_BACKOFF", &cfg.MaxBackoff},
+	}
+	for _, d := range durations {
+		raw := os.Getenv(d.key)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s %q: %w", d.key, raw, err)
+		}
+		*d.value = parsed
+	}
+
+	if raw := os.Getenv("PROMETHEUS_REMOTE_WRITE_MAX_RETRIES"); raw != "" {
+		retries, err := strconv.Atoi(raw)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid PROMETHEUS_REMOTE_WRITE_MAX_RETRIES %q: %w", raw, err)
+		}
+		cfg.MaxRetries = retries
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that the configuration is usable
+func (c Config) Validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("remote-write URL is required")
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid remote-write URL %q", c.URL)
+	}
+
+	basic := c.Username != "" || c.Password != ""
+	bearer := c.BearerToken != "" || c.BearerTokenFile != ""
+	if basic && bearer {
+		return fmt.Errorf("basic auth and bearer token are mutually exclusive")
+	}
+	if c.BearerToken != "" && c.BearerTokenFile != "" {
+		return fmt.Errorf("bearer token and bearer token file are mutually exclusive")
+	}
+
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive, got %s", c.Timeout)
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative, got %d", c.MaxRetries)
+	}
+	if c.MinBackoff <= 0 || c.MaxBackoff < c.MinBackoff {
+		return fmt.Errorf("invalid backoff %s..%s", c.MinBackoff, c.MaxBackoff)
+	}
+	return nil
+}
+
+// bearerToken returns the token to send, reading BearerTokenFile if configured
+func (c Config) bearerToken() (string, error) {
+	if c.BearerTokenFile == "" {
+		return c.BearerToken, nil
+	}
+	data, err := os.ReadFile(c.BearerTokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read bearer token file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
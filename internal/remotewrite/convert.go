@@ -0,0 +1,256 @@
+package remotewrite
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// Resource attribute keys mapped to the job and instance target labels, as the
+// collector's prometheusremotewrite exporter does
+const (
+	serviceNameKey       = "service.name"
+	serviceNamespaceKey  = "service.namespace"
+	serviceInstanceIDKey = "service.instance.id"
+)
+
+// unitSuffixes are the Prometheus names of the common UCUM units
+var unitSuffixes = map[string]string{
+	"d": "days", "h": "hours", "min": "minutes", "s": "seconds",
+	"ms": "milliseconds", "us": "microseconds", "ns": "nanoseconds",
+	"By": "bytes", "KiBy": "kibibytes", "MiBy": "mebibytes", "GiBy": "gibibytes",
+	"KBy": "kilobytes", "MBy": "megabytes", "GBy": "gigabytes",
+	"%": "percent", "Hz": "hertz", "Cel": "celsius",
+}
+
+// perUnitSuffixes are the Prometheus names of the denominators of rate units (By/s)
+var perUnitSuffixes = map[string]string{
+	"s": "second", "m": "minute", "h": "hour", "d": "day",
+}
+
+// toWriteRequest converts the SDK output to remote-write series. Only cumulative
+// data can be written; the exporter asks the SDK for cumulative temporality.
+func toWriteRequest(rm *metricdata.ResourceMetrics) writeRequest {
+	var req writeRequest
+	target := targetLabels(rm.Resource)
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			name, typ := metricName(m)
+			if typ == metricTypeUnknown {
+				continue
+			}
+			req.Metadata = append(req.Metadata, metricMetadata{Type: typ, Name: name, Help: m.Description, Unit: promUnit(m.Unit)})
+
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				req.Series = append(req.Series, numberSeries(name, target, data.DataPoints)...)
+			case metricdata.Sum[float64]:
+				req.Series = append(req.Series, numberSeries(name, target, data.DataPoints)...)
+			case metricdata.Gauge[int64]:
+				req.Series = append(req.Series, numberSeries(name, target, data.DataPoints)...)
+			case metricdata.Gauge[float64]:
+				req.Series = append(req.Series, numberSeries(name, target, data.DataPoints)...)
+			case metricdata.Histogram[int64]:
+				req.Series = append(req.Series, histogramSeries(name, target, data.DataPoints)...)
+			case metricdata.Histogram[float64]:
+				req.Series = append(req.Series, histogramSeries(name, target, data.DataPoints)...)
+			case metricdata.Summary:
+				req.Series = append(req.Series, summarySeries(name, target, data.DataPoints)...)
+			}
+		}
+	}
+	return req
+}
+
+// metricName returns the Prometheus metric family name and type of an OTel metric.
+// Exponential histograms are not supported by remote-write 1.0 and are skipped.
+func metricName(m metricdata.Metrics) (string, metricType) {
+	name := sanitizeName(m.Name, true)
+	unit := promUnit(m.Unit)
+
+	typ := metricTypeUnknown
+	monotonic := false
+	switch data := m.Data.(type) {
+	case metricdata.Sum[int64]:
+		typ, monotonic = sumType(data.IsMonotonic), data.IsMonotonic
+	case metricdata.Sum[float64]:
+		typ, monotonic = sumType(data.IsMonotonic), data.IsMonotonic
+	case metricdata.Gauge[int64], metricdata.Gauge[float64]:
+		typ = metricTypeGauge
+		if m.Unit == "1" {
+			unit = "ratio"
+		}
+	case metricdata.Histogram[int64], metricdata.Histogram[float64]:
+		typ = metricTypeHistogram
+	case metricdata.Summary:
+		typ = metricTypeSummary
+	}
+
+	if unit != "" && !strings.HasSuffix(strings.TrimSuffix(name, "_total"), "_"+unit) {
+		name = strings.TrimSuffix(name, "_total") + "_" + unit
+		if monotonic {
+			name += "_total"
+		}
+	}
+	if monotonic && !strings.HasSuffix(name, "_total") {
+		name += "_total"
+	}
+	return name, typ
+}
+
+func sumType(monotonic bool) metricType {
+	if monotonic {
+		return metricTypeCounter
+	}
+	return metricTypeGauge
+}
+
+// promUnit converts a UCUM unit to its Prometheus suffix. Annotations ({request})
+// and the dimensionless "1" have no suffix.
+func promUnit(unit string) string {
+	if unit == "" || unit == "1" || strings.HasPrefix(unit, "{") {
+		return ""
+	}
+	main, per, hasPer := strings.Cut(unit, "/")
+	suffix := unitSuffix(main, unitSuffixes)
+	if hasPer {
+		perSuffix := unitSuffix(per, perUnitSuffixes)
+		if suffix == "" {
+			return "per_" + perSuffix
+		}
+		return suffix + "_per_" + perSuffix
+	}
+	return suffix
+}
+
+func unitSuffix(unit string, names map[string]string) string {
+	if strings.HasPrefix(unit, "{") {
+		return ""
+	}
+	if name, ok := names[unit]; ok {
+		return name
+	}
+	return sanitizeName(unit, false)
+}
+
+// targetLabels derives job and instance from the resource
+func targetLabels(res *resource.Resource) []label {
+	if res == nil {
+		return nil
+	}
+	var labels []label
+	if name, ok := res.Set().Value(serviceNameKey); ok {
+		job := name.Emit()
+		if ns, ok := res.Set().Value(serviceNamespaceKey); ok && ns.Emit() != "" {
+			job = ns.Emit() + "/" + job
+		}
+		labels = append(labels, label{Name: "job", Value: job})
+	}
+	if id, ok := res.Set().Value(serviceInstanceIDKey); ok {
+		labels = append(labels, label{Name: "instance", Value: id.Emit()})
+	}
+	return labels
+}
+
+// seriesLabels builds the sorted label set of a series
+func seriesLabels(name string, target []label, attrs attribute.Set, extra ...label) []label {
+	labels := make([]label, 0, len(target)+attrs.Len()+len(extra)+1)
+	labels = append(labels, label{Name: "__name__", Value: name})
+	labels = append(labels, target...)
+	for _, kv := range attrs.ToSlice() {
+		labels = append(labels, label{Name: sanitizeName(string(kv.Key), false), Value: kv.Value.Emit()})
+	}
+	labels = append(labels, extra...)
+	sort.SliceStable(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels
+}
+
+func numberSeries[N int64 | float64](name string, target []label, points []metricdata.DataPoint[N]) []timeSeries {
+	series := make([]timeSeries, 0, len(points))
+	for _, dp := range points {
+		series = append(series, timeSeries{
+			Labels:  seriesLabels(name, target, dp.Attributes),
+			Samples: []sample{{Value: float64(dp.Value), Timestamp: timestamp(dp.Time)}},
+		})
+	}
+	return series
+}
+
+func histogramSeries[N int64 | float64](name string, target []label, points []metricdata.HistogramDataPoint[N]) []timeSeries {
+	var series []timeSeries
+	for _, dp := range points {
+		ts := timestamp(dp.Time)
+		var cumulative uint64
+		for i, count := range dp.BucketCounts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(dp.Bounds) {
+				le = strconv.FormatFloat(dp.Bounds[i], 'g', -1, 64)
+			}
+			series = append(series, timeSeries{
+				Labels:  seriesLabels(name+"_bucket", target, dp.Attributes, label{Name: "le", Value: le}),
+				Samples: []sample{{Value: float64(cumulative), Timestamp: ts}},
+			})
+		}
+		series = append(series,
+			timeSeries{
+				Labels:  seriesLabels(name+"_sum", target, dp.Attributes),
+				Samples: []sample{{Value: float64(dp.Sum), Timestamp: ts}},
+			},
+			timeSeries{
+				Labels:  seriesLabels(name+"_count", target, dp.Attributes),
+				Samples: []sample{{Value: float64(dp.Count), Timestamp: ts}},
+			},
+		)
+	}
+	return series
+}
+
+func summarySeries(name string, target []label, points []metricdata.SummaryDataPoint) []timeSeries {
+	var series []timeSeries
+	for _, dp := range points {
+		ts := timestamp(dp.Time)
+		for _, q := range dp.QuantileValues {
+			series = append(series, timeSeries{
+				Labels: seriesLabels(name, target, dp.Attributes,
+					label{Name: "quantile", Value: strconv.FormatFloat(q.Quantile, 'g', -1, 64)}),
+				Samples: []sample{{Value: q.Value, Timestamp: ts}},
+			})
+		}
+		series = append(series,
+			timeSeries{Labels: seriesLabels(name+"_sum", target, dp.Attributes), Samples: []sample{{Value: dp.Sum, Timestamp: ts}}},
+			timeSeries{Labels: seriesLabels(name+"_count", target, dp.Attributes), Samples: []sample{{Value: float64(dp.Count), Timestamp: ts}}},
+		)
+	}
+	return series
+}
+
+func timestamp(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+// sanitizeName replaces characters Prometheus doesn't allow in metric names
+// ([a-zA-Z_:][a-zA-Z0-9_:]*) or label names (no colons) with underscores
+func sanitizeName(name string, allowColon bool) string {
+	var b strings.Builder
+	for i, c := range name {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(c == ':' && allowColon) || (c >= '0' && c <= '9' && i > 0)
+		if i == 0 && c >= '0' && c <= '9' {
+			b.WriteString("_")
+			valid = true
+		}
+		if valid {
+			b.WriteRune(c)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
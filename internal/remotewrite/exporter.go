@@ -0,0 +1,188 @@
+// Package remotewrite is an OpenTelemetry metric exporter that pushes the
+// MeterProvider output to a Prometheus remote-write 1.0 endpoint, for clusters
+// that run Prometheus but no OTel Collector.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Remote-write 1.0 protocol headers
+const (
+	contentType     = "application/x-protobuf"
+	contentEncoding = "snappy"
+	protocolVersion = "0.1.0"
+	userAgent       = "dm-nkp-gitops-custom-app/remotewrite"
+)
+
+// maxErrorBody bounds how much of an error response is kept for the error message
+const maxErrorBody = 1024
+
+// Exporter implements sdkmetric.Exporter for Prometheus remote-write
+type Exporter struct {
+	cfg    Config
+	client *http.Client
+
+	mu       sync.Mutex
+	shutdown bool
+}
+
+var _ sdkmetric.Exporter = (*Exporter)(nil)
+
+// New creates an exporter for the given configuration
+func New(cfg Config) (*Exporter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Exporter{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Temporality is always cumulative: remote-write expects Prometheus semantics
+func (e *Exporter) Temporality(sdkmetric.InstrumentKind) metricdata.Temporality {
+	return metricdata.CumulativeTemporality
+}
+
+// Aggregation uses the SDK defaults
+func (e *Exporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+// Export converts the metrics to time series and pushes them, retrying
+// recoverable failures with exponential backoff
+func (e *Exporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	shutdown := e.shutdown
+	e.mu.Unlock()
+	if shutdown {
+		return errors.New("remote-write exporter is shut down")
+	}
+
+	req := toWriteRequest(rm)
+	if len(req.Series) == 0 {
+		return nil
+	}
+	body := snappy.Encode(nil, req.Marshal())
+
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := e.send(ctx, body)
+		if err == nil {
+			return nil
+		}
+		var recoverable *recoverableError
+		if !errors.As(err, &recoverable) || attempt >= e.cfg.MaxRetries {
+			return fmt.Errorf("remote write to %s failed after %d attempt(s): %w", e.cfg.URL, attempt+1, err)
+		}
+
+		wait := e.backoff(attempt)
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("remote write to %s failed: %w (last error: %v)", e.cfg.URL, ctx.Err(), err)
+		case <-time.After(wait):
+		}
+	}
+}
+
+// ForceFlush is a no-op: every Export is sent synchronously
+func (e *Exporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+// Shutdown makes further exports fail
+func (e *Exporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shutdown = true
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// recoverableError marks failures worth retrying (network errors, 429 and 5xx)
+type recoverableError struct {
+	err error
+}
+
+func (r *recoverableError) Error() string { return r.err.Error() }
+func (r *recoverableError) Unwrap() error { return r.err }
+
+// send posts one request. It returns the server's Retry-After delay, if any.
+func (e *Exporter) send(ctx context.Context, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Content-Encoding", contentEncoding)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", protocolVersion)
+
+	if e.cfg.Username != "" || e.cfg.Password != "" {
+		req.SetBasicAuth(e.cfg.Username, e.cfg.Password)
+	}
+	token, err := e.cfg.bearerToken()
+	if err != nil {
+		return 0, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, err
+		}
+		return 0, &recoverableError{err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return 0, nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	err = fmt.Errorf("server returned HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5 {
+		return retryAfter(resp.Header.Get("Retry-After")), &recoverableError{err}
+	}
+	return 0, err
+}
+
+// backoff doubles the delay on every attempt, capped at MaxBackoff
+func (e *Exporter) backoff(attempt int) time.Duration {
+	wait := e.cfg.MinBackoff
+	for i := 0; i < attempt && wait < e.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > e.cfg.MaxBackoff {
+		wait = e.cfg.MaxBackoff
+	}
+	return wait
+}
+
+// retryAfter parses a Retry-After header given in seconds
+func retryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
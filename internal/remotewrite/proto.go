@@ -0,0 +1,113 @@
+package remotewrite
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The remote-write 1.0 messages (prometheus/prompb types.proto and remote.proto),
+// encoded by hand so the exporter doesn't pull in the Prometheus module:
+//
+//	message WriteRequest   { repeated TimeSeries timeseries = 1; repeated MetricMetadata metadata = 3; }
+//	message TimeSeries     { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label          { string name = 1; string value = 2; }
+//	message Sample         { double value = 1; int64 timestamp = 2; }
+//	message MetricMetadata { MetricType type = 1; string metric_family_name = 2; string help = 4; string unit = 5; }
+
+// metricType is prompb.MetricMetadata_MetricType
+type metricType int32
+
+const (
+	metricTypeUnknown   metricType = 0
+	metricTypeCounter   metricType = 1
+	metricTypeGauge     metricType = 2
+	metricTypeHistogram metricType = 3
+	metricTypeSummary   metricType = 5
+)
+
+// label is a single name/value pair of a series
+type label struct {
+	Name  string
+	Value string
+}
+
+// sample is a value at a timestamp in milliseconds
+type sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// timeSeries is a labelled series with its samples
+type timeSeries struct {
+	Labels  []label
+	Samples []sample
+}
+
+// metricMetadata describes a metric family
+type metricMetadata struct {
+	Type metricType
+	Name string
+	Help string
+	Unit string
+}
+
+// writeRequest is the body of a remote-write request before snappy compression
+type writeRequest struct {
+	Series   []timeSeries
+	Metadata []metricMetadata
+}
+
+// Marshal encodes the request as protobuf
+func (r writeRequest) Marshal() []byte {
+	var b []byte
+	for _, ts := range r.Series {
+		b = appendMessage(b, 1, ts.marshal())
+	}
+	for _, md := range r.Metadata {
+		b = appendMessage(b, 3, md.marshal())
+	}
+	return b
+}
+
+func (ts timeSeries) marshal() []byte {
+	var b []byte
+	for _, l := range ts.Labels {
+		var lb []byte
+		lb = appendString(lb, 1, l.Name)
+		lb = appendString(lb, 2, l.Value)
+		b = appendMessage(b, 1, lb)
+	}
+	for _, s := range ts.Samples {
+		var sb []byte
+		sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+		sb = protowire.AppendFixed64(sb, math.Float64bits(s.Value))
+		sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(s.Timestamp))
+		b = appendMessage(b, 2, sb)
+	}
+	return b
+}
+
+func (md metricMetadata) marshal() []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(md.Type))
+	b = appendString(b, 2, md.Name)
+	b = appendString(b, 4, md.Help)
+	b = appendString(b, 5, md.Unit)
+	return b
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
@@ -0,0 +1,452 @@
+package remotewrite
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestRemoteWrite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "RemoteWrite Suite")
+}
+
+// receiver is a remote-write endpoint recording what it receives
+type receiver struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []writeRequest
+	// statuses are returned in order, then 204
+	statuses []int
+	calls    atomic.Int32
+}
+
+func newReceiver(statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		call := int(r.calls.Add(1)) - 1
+
+		compressed, err := io.ReadAll(req.Body)
+		Expect(err).NotTo(HaveOccurred())
+		data, err := snappy.Decode(nil, compressed)
+		Expect(err).NotTo(HaveOccurred())
+
+		r.mu.Lock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, decodeWriteRequest(data))
+		r.mu.Unlock()
+
+		if call < len(r.statuses) {
+			w.WriteHeader(r.statuses[call])
+			_, _ = io.WriteString(w, "receiver error")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return r
+}
+
+func (r *receiver) lastRequest() (*http.Request, writeRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	Expect(r.requests).NotTo(BeEmpty())
+	return r.requests[len(r.requests)-1], r.bodies[len(r.bodies)-1]
+}
+
+// decodeWriteRequest is the inverse of writeRequest.Marshal
+func decodeWriteRequest(b []byte) writeRequest {
+	var req writeRequest
+	forEachField(b, func(num protowire.Number, v []byte, _ uint64) {
+		switch num {
+		case 1:
+			var ts timeSeries
+			forEachField(v, func(num protowire.Number, v []byte, _ uint64) {
+				switch num {
+				case 1:
+					var l label
+					forEachField(v, func(num protowire.Number, v []byte, _ uint64) {
+						if num == 1 {
+							l.Name = string(v)
+						} else {
+							l.Value = string(v)
+						}
+					})
+					ts.Labels = append(ts.Labels, l)
+				case 2:
+					var s sample
+					forEachField(v, func(num protowire.Number, _ []byte, n uint64) {
+						if num == 1 {
+							s.Value = math.Float64frombits(n)
+						} else {
+							s.Timestamp = int64(n)
+						}
+					})
+					ts.Samples = append(ts.Samples, s)
+				}
+			})
+			req.Series = append(req.Series, ts)
+		case 3:
+			var md metricMetadata
+			forEachField(v, func(num protowire.Number, v []byte, n uint64) {
+				switch num {
+				case 1:
+					md.Type = metricType(n)
+				case 2:
+					md.Name = string(v)
+				case 4:
+					md.Help = string(v)
+				case 5:
+					md.Unit = string(v)
+				}
+			})
+			req.Metadata = append(req.Metadata, md)
+		}
+	})
+	return req
+}
+
+// forEachField calls fn with the bytes of length-delimited fields or the value of numeric fields
+func forEachField(b []byte, fn func(num protowire.Number, bytes []byte, value uint64)) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		Expect(n).To(BeNumerically(">", 0))
+		b = b[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			Expect(n).To(BeNumerically(">", 0))
+			fn(num, v, 0)
+			b = b[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			Expect(n).To(BeNumerically(">", 0))
+			fn(num, nil, v)
+			b = b[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			Expect(n).To(BeNumerically(">", 0))
+			fn(num, nil, v)
+			b = b[n:]
+		default:
+			Fail("unexpected wire type")
+		}
+	}
+}
+
+// series indexes the received series by their label set
+func series(req writeRequest) map[string]float64 {
+	out := make(map[string]float64)
+	for _, ts := range req.Series {
+		key := ""
+		for _, l := range ts.Labels {
+			key += l.Name + "=" + l.Value + ","
+		}
+		out[key] = ts.Samples[0].Value
+	}
+	return out
+}
+
+var testTime = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func testMetrics() *metricdata.ResourceMetrics {
+	attrs := attribute.NewSet(attribute.String("method", "GET"), attribute.String("http.status", "200"))
+	return &metricdata.ResourceMetrics{
+		Resource: resource.NewSchemaless(
+			attribute.String("service.name", "app"),
+			attribute.String("service.namespace", "shop"),
+			attribute.String("service.instance.id", "pod-1"),
+		),
+		ScopeMetrics: []metricdata.ScopeMetrics{{Metrics: []metricdata.Metrics{
+			{
+				Name: "http_requests_total", Description: "Total requests", Unit: "{request}",
+				Data: metricdata.Sum[int64]{IsMonotonic: true, Temporality: metricdata.CumulativeTemporality,
+					DataPoints: []metricdata.DataPoint[int64]{{Attributes: attrs, Time: testTime, Value: 7}}},
+			},
+			{
+				Name: "http.server.request.size", Unit: "By",
+				Data: metricdata.Sum[int64]{IsMonotonic: true, Temporality: metricdata.CumulativeTemporality,
+					DataPoints: []metricdata.DataPoint[int64]{{Time: testTime, Value: 512}}},
+			},
+			{
+				Name: "queue.fill", Unit: "1",
+				Data: metricdata.Gauge[float64]{DataPoints: []metricdata.DataPoint[float64]{{Time: testTime, Value: 0.5}}},
+			},
+			{
+				Name: "http_request_duration_seconds", Unit: "s",
+				Data: metricdata.Histogram[float64]{Temporality: metricdata.CumulativeTemporality,
+					DataPoints: []metricdata.HistogramDataPoint[float64]{{
+						Time: testTime, Count: 3, Sum: 1.5, Bounds: []float64{0.1, 1}, BucketCounts: []uint64{1, 1, 1},
+					}}},
+			},
+		}}},
+	}
+}
+
+var _ = Describe("RemoteWrite", func() {
+	var rcv *receiver
+
+	BeforeEach(func() {
+		rcv = newReceiver()
+	})
+
+	AfterEach(func() {
+		rcv.server.Close()
+	})
+
+	newExporter := func(mutate func(*Config)) *Exporter {
+		cfg := DefaultConfig()
+		cfg.URL = rcv.server.URL
+		cfg.MinBackoff = time.Millisecond
+		cfg.MaxBackoff = 5 * time.Millisecond
+		if mutate != nil {
+			mutate(&cfg)
+		}
+		exporter, err := New(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		return exporter
+	}
+
+	Describe("Export", func() {
+		It("should send snappy-compressed protobuf with the remote-write headers", func() {
+			Expect(newExporter(nil).Export(context.Background(), testMetrics())).To(Succeed())
+
+			req, _ := rcv.lastRequest()
+			Expect(req.Method).To(Equal(http.MethodPost))
+			Expect(req.Header.Get("Content-Type")).To(Equal("application/x-protobuf"))
+			Expect(req.Header.Get("Content-Encoding")).To(Equal("snappy"))
+			Expect(req.Header.Get("X-Prometheus-Remote-Write-Version")).To(Equal("0.1.0"))
+		})
+
+		It("should convert counters, gauges and histograms to Prometheus series", func() {
+			Expect(newExporter(nil).Export(context.Background(), testMetrics())).To(Succeed())
+
+			_, body := rcv.lastRequest()
+			Expect(series(body)).To(Equal(map[string]float64{
+				"__name__=http_requests_total,http_status=200,instance=pod-1,job=shop/app,method=GET,": 7,
+				"__name__=http_server_request_size_bytes_total,instance=pod-1,job=shop/app,":           512,
+				"__name__=queue_fill_ratio,instance=pod-1,job=shop/app,":                               0.5,
+				"__name__=http_request_duration_seconds_bucket,instance=pod-1,job=shop/app,le=0.1,":    1,
+				"__name__=http_request_duration_seconds_bucket,instance=pod-1,job=shop/app,le=1,":      2,
+				"__name__=http_request_duration_seconds_bucket,instance=pod-1,job=shop/app,le=+Inf,":   3,
+				"__name__=http_request_duration_seconds_sum,instance=pod-1,job=shop/app,":              1.5,
+				"__name__=http_request_duration_seconds_count,instance=pod-1,job=shop/app,":            3,
+			}))
+			for _, ts := range body.Series {
+				Expect(ts.Samples[0].Timestamp).To(Equal(testTime.UnixMilli()))
+			}
+		})
+
+		It("should send metric metadata", func() {
+			Expect(newExporter(nil).Export(context.Background(), testMetrics())).To(Succeed())
+
+			_, body := rcv.lastRequest()
+			Expect(body.Metadata).To(ContainElements(
+				metricMetadata{Type: metricTypeCounter, Name: "http_requests_total", Help: "Total requests"},
+				metricMetadata{Type: metricTypeHistogram, Name: "http_request_duration_seconds", Unit: "seconds"},
+			))
+		})
+
+		It("should not send anything without data", func() {
+			Expect(newExporter(nil).Export(context.Background(), &metricdata.ResourceMetrics{})).To(Succeed())
+			Expect(rcv.calls.Load()).To(BeZero())
+		})
+
+		It("should fail after shutdown", func() {
+			exporter := newExporter(nil)
+			Expect(exporter.Shutdown(context.Background())).To(Succeed())
+			Expect(exporter.Export(context.Background(), testMetrics())).To(MatchError(ContainSubstring("shut down")))
+		})
+
+		It("should work as the exporter of a MeterProvider", func() {
+			reader := sdkmetric.NewPeriodicReader(newExporter(nil), sdkmetric.WithInterval(time.Hour))
+			provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader),
+				sdkmetric.WithResource(resource.NewSchemaless(attribute.String("service.name", "app"))))
+			counter, err := provider.Meter("test").Int64Counter("jobs_processed_total")
+			Expect(err).NotTo(HaveOccurred())
+			counter.Add(context.Background(), 3, metric.WithAttributes(attribute.String("queue", "default")))
+
+			Expect(provider.ForceFlush(context.Background())).To(Succeed())
+			Expect(provider.Shutdown(context.Background())).To(Succeed())
+
+			_, body := rcv.lastRequest()
+			Expect(series(body)).To(HaveKeyWithValue("__name__=jobs_processed_total,job=app,queue=default,", 3.0))
+		})
+	})
+
+	Describe("Authentication", func() {
+		It("should send basic auth", func() {
+			exporter := newExporter(func(c *Config) { c.Username, c.Password = "user", "secret" })
+			Expect(exporter.Export(context.Background(), testMetrics())).To(Succeed())
+
+			req, _ := rcv.lastRequest()
+			user, pass, ok := req.BasicAuth()
+			Expect(ok).To(BeTrue())
+			Expect(user).To(Equal("user"))
+			Expect(pass).To(Equal("secret"))
+		})
+
+		It("should send a bearer token", func() {
+			exporter := newExporter(func(c *Config) { c.BearerToken = "token-1" })
+			Expect(exporter.Export(context.Background(), testMetrics())).To(Succeed())
+
+			req, _ := rcv.lastRequest()
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer token-1"))
+		})
+
+		It("should re-read the bearer token file on every request", func() {
+			file := filepath.Join(GinkgoT().TempDir(), "token")
+			Expect(os.WriteFile(file, []byte("token-1\n"), 0o600)).To(Succeed())
+			exporter := newExporter(func(c *Config) { c.BearerTokenFile = file })
+
+			Expect(exporter.Export(context.Background(), testMetrics())).To(Succeed())
+			req, _ := rcv.lastRequest()
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer token-1"))
+
+			Expect(os.WriteFile(file, []byte("token-2"), 0o600)).To(Succeed())
+			Expect(exporter.Export(context.Background(), testMetrics())).To(Succeed())
+			req, _ = rcv.lastRequest()
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer token-2"))
+		})
+	})
+
+	Describe("Retries", func() {
+		It("should retry 5xx and 429 responses", func() {
+			rcv.server.Close()
+			rcv = newReceiver(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+
+			Expect(newExporter(nil).Export(context.Background(), testMetrics())).To(Succeed())
+			Expect(rcv.calls.Load()).To(BeEquivalentTo(3))
+		})
+
+		It("should give up after MaxRetries", func() {
+			rcv.server.Close()
+			rcv = newReceiver(500, 500, 500)
+
+			err := newExporter(func(c *Config) { c.MaxRetries = 2 }).Export(context.Background(), testMetrics())
+			Expect(err).To(MatchError(ContainSubstring("after 3 attempt(s)")))
+			Expect(err).To(MatchError(ContainSubstring("HTTP 500: receiver error")))
+			Expect(rcv.calls.Load()).To(BeEquivalentTo(3))
+		})
+
+		It("should not retry other 4xx responses", func() {
+			rcv.server.Close()
+			rcv = newReceiver(http.StatusBadRequest)
+
+			err := newExporter(nil).Export(context.Background(), testMetrics())
+			Expect(err).To(MatchError(ContainSubstring("HTTP 400")))
+			Expect(rcv.calls.Load()).To(BeEquivalentTo(1))
+		})
+
+		It("should retry network errors and stop when the context is done", func() {
+			url := rcv.server.URL
+			rcv.server.Close()
+			exporter := newExporter(func(c *Config) {
+				c.URL = url
+				c.MaxRetries = 100
+				c.MinBackoff = 10 * time.Millisecond
+				c.MaxBackoff = 10 * time.Millisecond
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			Expect(exporter.Export(ctx, testMetrics())).To(MatchError(context.DeadlineExceeded))
+		})
+
+		It("should back off exponentially up to MaxBackoff", func() {
+			exporter := newExporter(func(c *Config) {
+				c.MinBackoff = 100 * time.Millisecond
+				c.MaxBackoff = time.Second
+			})
+			Expect(exporter.backoff(0)).To(Equal(100 * time.Millisecond))
+			Expect(exporter.backoff(1)).To(Equal(200 * time.Millisecond))
+			Expect(exporter.backoff(3)).To(Equal(800 * time.Millisecond))
+			Expect(exporter.backoff(10)).To(Equal(time.Second))
+		})
+
+		It("should parse Retry-After seconds", func() {
+			Expect(retryAfter("3")).To(Equal(3 * time.Second))
+			Expect(retryAfter("")).To(BeZero())
+			Expect(retryAfter("Wed, 21 Oct 2015 07:28:00 GMT")).To(BeZero())
+		})
+	})
+
+	Describe("Config", func() {
+		BeforeEach(func() {
+			for _, key := range []string{"URL", "USERNAME", "PASSWORD", "BEARER_TOKEN", "BEARER_TOKEN_FILE",
+				"TIMEOUT", "MIN_BACKOFF", "MAX_BACKOFF", "MAX_RETRIES"} {
+				GinkgoT().Setenv("PROMETHEUS_REMOTE_WRITE_"+key, "")
+			}
+		})
+
+		It("should load the configuration from the environment", func() {
+			GinkgoT().Setenv("PROMETHEUS_REMOTE_WRITE_URL", "https://prometheus:9090/api/v1/write")
+			GinkgoT().Setenv("PROMETHEUS_REMOTE_WRITE_BEARER_TOKEN", "t")
+			GinkgoT().Setenv("PROMETHEUS_REMOTE_WRITE_TIMEOUT", "3s")
+			GinkgoT().Setenv("PROMETHEUS_REMOTE_WRITE_MAX_RETRIES", "5")
+
+			cfg, err := LoadConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.URL).To(Equal("https://prometheus:9090/api/v1/write"))
+			Expect(cfg.BearerToken).To(Equal("t"))
+			Expect(cfg.Timeout).To(Equal(3 * time.Second))
+			Expect(cfg.MaxRetries).To(Equal(5))
+			Expect(cfg.MaxBackoff).To(Equal(DefaultConfig().MaxBackoff))
+		})
+
+		It("should require a URL", func() {
+			_, err := LoadConfig()
+			Expect(err).To(MatchError(ContainSubstring("URL is required")))
+		})
+
+		It("should reject invalid settings", func() {
+			GinkgoT().Setenv("PROMETHEUS_REMOTE_WRITE_URL", "http://prometheus/api/v1/write")
+			GinkgoT().Setenv("PROMETHEUS_REMOTE_WRITE_TIMEOUT", "soon")
+			_, err := LoadConfig()
+			Expect(err).To(MatchError(ContainSubstring("PROMETHEUS_REMOTE_WRITE_TIMEOUT")))
+
+			cfg := DefaultConfig()
+			cfg.URL = "prometheus:9090"
+			Expect(cfg.Validate()).To(MatchError(ContainSubstring("invalid remote-write URL")))
+
+			cfg.URL = "http://prometheus/api/v1/write"
+			cfg.Username, cfg.BearerToken = "user", "token"
+			Expect(cfg.Validate()).To(MatchError(ContainSubstring("mutually exclusive")))
+		})
+	})
+
+	Describe("Names", func() {
+		It("should sanitize metric and label names", func() {
+			Expect(sanitizeName("http.server.duration", true)).To(Equal("http_server_duration"))
+			Expect(sanitizeName("job:rate5m", true)).To(Equal("job:rate5m"))
+			Expect(sanitizeName("job:rate5m", false)).To(Equal("job_rate5m"))
+			Expect(sanitizeName("2xx", false)).To(Equal("_2xx"))
+		})
+
+		It("should map UCUM units to Prometheus suffixes", func() {
+			Expect(promUnit("s")).To(Equal("seconds"))
+			Expect(promUnit("By/s")).To(Equal("bytes_per_second"))
+			Expect(promUnit("{request}")).To(BeEmpty())
+			Expect(promUnit("1")).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,266 @@
+// Package rules generates a monitoring.coreos.com/v1 PrometheusRule for the app
+// from the metric names and SLO definitions in code, so alerts can't drift
+// from what the app actually emits.
+package rules
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/slo"
+	"go.yaml.in/yaml/v3"
+)
+
+// PrometheusRule is the subset of the Prometheus Operator CRD we generate
+type PrometheusRule struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   ObjectMeta `yaml:"metadata"`
+	Spec       RuleSpec   `yaml:"spec"`
+}
+
+// ObjectMeta is the Kubernetes object metadata of the rule
+type ObjectMeta struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+// RuleSpec holds the rule groups
+type RuleSpec struct {
+	Groups []RuleGroup `yaml:"groups"`
+}
+
+// RuleGroup is a named group of recording and alerting rules
+type RuleGroup struct {
+	Name     string `yaml:"name"`
+	Interval string `yaml:"interval,omitempty"`
+	Rules    []Rule `yaml:"rules"`
+}
+
+// Rule is either a recording rule (Record) or an alerting rule (Alert)
+type Rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Options controls what is generated
+type Options struct {
+	// Name of the PrometheusRule object
+	Name string
+	// Namespace of the PrometheusRule object (empty to leave it to kubectl/Helm)
+	Namespace string
+	// Labels added to the PrometheusRule object (e.g. the Prometheus ruleSelector labels)
+	Labels map[string]string
+	// Job is the Prometheus job label the app's metrics are scraped with
+	Job string
+	// ErrorRatioThreshold fires the error alert above this fraction of 5xx responses
+	ErrorRatioThreshold float64
+	// LatencyP99Threshold fires the latency alert above this p99 request duration
+	LatencyP99Threshold time.Duration
+	// SLOs are turned into multi-window burn-rate alerts
+	SLOs []slo.Definition
+}
+
+// DefaultOptions returns the options used by `rules generate` without flags
+func DefaultOptions() Options {
+	return Options{
+		Name: "dm-nkp-gitops-custom-app",
+		Labels: map[string]string{
+			"app.kubernetes.io/name":      "dm-nkp-gitops-custom-app",
+			"app.kubernetes.io/component": "alerting",
+		},
+		Job:                 "dm-nkp-gitops-custom-app",
+		ErrorRatioThreshold: 0.05,
+		LatencyP99Threshold: 500 * time.Millisecond,
+		SLOs:                slo.DefaultDefinitions(),
+	}
+}
+
+// Recording rule names, reused by the alerts
+const (
+	requestRateRecord  = "job:http_requests:rate5m"
+	errorRatioRecord   = "job:http_requests_error_ratio:rate5m"
+	latencyP50Record   = "job:http_request_duration_seconds:p50_5m"
+	latencyP95Record   = "job:http_request_duration_seconds:p95_5m"
+	latencyP99Record   = "job:http_request_duration_seconds:p99_5m"
+	latencyMeanRecord  = "job:http_request_duration_seconds:mean5m"
+	responseSizeRecord = "job:http_response_size_bytes:mean5m"
+)
+
+// burnRateAlert is one short/long window pair of the multi-window, multi-burn-rate
+// alerting approach (Google SRE workbook, chapter 5)
+type burnRateAlert struct {
+	long, short time.Duration
+	factor      float64
+	forDuration string
+	severity    string
+}
+
+var burnRateAlerts = []burnRateAlert{
+	{long: time.Hour, short: 5 * time.Minute, factor: 14.4, forDuration: "2m", severity: "critical"},
+	{long: 6 * time.Hour, short: 30 * time.Minute, factor: 6, forDuration: "15m", severity: "critical"},
+	{long: 24 * time.Hour, short: 2 * time.Hour, factor: 3, forDuration: "1h", severity: "warning"},
+	{long: 72 * time.Hour, short: 6 * time.Hour, factor: 1, forDuration: "3h", severity: "warning"},
+}
+
+// Generate builds the PrometheusRule for the given options
+func Generate(opts Options) PrometheusRule {
+	rule := PrometheusRule{
+		APIVersion: "monitoring.coreos.com/v1",
+		Kind:       "PrometheusRule",
+		Metadata: ObjectMeta{
+			Name:      opts.Name,
+			Namespace: opts.Namespace,
+			Labels:    opts.Labels,
+		},
+	}
+
+	rule.Spec.Groups = append(rule.Spec.Groups, redRecordingRules(opts), redAlerts(opts))
+	if group, ok := sloAlerts(opts); ok {
+		rule.Spec.Groups = append(rule.Spec.Groups, group)
+	}
+	return rule
+}
+
+// Render writes the rule as YAML
+func Render(w io.Writer, rule PrometheusRule) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(rule); err != nil {
+		return fmt.Errorf("failed to render PrometheusRule: %w", err)
+	}
+	return enc.Close()
+}
+
+// redRecordingRules precomputes rate, errors and duration per job
+func redRecordingRules(opts Options) RuleGroup {
+	sel := fmt.Sprintf(`job=%q`, opts.Job)
+	duration := func(q string) string {
+		return fmt.Sprintf("histogram_quantile(%s, sum by (job, le) (rate(%s_bucket{%s}[5m])))",
+			q, metrics.RequestDurationName, sel)
+	}
+
+	return RuleGroup{
+		Name: opts.Name + ".red.rules",
+		Rules: []Rule{
+			{
+				Record: requestRateRecord,
+				Expr:   fmt.Sprintf("sum by (job) (rate(%s{%s}[5m]))", metrics.RequestsTotalName, sel),
+			},
+			{
+				Record: errorRatioRecord,
+				Expr: fmt.Sprintf(`sum by (job) (rate(%[1]s{%[2]s,status=~"5.."}[5m])) / sum by (job) (rate(%[1]s{%[2]s}[5m]))`,
+					metrics.RequestsByMethodTotalName, sel),
+			},
+			{Record: latencyP50Record, Expr: duration("0.5")},
+			{Record: latencyP95Record, Expr: duration("0.95")},
+			{Record: latencyP99Record, Expr: duration("0.99")},
+			{
+				Record: latencyMeanRecord,
+				Expr: fmt.Sprintf("sum by (job) (rate(%[1]s_sum{%[2]s}[5m])) / sum by (job) (rate(%[1]s_count{%[2]s}[5m]))",
+					metrics.RequestDurationName, sel),
+			},
+			{
+				Record: responseSizeRecord,
+				Expr: fmt.Sprintf("sum by (job) (rate(%[1]s_sum{%[2]s}[5m])) / sum by (job) (rate(%[1]s_count{%[2]s}[5m]))",
+					metrics.ResponseSizeName, sel),
+			},
+		},
+	}
+}
+
+// redAlerts fires on error ratio, latency and missing metrics
+func redAlerts(opts Options) RuleGroup {
+	sel := fmt.Sprintf(`job=%q`, opts.Job)
+	return RuleGroup{
+		Name: opts.Name + ".alerts",
+		Rules: []Rule{
+			{
+				Alert:  "HTTPHighErrorRatio",
+				Expr:   fmt.Sprintf("%s{%s} > %s", errorRatioRecord, sel, formatFloat(opts.ErrorRatioThreshold)),
+				For:    "5m",
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary": "High HTTP error ratio",
+					"description": fmt.Sprintf("More than %s%% of requests to {{ $labels.job }} fail with 5xx (current: {{ $value | humanizePercentage }}).",
+						formatFloat(opts.ErrorRatioThreshold*100)),
+				},
+			},
+			{
+				Alert:  "HTTPHighLatencyP99",
+				Expr:   fmt.Sprintf("%s{%s} > %s", latencyP99Record, sel, formatFloat(opts.LatencyP99Threshold.Seconds())),
+				For:    "10m",
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary": "High HTTP p99 latency",
+					"description": fmt.Sprintf("p99 request duration of {{ $labels.job }} is above %s (current: {{ $value | humanizeDuration }}).",
+						opts.LatencyP99Threshold),
+				},
+			},
+			{
+				Alert:  "HTTPMetricsAbsent",
+				Expr:   fmt.Sprintf("absent(%s{%s})", metrics.RequestsTotalName, sel),
+				For:    "15m",
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary":     "HTTP metrics missing",
+					"description": fmt.Sprintf("No %s series for job %s: the app or the metrics pipeline is down, or the metric was renamed.", metrics.RequestsTotalName, opts.Job),
+				},
+			},
+		},
+	}
+}
+
+// sloAlerts builds multi-window burn-rate alerts from the slo_burn_rate gauges
+// the app exports for every SLO
+func sloAlerts(opts Options) (RuleGroup, bool) {
+	group := RuleGroup{Name: opts.Name + ".slo.alerts"}
+
+	defs := append([]slo.Definition(nil), opts.SLOs...)
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+
+	for _, def := range defs {
+		for _, a := range burnRateAlerts {
+			if a.long > time.Duration(def.Window) {
+				continue
+			}
+			burnRate := func(window time.Duration) string {
+				return fmt.Sprintf(`%s{job=%q,slo=%q,window=%q} > %s`,
+					slo.BurnRateName, opts.Job, def.Name, slo.Duration(window), formatFloat(a.factor))
+			}
+			group.Rules = append(group.Rules, Rule{
+				Alert: "SLOErrorBudgetBurn",
+				Expr:  burnRate(a.long) + " and " + burnRate(a.short),
+				For:   a.forDuration,
+				Labels: map[string]string{
+					"severity":    a.severity,
+					"slo":         def.Name,
+					"long_window": slo.Duration(a.long).String(),
+				},
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("SLO %s is burning its error budget too fast", def.Name),
+					"description": fmt.Sprintf("SLO %s (target %s%% over %s) burns its error budget at more than %sx over %s and %s.",
+						def.Name, formatFloat(def.Target*100), def.Window, formatFloat(a.factor),
+						slo.Duration(a.long), slo.Duration(a.short)),
+				},
+			})
+		}
+	}
+	return group, len(group.Rules) > 0
+}
+
+// formatFloat prints floats without trailing zeros (0.05, 14.4, 1)
+func formatFloat(f float64) string {
+	s := fmt.Sprintf("%.6f", f)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
@@ -0,0 +1,108 @@
+package rules
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/slo"
+)
+
+// Regenerate the golden files with: go test ./internal/rules/ -update
+var update = flag.Bool("update", false, "update golden files")
+
+func TestRules(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Rules Suite")
+}
+
+// expectGolden compares the rendered rule with testdata/<name>, rewriting it with -update
+func expectGolden(name string, rule PrometheusRule) {
+	var buf bytes.Buffer
+	Expect(Render(&buf, rule)).To(Succeed())
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		Expect(os.WriteFile(path, buf.Bytes(), 0o644)).To(Succeed())
+	}
+	golden, err := os.ReadFile(path)
+	Expect(err).NotTo(HaveOccurred(), "run `go test ./internal/rules/ -update` to create %s", path)
+	Expect(buf.String()).To(Equal(string(golden)), "output drifted from %s; rerun with -update if intended", path)
+}
+
+var _ = Describe("Generate", func() {
+	It("should match the golden file for the default options", func() {
+		expectGolden("default.golden.yaml", Generate(DefaultOptions()))
+	})
+
+	It("should match the golden file for custom thresholds and SLOs", func() {
+		opts := DefaultOptions()
+		opts.Name = "custom"
+		opts.Namespace = "monitoring"
+		opts.Labels = map[string]string{"release": "kube-prometheus-stack"}
+		opts.Job = "custom-job"
+		opts.ErrorRatioThreshold = 0.01
+		opts.LatencyP99Threshold = 250 * time.Millisecond
+		opts.SLOs = []slo.Definition{{
+			Name:      "short-window",
+			Target:    0.95,
+			Window:    slo.Duration(6 * time.Hour),
+			BadStatus: []string{"5xx"},
+		}}
+		expectGolden("custom.golden.yaml", Generate(opts))
+	})
+
+	It("should produce a valid PrometheusRule document", func() {
+		var buf bytes.Buffer
+		Expect(Render(&buf, Generate(DefaultOptions()))).To(Succeed())
+
+		var doc map[string]interface{}
+		Expect(yaml.Unmarshal(buf.Bytes(), &doc)).To(Succeed())
+		Expect(doc).To(HaveKeyWithValue("apiVersion", "monitoring.coreos.com/v1"))
+		Expect(doc).To(HaveKeyWithValue("kind", "PrometheusRule"))
+	})
+
+	It("should query the metric names the app emits", func() {
+		var buf bytes.Buffer
+		Expect(Render(&buf, Generate(DefaultOptions()))).To(Succeed())
+
+		for _, name := range []string{
+			metrics.RequestsTotalName,
+			metrics.RequestsByMethodTotalName,
+			metrics.RequestDurationName,
+			metrics.ResponseSizeName,
+			slo.BurnRateName,
+		} {
+			Expect(buf.String()).To(ContainSubstring(name))
+		}
+	})
+
+	It("should skip burn-rate pairs longer than the SLO window", func() {
+		opts := DefaultOptions()
+		opts.SLOs = []slo.Definition{{
+			Name:      "hourly",
+			Target:    0.9,
+			Window:    slo.Duration(time.Hour),
+			BadStatus: []string{"5xx"},
+		}}
+
+		rule := Generate(opts)
+		Expect(rule.Spec.Groups).To(HaveLen(3))
+		Expect(rule.Spec.Groups[2].Rules).To(HaveLen(1))
+		Expect(rule.Spec.Groups[2].Rules[0].Labels).To(HaveKeyWithValue("long_window", "1h"))
+	})
+
+	It("should omit the SLO group without SLOs", func() {
+		opts := DefaultOptions()
+		opts.SLOs = nil
+		Expect(Generate(opts).Spec.Groups).To(HaveLen(2))
+	})
+})
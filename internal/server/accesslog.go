@@ -0,0 +1,215 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Access log formats accepted by ACCESS_LOG_FORMAT. They set the body of the
+// access log record; the attributes are the same in every format.
+const (
+	// AccessLogCommon is the NCSA Common Log Format
+	AccessLogCommon = "common"
+	// AccessLogCombined is the Common Log Format plus referer and user agent (the default)
+	AccessLogCombined = "combined"
+	// AccessLogJSON is one JSON object per request
+	AccessLogJSON = "json"
+	// AccessLogOff disables the access log
+	AccessLogOff = "off"
+)
+
+// clfTimeLayout is the timestamp layout of the Common Log Format
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// parseAccessLogFormat parses common, combined, json or off, case-insensitively
+func parseAccessLogFormat(s string) (string, error) {
+	switch format := strings.ToLower(strings.TrimSpace(s)); format {
+	case AccessLogCommon, AccessLogCombined, AccessLogJSON, AccessLogOff:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid access log format %q (want %s, %s, %s or %s)",
+			s, AccessLogCommon, AccessLogCombined, AccessLogJSON, AccessLogOff)
+	}
+}
+
+// resolveAccessLogFormat parses the configured format, falling back to
+// ACCESS_LOG_FORMAT when it is empty, and to combined when that is empty or
+// either is invalid
+func resolveAccessLogFormat(configured string) string {
+	value, source := configured, "access log format"
+	if value == "" {
+		value, source = os.Getenv("ACCESS_LOG_FORMAT"), "ACCESS_LOG_FORMAT"
+	}
+	if value == "" {
+		return AccessLogCombined
+	}
+	format, err := parseAccessLogFormat(value)
+	if err != nil {
+		telemetry.ScopedLogger(telemetry.ScopeServer).Warn("Invalid "+source+", using "+AccessLogCombined,
+			slog.String(telemetry.ErrorKey, err.Error()))
+		return AccessLogCombined
+	}
+	return format
+}
+
+// accessEntry is what the access log records about a request
+type accessEntry struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Route      string    `json:"route"`
+	Path       string    `json:"path"`
+	Query      string    `json:"query,omitempty"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMs float64   `json:"duration_ms"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Referer    string    `json:"referer,omitempty"`
+	ClientIP   string    `json:"client_ip"`
+	TraceID    string    `json:"trace_id,omitempty"`
+}
+
+// newAccessEntry collects a request's fields; pattern is the mux pattern that
+// served it
+func newAccessEntry(r *http.Request, pattern string, start time.Time, rec *responseRecorder) accessEntry {
+	e := accessEntry{
+		Time:       start,
+		Method:     r.Method,
+		Route:      httpRoute(pattern),
+		Path:       r.URL.Path,
+		Proto:      r.Proto,
+		Status:     rec.status,
+		Bytes:      rec.bytes,
+		DurationMs: float64(time.Since(start).Nanoseconds()) / 1e6,
+		Query:      r.URL.RawQuery,
+		UserAgent:  r.UserAgent(),
+		Referer:    r.Referer(),
+		ClientIP:   clientIP(r),
+	}
+	if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+		e.TraceID = sc.TraceID().String()
+	}
+	return e
+}
+
+// redacted applies the telemetry redaction rules to the fields that end up in the
+// record body. The attributes are redacted by the log pipeline itself.
+func (e accessEntry) redacted() accessEntry {
+	e.Query = redact(telemetry.URLQueryKey, e.Query)
+	e.UserAgent = redact(telemetry.UserAgentKey, e.UserAgent)
+	e.Referer = redact(telemetry.HTTPRefererKey, e.Referer)
+	e.ClientIP = redact(telemetry.ClientAddressKey, e.ClientIP)
+	return e
+}
+
+func redact(key, value string) string {
+	if value == "" {
+		return ""
+	}
+	if value, ok := telemetry.RedactValue(key, value); ok {
+		return value
+	}
+	return ""
+}
+
+// clientIP is the host of the request's remote address
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// line formats the redacted entry as the record body
+func (e accessEntry) line(format string) string {
+	e = e.redacted()
+	if format == AccessLogJSON {
+		b, _ := json.Marshal(e)
+		return string(b)
+	}
+
+	target := e.Path
+	if e.Query != "" {
+		target += "?" + e.Query
+	}
+	bytes := "-"
+	if e.Bytes > 0 {
+		bytes = strconv.FormatInt(e.Bytes, 10)
+	}
+	line := fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %s`,
+		orDash(e.ClientIP), e.Time.Format(clfTimeLayout), e.Method, target, e.Proto, e.Status, bytes)
+	if format == AccessLogCombined {
+		line += fmt.Sprintf(` %s %s`, strconv.Quote(orDash(e.Referer)), strconv.Quote(orDash(e.UserAgent)))
+	}
+	return line
+}
+
+// attrs are the typed record attributes, the same in every format
+func (e accessEntry) attrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String(telemetry.HTTPMethodKey, e.Method),
+		slog.String(telemetry.HTTPRouteKey, e.Route),
+		slog.String(telemetry.URLPathKey, e.Path),
+		slog.Int(telemetry.HTTPStatusCodeKey, e.Status),
+		slog.Int64(telemetry.ResponseBodySizeKey, e.Bytes),
+		slog.Float64(telemetry.DurationMsKey, e.DurationMs),
+		slog.String(telemetry.ClientAddressKey, e.ClientIP),
+	}
+	for _, a := range []slog.Attr{
+		slog.String(telemetry.URLQueryKey, e.Query),
+		slog.String(telemetry.UserAgentKey, e.UserAgent),
+		slog.String(telemetry.HTTPRefererKey, e.Referer),
+		slog.String(telemetry.TraceIDKey, e.TraceID),
+	} {
+		if a.Value.String() != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	return attrs
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// accessLogMiddleware logs one record per request in the access scope, at warn
+// for 5xx responses and info otherwise. Records are sampled per method, route and
+// status rather than per line (see LOG_SAMPLING_*). routes resolves the route of
+// the record.
+func accessLogMiddleware(next http.Handler, format string, logger *slog.Logger, routes routeResolver) http.Handler {
+	if format == AccessLogOff {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newResponseRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelWarn
+		}
+		ctx := r.Context()
+		if !logger.Enabled(ctx, level) {
+			return
+		}
+		pattern, _ := routes.resolve(r)
+		e := newAccessEntry(r, pattern, start, rec)
+		ctx = telemetry.ContextWithSamplingKey(ctx, fmt.Sprintf("access %s %s %d", e.Method, e.Route, e.Status))
+		logger.LogAttrs(ctx, level, e.line(format), e.attrs()...)
+	})
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/slo"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
+)
+
+// metricsFlushTimeout bounds how long the flush endpoint waits for the exporter
+const metricsFlushTimeout = 10 * time.Second
+
+// flushMetrics exports pending metrics; tests replace it to make flushes fail
+var flushMetrics = metrics.ForceFlush
+
+// handleMetricsFlush forces an immediate export of all pending metrics.
+// Useful for short-lived runs (e.g. e2e tests) that can't wait for the next export interval.
+func handleMetricsFlush(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), metricsFlushTimeout)
+	defer cancel()
+
+	telemetry.LogInfo(ctx, "Metrics flush requested via admin endpoint")
+	if err := flushMetrics(ctx); err != nil {
+		telemetry.LogError(ctx, "Metrics flush failed", err)
+		body, _ := json.Marshal(map[string]string{"status": "error", "error": err.Error()})
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write(body)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprint(w, `{"status": "flushed"}`)
+}
+
+// handleSLO reports the current compliance, error budget and burn rates of every SLO
+func handleSLO(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	body, err := json.Marshal(map[string]interface{}{"slos": slo.Statuses()})
+	if err != nil {
+		telemetry.LogError(r.Context(), "Failed to encode SLO report", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprint(w, `{"error": "failed to encode SLO report"}`)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// handleMetricsCatalog describes every instrument the app registers. It serves
+// JSON by default and a Markdown table for ?format=markdown or Accept: text/markdown.
+func handleMetricsCatalog(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") == "markdown" || strings.Contains(r.Header.Get("Accept"), "text/markdown") {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		if err := metrics.WriteCatalogMarkdown(w); err != nil {
+			telemetry.LogError(r.Context(), "Failed to write metrics catalog", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	body, err := json.Marshal(map[string]interface{}{"instruments": metrics.Catalog()})
+	if err != nil {
+		telemetry.LogError(r.Context(), "Failed to encode metrics catalog", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprint(w, `{"error": "failed to encode metrics catalog"}`)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// logLevelRequest is the body of PUT /admin/loglevel. TTL is a Go duration
+// ("10m"); it defaults to telemetry.DefaultLogLevelTTL.
+type logLevelRequest struct {
+	Level  string            `json:"level"`
+	Scopes map[string]string `json:"scopes"`
+	TTL    string            `json:"ttl"`
+}
+
+// maxLogLevelBody bounds the PUT /admin/loglevel body
+const maxLogLevelBody = 4096
+
+// handleLogLevel reports (GET), changes (PUT) or resets (DELETE) the log levels.
+// Changes revert on their own once their TTL expires. The mux routes only these
+// three methods here.
+func handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var config telemetry.LogLevelConfig
+	switch r.Method {
+	case http.MethodGet:
+		config = telemetry.CurrentLogLevels()
+	case http.MethodPut:
+		var req logLevelRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLogLevelBody)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+		var ttl time.Duration
+		if req.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(req.TTL); err != nil {
+				writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid ttl: %v", err))
+				return
+			}
+		}
+		var err error
+		if config, err = telemetry.SetLogLevels(r.Context(), req.Level, req.Scopes, ttl); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	case http.MethodDelete:
+		config = telemetry.ResetLogLevels(r.Context())
+	}
+
+	body, err := json.Marshal(config)
+	if err != nil {
+		telemetry.LogError(r.Context(), "Failed to encode log levels", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to encode log levels")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// writeJSONError writes {"error": message} with the given status. Invalid
+// UTF-8 in message is replaced, as the JSON encoder does.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	body, _ := json.Marshal(map[string]string{"error": message})
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
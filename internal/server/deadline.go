@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
+	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultRouteTimeout is the deadline of a request when its route has no
+// timeout of its own. It is below the default write timeout, so the 504 still
+// reaches the client.
+const DefaultRouteTimeout = 10 * time.Second
+
+// StatusClientClosedRequest is the status of requests the client cancelled
+// before the response was written (nginx's 499). The client never sees it; it
+// sets them apart in the access log, the SLOs and the request metrics.
+const StatusClientClosedRequest = 499
+
+// error.type of the request span when its context ended before the response
+const (
+	errorTypeDeadlineExceeded = "deadline_exceeded"
+	errorTypeClientCancelled  = "client_cancelled"
+)
+
+// routeDeadlines gives each request the timeout budget of its route as a
+// context deadline
+type routeDeadlines struct {
+	// routes resolves the route of a request; admin routes sharing the
+	// listener get no deadline, as profiles run for as long as asked
+	routes   routeResolver
+	fallback time.Duration
+	timeouts map[string]time.Duration
+}
+
+// middleware runs the request with the deadline of its route. Handlers return
+// without writing once their context is done (see sleepContext); the response
+// is then a 504 JSON error when the deadline passed, or 499 when the client went
+// away, with error.type deadline_exceeded or client_cancelled on the span and
+// the status in http_requests_by_method_total.
+func (d *routeDeadlines) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pattern, admin := d.routes.resolve(r)
+		timeout, ok := d.timeouts[pattern]
+		if !ok {
+			timeout = d.fallback
+		}
+		ctx := r.Context()
+		if timeout > 0 && !admin {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		rec := newResponseRecorder(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		if rec.wroteHeader || ctx.Err() == nil {
+			return
+		}
+
+		status := contextErrorStatus(ctx.Err())
+		metrics.IncrementRequestCounterVec(r.Method, strconv.Itoa(status))
+		span := trace.SpanFromContext(ctx)
+		if status == http.StatusGatewayTimeout {
+			span.SetAttributes(semconv.ErrorTypeKey.String(errorTypeDeadlineExceeded))
+			telemetry.LogWarn(ctx, "Request deadline exceeded", map[string]string{
+				telemetry.HTTPRouteKey: d.routes.route(pattern),
+				telemetry.URLPathKey:   r.URL.Path,
+			})
+			w.Header().Set("Content-Type", "application/json")
+			writeJSONError(w, status, fmt.Sprintf("deadline of %s exceeded", timeout))
+			return
+		}
+		span.SetAttributes(semconv.ErrorTypeKey.String(errorTypeClientCancelled))
+		w.WriteHeader(status)
+	})
+}
+
+// contextErrorStatus is the status of a request whose context ended with err:
+// 504 when its deadline passed, 499 when the client went away
+func contextErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return StatusClientClosedRequest
+}
+
+// sleepContext waits for d, or returns ctx.Err() as soon as ctx is done. It is
+// how handlers wait on work: once the request is cancelled or out of time they
+// stop and return without writing, and the deadline middleware answers.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RouteTimeoutsFromEnv returns the options of ROUTE_TIMEOUT, the timeout of
+// every route (a Go duration, "0" for none), and ROUTE_TIMEOUTS, the timeouts of
+// single routes as comma-separated pattern=duration pairs
+// ("GET /{$}=2s,GET /items/{id}=500ms").
+func RouteTimeoutsFromEnv() ([]Option, error) {
+	var opts []Option
+	if value := os.Getenv("ROUTE_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("ROUTE_TIMEOUT: invalid duration %q", value)
+		}
+		opts = append(opts, WithDefaultRouteTimeout(timeout))
+	}
+	for _, pair := range strings.Split(os.Getenv("ROUTE_TIMEOUTS"), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		pattern, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("ROUTE_TIMEOUTS: %q is not pattern=duration", pair)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("ROUTE_TIMEOUTS: invalid duration %q for %q", value, pattern)
+		}
+		opts = append(opts, WithRouteTimeout(strings.TrimSpace(pattern), timeout))
+	}
+	return opts, nil
+}
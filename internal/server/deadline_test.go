@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/slo"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var _ = Describe("Route deadlines", func() {
+	var exporter *tracetest.InMemoryExporter
+
+	// newServer serves GET /slow, which works for a second unless cancelled
+	newServer := func(opts ...Option) *Server {
+		exporter = tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		DeferCleanup(provider.Shutdown, context.Background())
+		slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := sleepContext(r.Context(), time.Second); err != nil {
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+		return New(append([]Option{
+			WithAccessLog(AccessLogOff, nil), WithTracerProvider(provider), WithHandler("GET /slow", slow),
+		}, opts...)...)
+	}
+
+	serve := func(s *Server, req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// serverSpan is the otelhttp span of the last request
+	serverSpan := func() sdktrace.ReadOnlySpan {
+		spans := exporter.GetSpans().Snapshots()
+		return spans[len(spans)-1]
+	}
+
+	It("should answer 504 once the route's deadline passes", func() {
+		s := newServer(WithRouteTimeout("GET /slow", 20*time.Millisecond))
+		start := time.Now()
+		w := serve(s, httptest.NewRequest("GET", "/slow", nil))
+		Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+		Expect(w.Code).To(Equal(http.StatusGatewayTimeout))
+		Expect(w.Body.String()).To(MatchJSON(`{"error": "deadline of 20ms exceeded"}`))
+		Expect(serverSpan().Attributes()).To(ContainElements(
+			attribute.String("error.type", "deadline_exceeded"),
+			attribute.Int("http.status_code", http.StatusGatewayTimeout),
+		))
+	})
+
+	It("should apply the default timeout to the built-in routes, but not to the admin ones", func() {
+		s := newServer(WithDefaultRouteTimeout(time.Millisecond))
+		Expect(serve(s, httptest.NewRequest("GET", "/", nil)).Code).To(Equal(http.StatusGatewayTimeout))
+		Expect(serve(s, httptest.NewRequest("GET", "/health", nil)).Code).To(Equal(http.StatusOK))
+		Expect(serve(s, httptest.NewRequest("GET", "/ready", nil)).Code).To(Equal(http.StatusOK))
+	})
+
+	It("should record requests the client cancelled as 499", func() {
+		s := newServer()
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		w := serve(s, httptest.NewRequest("GET", "/slow", nil).WithContext(ctx))
+		Expect(w.Code).To(Equal(StatusClientClosedRequest))
+		Expect(serverSpan().Attributes()).To(ContainElements(
+			attribute.String("error.type", "client_cancelled"),
+			attribute.Int("http.status_code", StatusClientClosedRequest),
+		))
+	})
+
+	It("should leave responses written in time alone", func() {
+		s := newServer(WithRouteTimeout("GET /slow", 0), WithDefaultRouteTimeout(time.Millisecond))
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		Expect(serve(s, httptest.NewRequest("GET", "/slow", nil).WithContext(ctx)).Code).To(Equal(http.StatusOK))
+	})
+
+	// recordCounter points counter at a manual reader for the test and returns
+	// the sum of each attribute set
+	recordCounter := func(counter *metric.Int64Counter) func() map[attribute.Set]int64 {
+		reader := sdkmetric.NewManualReader()
+		provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+		DeferCleanup(provider.Shutdown, context.Background())
+		previous := *counter
+		DeferCleanup(func() { *counter = previous })
+		var err error
+		*counter, err = provider.Meter("test").Int64Counter("counter")
+		Expect(err).NotTo(HaveOccurred())
+
+		return func() map[attribute.Set]int64 {
+			var rm metricdata.ResourceMetrics
+			Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+			sums := make(map[attribute.Set]int64)
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+						sums[dp.Attributes] = dp.Value
+					}
+				}
+			}
+			return sums
+		}
+	}
+
+	It("should count 504 and 499 responses of every route in the request metrics", func() {
+		sums := recordCounter(&metrics.RequestCounterVec)
+		s := newServer(WithRouteTimeout("GET /slow", 20*time.Millisecond))
+		serve(s, httptest.NewRequest("GET", "/slow", nil))
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		serve(s, httptest.NewRequest("GET", "/slow", nil).WithContext(ctx))
+
+		Expect(sums()).To(Equal(map[attribute.Set]int64{
+			attribute.NewSet(attribute.String("method", "GET"), attribute.String("status", "504")): 1,
+			attribute.NewSet(attribute.String("method", "GET"), attribute.String("status", "499")): 1,
+		}))
+	})
+
+	It("should leave the route to the SLOs, the access log and the panic counter", func() {
+		Expect(slo.Initialize()).To(Succeed())
+		panics := recordCounter(&metrics.PanicCounter)
+		var logs bytes.Buffer
+		s := New(WithAccessLog(AccessLogCommon, slog.New(slog.NewJSONHandler(&logs, nil))),
+			WithHandler("GET /panic", http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("boom") })))
+
+		for range 3 {
+			Expect(serve(s, httptest.NewRequest("GET", "/", nil)).Code).To(Equal(http.StatusOK))
+		}
+		statuses := slo.Statuses()
+		Expect(statuses).NotTo(BeEmpty())
+		for _, status := range statuses {
+			Expect(status.TotalEvents).To(BeEquivalentTo(3), status.Name)
+		}
+
+		decoder := json.NewDecoder(&logs)
+		for range 3 {
+			var record map[string]any
+			Expect(decoder.Decode(&record)).To(Succeed())
+			Expect(record).To(HaveKeyWithValue(telemetry.HTTPRouteKey, "/"))
+		}
+
+		Expect(serve(s, httptest.NewRequest("GET", "/panic", nil)).Code).To(Equal(http.StatusInternalServerError))
+		Expect(panics()).To(Equal(map[attribute.Set]int64{
+			attribute.NewSet(attribute.String("route", "/panic")): 1,
+		}))
+	})
+
+	It("should read the timeouts from the environment", func() {
+		os.Setenv("ROUTE_TIMEOUT", "5s")
+		DeferCleanup(os.Unsetenv, "ROUTE_TIMEOUT")
+		os.Setenv("ROUTE_TIMEOUTS", "GET /{$}=2s, GET /items/{id}=500ms")
+		DeferCleanup(os.Unsetenv, "ROUTE_TIMEOUTS")
+
+		opts, err := RouteTimeoutsFromEnv()
+		Expect(err).NotTo(HaveOccurred())
+		cfg := defaultConfig()
+		for _, opt := range opts {
+			opt(&cfg)
+		}
+		Expect(cfg.routeTimeout).To(Equal(5 * time.Second))
+		Expect(cfg.routeTimeouts).To(Equal(map[string]time.Duration{
+			"GET /{$}":        2 * time.Second,
+			"GET /items/{id}": 500 * time.Millisecond,
+		}))
+
+		os.Setenv("ROUTE_TIMEOUTS", "GET /{$}")
+		_, err = RouteTimeoutsFromEnv()
+		Expect(err).To(MatchError(ContainSubstring("is not pattern=duration")))
+	})
+})
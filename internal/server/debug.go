@@ -0,0 +1,267 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	runtimemetrics "runtime/metrics"
+	runtimepprof "runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// How a debug session was let in, in the debug.auth attribute
+const (
+	debugAuthToken         = "token"
+	debugAuthAdminListener = "admin_listener"
+)
+
+// registerDebugRoutes registers the profiling and runtime debugging endpoints.
+// With a debug token, every request must send it as a bearer token. Each session
+// is recorded in the audit log and as a debug.session span, also on the admin
+// listener, which is otherwise untraced.
+func registerDebugRoutes(mux *http.ServeMux, cfg config) {
+	tp := cfg.tracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	audit := cfg.auditLogger
+	if audit == nil {
+		audit = telemetry.ScopedLogger(telemetry.ScopeAudit)
+	}
+	d := &debugRoutes{token: cfg.debugToken, tracer: tp.Tracer(tracerName), audit: audit}
+	handle(mux, "GET /debug/pprof/{$}", d.session("index", pprof.Index))
+	handle(mux, "GET /debug/pprof/cmdline", d.session("cmdline", pprof.Cmdline))
+	handle(mux, "GET /debug/pprof/profile", d.session("cpu", pprof.Profile))
+	handle(mux, "GET /debug/pprof/symbol", d.session("symbol", pprof.Symbol))
+	handle(mux, "POST /debug/pprof/symbol", d.session("symbol", pprof.Symbol))
+	handle(mux, "GET /debug/pprof/trace", d.session("trace", pprof.Trace))
+	// heap, goroutine, block, mutex, allocs and threadcreate
+	handle(mux, "GET /debug/pprof/{profile}", d.session("", serveProfile))
+	handle(mux, "GET /debug/goroutines", d.session("goroutines", serveGoroutineDump))
+	handle(mux, "GET /debug/runtime/metrics", d.session("runtime_metrics", serveRuntimeMetrics))
+}
+
+type debugRoutes struct {
+	token  string
+	tracer trace.Tracer
+	audit  *slog.Logger
+}
+
+// session checks the bearer token, then serves h in a debug.session span,
+// between a started and a completed audit record. An empty endpoint is the
+// {profile} path value.
+func (d *debugRoutes) session(endpoint string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		name := endpoint
+		if name == "" {
+			name = r.PathValue("profile")
+		}
+		auth := debugAuthAdminListener
+		if d.token != "" {
+			auth = debugAuthToken
+		}
+		logger := d.audit.With(
+			slog.String(telemetry.DebugEndpointKey, name),
+			slog.String(telemetry.DebugAuthKey, auth),
+			slog.String(telemetry.URLPathKey, r.URL.Path),
+			slog.String(telemetry.URLQueryKey, r.URL.RawQuery),
+			slog.String(telemetry.ClientAddressKey, r.RemoteAddr),
+		)
+
+		if d.token != "" && !validBearerToken(r, d.token) {
+			logger.WarnContext(ctx, "Debug session denied", slog.Int(telemetry.HTTPStatusCodeKey, http.StatusUnauthorized))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="debug"`)
+			w.Header().Set("Content-Type", "application/json")
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		ctx, span := d.tracer.Start(ctx, "debug.session", trace.WithAttributes(
+			attribute.String(telemetry.DebugEndpointKey, name),
+			attribute.String(telemetry.DebugAuthKey, auth),
+			semconv.URLPath(r.URL.Path),
+			semconv.URLQuery(r.URL.RawQuery),
+			semconv.ClientAddress(r.RemoteAddr),
+		))
+		defer span.End()
+		logger.InfoContext(ctx, "Debug session started")
+
+		if r.URL.Query().Has("seconds") {
+			defer sampleProfile(name)()
+		}
+		start := time.Now()
+		rec := newResponseRecorder(w)
+		h(rec, r.WithContext(ctx))
+		duration := time.Since(start)
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= http.StatusBadRequest {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+		logger.InfoContext(ctx, "Debug session completed",
+			slog.Int(telemetry.HTTPStatusCodeKey, rec.status),
+			slog.Float64(telemetry.DurationMsKey, float64(duration.Nanoseconds())/1e6),
+		)
+	}
+}
+
+// requireBearerToken answers 401 to requests without the bearer token, and
+// serves the others with h
+func requireBearerToken(token string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validBearerToken(r, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			w.Header().Set("Content-Type", "application/json")
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// validBearerToken compares the bearer token of r with token in constant time
+func validBearerToken(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// sampling counts the running delta profiles (?seconds=N) of blocking and mutex
+// contention. Both are off by default, as they cost on every blocking event;
+// they are sampled while at least one delta profile runs.
+var sampling struct {
+	sync.Mutex
+	block, mutex  int
+	mutexFraction int
+}
+
+// sampleProfile turns sampling on for a block or mutex delta profile. The
+// returned func turns it back off when the last of them is done.
+func sampleProfile(profile string) func() {
+	sampling.Lock()
+	defer sampling.Unlock()
+	switch profile {
+	case "block":
+		if sampling.block == 0 {
+			runtime.SetBlockProfileRate(1)
+		}
+		sampling.block++
+		return func() {
+			sampling.Lock()
+			defer sampling.Unlock()
+			if sampling.block--; sampling.block == 0 {
+				runtime.SetBlockProfileRate(0)
+			}
+		}
+	case "mutex":
+		if sampling.mutex == 0 {
+			sampling.mutexFraction = runtime.SetMutexProfileFraction(1)
+		}
+		sampling.mutex++
+		return func() {
+			sampling.Lock()
+			defer sampling.Unlock()
+			if sampling.mutex--; sampling.mutex == 0 {
+				runtime.SetMutexProfileFraction(sampling.mutexFraction)
+			}
+		}
+	}
+	return func() {}
+}
+
+// serveProfile serves the named runtime/pprof profile ({profile})
+func serveProfile(w http.ResponseWriter, r *http.Request) {
+	pprof.Handler(r.PathValue("profile")).ServeHTTP(w, r)
+}
+
+// serveGoroutineDump writes the stack of every goroutine, as a panic would
+func serveGoroutineDump(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_ = runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// serveRuntimeMetrics reports a snapshot of every runtime/metrics metric.
+// Histograms are summarized as their count and approximate p50, p90 and p99.
+func serveRuntimeMetrics(w http.ResponseWriter, r *http.Request) {
+	descs := runtimemetrics.All()
+	samples := make([]runtimemetrics.Sample, len(descs))
+	for i, desc := range descs {
+		samples[i].Name = desc.Name
+	}
+	runtimemetrics.Read(samples)
+
+	snapshot := make(map[string]interface{}, len(samples))
+	for _, sample := range samples {
+		switch sample.Value.Kind() {
+		case runtimemetrics.KindUint64:
+			snapshot[sample.Name] = sample.Value.Uint64()
+		case runtimemetrics.KindFloat64:
+			if v := sample.Value.Float64(); !math.IsInf(v, 0) && !math.IsNaN(v) {
+				snapshot[sample.Name] = v
+			}
+		case runtimemetrics.KindFloat64Histogram:
+			snapshot[sample.Name] = summarizeHistogram(sample.Value.Float64Histogram())
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	body, err := json.Marshal(map[string]interface{}{"metrics": snapshot})
+	if err != nil {
+		telemetry.LogError(r.Context(), "Failed to encode runtime metrics", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprint(w, `{"error": "failed to encode runtime metrics"}`)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// summarizeHistogram returns the count and the quantiles of h, each the finite
+// boundary of the bucket it falls in
+func summarizeHistogram(h *runtimemetrics.Float64Histogram) map[string]interface{} {
+	var count uint64
+	for _, c := range h.Counts {
+		count += c
+	}
+	summary := map[string]interface{}{"count": count}
+	if count == 0 {
+		return summary
+	}
+	for _, q := range []struct {
+		name string
+		q    float64
+	}{{"p50", 0.5}, {"p90", 0.9}, {"p99", 0.99}} {
+		target := uint64(math.Ceil(q.q * float64(count)))
+		var cumulative uint64
+		for i, c := range h.Counts {
+			cumulative += c
+			if cumulative < target {
+				continue
+			}
+			// Bucket i spans [Buckets[i], Buckets[i+1])
+			boundary := h.Buckets[i+1]
+			if math.IsInf(boundary, 0) {
+				boundary = h.Buckets[i]
+			}
+			if !math.IsInf(boundary, 0) {
+				summary[q.name] = boundary
+			}
+			break
+		}
+	}
+	return summary
+}
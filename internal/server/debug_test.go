@@ -0,0 +1,155 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	runtimemetrics "runtime/metrics"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var _ = Describe("Debug endpoints", func() {
+	var (
+		exporter *tracetest.InMemoryExporter
+		provider *sdktrace.TracerProvider
+		audit    *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		exporter = tracetest.NewInMemoryExporter()
+		provider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		DeferCleanup(provider.Shutdown, context.Background())
+		audit = &bytes.Buffer{}
+	})
+
+	// auditRecords decodes the JSON audit records written so far
+	auditRecords := func() []map[string]interface{} {
+		var records []map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(audit.Bytes()))
+		for decoder.More() {
+			var record map[string]interface{}
+			Expect(decoder.Decode(&record)).To(Succeed())
+			records = append(records, record)
+		}
+		return records
+	}
+
+	Describe("on the public listener", func() {
+		serve := func(s *Server, path, token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", path, nil)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			w := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(w, req)
+			return w
+		}
+
+		It("should not be served without a token", func() {
+			s := New(WithAccessLog(AccessLogOff, nil))
+			Expect(serve(s, "/debug/pprof/", "").Code).To(Equal(http.StatusNotFound))
+			Expect(serve(s, "/debug/goroutines", "").Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should require the bearer token", func() {
+			s := New(WithDebugToken("s3cret"), WithAccessLog(AccessLogOff, nil),
+				WithAuditLog(slog.New(slog.NewJSONHandler(audit, nil))))
+
+			w := serve(s, "/debug/pprof/heap", "")
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+			Expect(w.Header().Get("WWW-Authenticate")).To(HavePrefix("Bearer"))
+			Expect(w.Body.String()).To(MatchJSON(`{"error": "unauthorized"}`))
+			Expect(serve(s, "/debug/pprof/heap", "wrong").Code).To(Equal(http.StatusUnauthorized))
+
+			records := auditRecords()
+			Expect(records).To(HaveLen(2))
+			Expect(records[0]).To(HaveKeyWithValue("msg", "Debug session denied"))
+			Expect(records[0]).To(HaveKeyWithValue("debug.endpoint", "heap"))
+			Expect(records[0]).To(HaveKeyWithValue("debug.auth", "token"))
+
+			w = serve(s, "/debug/pprof/heap", "s3cret")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.Len()).To(BeNumerically(">", 0))
+		})
+
+		It("should dump goroutines and runtime metrics", func() {
+			s := New(WithDebugToken("s3cret"), WithAccessLog(AccessLogOff, nil),
+				WithAuditLog(slog.New(slog.NewJSONHandler(io.Discard, nil))))
+
+			w := serve(s, "/debug/goroutines", "s3cret")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(ContainSubstring("goroutine "))
+
+			w = serve(s, "/debug/runtime/metrics", "s3cret")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			var body struct {
+				Metrics map[string]interface{} `json:"metrics"`
+			}
+			Expect(json.Unmarshal(w.Body.Bytes(), &body)).To(Succeed())
+			Expect(body.Metrics).To(HaveKey("/sched/goroutines:goroutines"))
+			Expect(body.Metrics["/sched/latencies:seconds"]).To(HaveKey("count"))
+		})
+	})
+
+	It("should serve the admin listener without a token, with a span and audit records per session", func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		s := New(WithAdminListener(l), WithAddr("127.0.0.1:0"), WithTracerProvider(provider),
+			WithAuditLog(slog.New(slog.NewJSONHandler(audit, nil))))
+		Expect(s.startAdmin()).To(Succeed())
+		DeferCleanup(s.Shutdown, context.Background())
+
+		resp, err := http.Get("http://" + l.Addr().String() + "/debug/pprof/cmdline")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name).To(Equal("debug.session"))
+		Expect(spans[0].Attributes).To(ContainElements(
+			attribute.String("debug.endpoint", "cmdline"),
+			attribute.String("debug.auth", "admin_listener"),
+			attribute.Int("http.response.status_code", http.StatusOK),
+		))
+
+		records := auditRecords()
+		Expect(records).To(HaveLen(2))
+		Expect(records[0]).To(HaveKeyWithValue("msg", "Debug session started"))
+		Expect(records[1]).To(HaveKeyWithValue("msg", "Debug session completed"))
+		Expect(records[1]).To(HaveKeyWithValue("url.path", "/debug/pprof/cmdline"))
+	})
+
+	It("should sample mutex contention only while a delta profile runs", func() {
+		Expect(runtime.SetMutexProfileFraction(-1)).To(BeZero())
+		stop := sampleProfile("mutex")
+		Expect(runtime.SetMutexProfileFraction(-1)).To(Equal(1))
+		nested := sampleProfile("mutex")
+		stop()
+		Expect(runtime.SetMutexProfileFraction(-1)).To(Equal(1))
+		nested()
+		Expect(runtime.SetMutexProfileFraction(-1)).To(BeZero())
+	})
+
+	It("should summarize histograms with finite quantiles", func() {
+		summary := summarizeHistogram(&runtimemetrics.Float64Histogram{
+			Counts:  []uint64{0, 50, 49, 1},
+			Buckets: []float64{-1, 0, 1, 2, math.Inf(1)},
+		})
+		Expect(summary).To(Equal(map[string]interface{}{
+			"count": uint64(100), "p50": 1.0, "p90": 2.0, "p99": 2.0,
+		}))
+	})
+})
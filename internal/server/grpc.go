@@ -0,0 +1,273 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	greeterv1 "github.com/deepak-muley/dm-nkp-gitops-custom-app/api/greeter/v1"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultGRPCAddr is the address NewGRPCServer listens on without WithPort,
+// WithAddr or WithListener
+const DefaultGRPCAddr = ":9090"
+
+// Services of the gRPC health service besides the registered ones. The empty
+// service is the server as a whole, which is healthy when it is ready.
+const (
+	GRPCHealthLiveness  = "liveness"
+	GRPCHealthReadiness = "readiness"
+)
+
+// grpcHealthWatchInterval is how often Watch re-runs the checks
+const grpcHealthWatchInterval = 5 * time.Second
+
+// GRPCServer serves the grpc.health.v1.Health service, server reflection and
+// the GreeterService, with rpc.* spans and metrics from otelgrpc
+type GRPCServer struct {
+	grpcServer *grpc.Server
+	health     *healthService
+	addr       string
+	// listener is served instead of addr when set (WithListener)
+	listener net.Listener
+	// tls serves gRPC over TLS when set (WithTLS)
+	tls *TLSConfig
+}
+
+// NewGRPCServer builds the gRPC server. It takes the Options of New that apply
+// to it: WithPort, WithAddr, WithListener, WithTLS, WithTracerProvider and
+// WithMeterProvider; the others are ignored. Without options it listens on
+// DefaultGRPCAddr.
+func NewGRPCServer(opts ...Option) *GRPCServer {
+	cfg := defaultConfig()
+	cfg.addr = DefaultGRPCAddr
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var otelOpts []otelgrpc.Option
+	if cfg.tracerProvider != nil {
+		otelOpts = append(otelOpts, otelgrpc.WithTracerProvider(cfg.tracerProvider))
+	}
+	if cfg.meterProvider != nil {
+		otelOpts = append(otelOpts, otelgrpc.WithMeterProvider(cfg.meterProvider))
+	}
+	grpcServer := grpc.NewServer(grpc.StatsHandler(otelgrpc.NewServerHandler(otelOpts...)))
+
+	health := newHealthService()
+	healthpb.RegisterHealthServer(grpcServer, health)
+	greeterv1.RegisterGreeterServiceServer(grpcServer, greeterService{})
+	reflection.Register(grpcServer)
+
+	return &GRPCServer{
+		grpcServer: grpcServer,
+		health:     health,
+		addr:       cfg.addr,
+		listener:   cfg.listener,
+		tls:        cfg.tls,
+	}
+}
+
+// Start serves until Shutdown. With WithTLS the certificate files are reloaded
+// as for the HTTP server.
+func (s *GRPCServer) Start() error {
+	l := s.listener
+	if l == nil {
+		var err error
+		if l, err = net.Listen("tcp", s.addr); err != nil {
+			return err
+		}
+	}
+	if s.tls == nil {
+		return s.grpcServer.Serve(l)
+	}
+
+	policy, err := s.tls.policy()
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	reloader, err := newCertReloader(*s.tls)
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	tlsConfig := reloader.tlsConfig(policy)
+	tlsConfig.NextProtos = []string{"h2"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.watch(ctx)
+	return s.grpcServer.Serve(tls.NewListener(l, tlsConfig))
+}
+
+// Shutdown reports every service as NOT_SERVING to health watchers and stops
+// the server gracefully, waiting for pending RPCs until ctx is done. It then
+// closes the remaining connections and returns ctx.Err().
+func (s *GRPCServer) Shutdown(ctx context.Context) error {
+	s.health.shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		return ctx.Err()
+	}
+}
+
+// healthService answers grpc.health.v1.Health with the checks of /health and
+// /ready: the liveness service runs the liveness checks, every other known
+// service the readiness checks
+type healthService struct {
+	healthpb.UnimplementedHealthServer
+
+	// done is closed by shutdown, after which every service is NOT_SERVING
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func newHealthService() *healthService {
+	return &healthService{done: make(chan struct{})}
+}
+
+func (h *healthService) shutdown() {
+	h.doneOnce.Do(func() { close(h.done) })
+}
+
+func (h *healthService) shuttingDown() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// services are the names the health service knows, in the order List returns them
+func (h *healthService) services() []string {
+	return []string{"", GRPCHealthLiveness, GRPCHealthReadiness, greeterv1.GreeterService_ServiceDesc.ServiceName}
+}
+
+// check runs the checks of service. ok is false for unknown services.
+func (h *healthService) check(ctx context.Context, service string) (healthpb.HealthCheckResponse_ServingStatus, bool) {
+	// The checks' endpoint is the health method called, as it is the path for /health and /ready
+	method, _ := grpc.Method(ctx)
+	var healthy bool
+	switch service {
+	case GRPCHealthLiveness:
+		healthy = checkLiveness(ctx, method)
+	case "", GRPCHealthReadiness, greeterv1.GreeterService_ServiceDesc.ServiceName:
+		healthy = checkReadiness(ctx, method)
+	default:
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+	}
+	if !healthy || h.shuttingDown() {
+		return healthpb.HealthCheckResponse_NOT_SERVING, true
+	}
+	return healthpb.HealthCheckResponse_SERVING, true
+}
+
+func (h *healthService) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	serving, ok := h.check(ctx, req.GetService())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.GetService())
+	}
+	if err := ctx.Err(); err != nil {
+		// DeadlineExceeded or Canceled, rather than a NOT_SERVING the checks didn't find
+		return nil, status.FromContextError(err).Err()
+	}
+	return &healthpb.HealthCheckResponse{Status: serving}, nil
+}
+
+func (h *healthService) List(ctx context.Context, _ *healthpb.HealthListRequest) (*healthpb.HealthListResponse, error) {
+	statuses := make(map[string]*healthpb.HealthCheckResponse)
+	for _, service := range h.services() {
+		serving, _ := h.check(ctx, service)
+		statuses[service] = &healthpb.HealthCheckResponse{Status: serving}
+	}
+	return &healthpb.HealthListResponse{Statuses: statuses}, nil
+}
+
+// Watch sends the status of the service, then every change the periodic checks
+// find. Unknown services are SERVICE_UNKNOWN, as the health protocol asks. On
+// shutdown it sends NOT_SERVING and ends the stream.
+func (h *healthService) Watch(req *healthpb.HealthCheckRequest, stream grpc.ServerStreamingServer[healthpb.HealthCheckResponse]) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(grpcHealthWatchInterval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		serving, _ := h.check(ctx, req.GetService())
+		if serving != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: serving}); err != nil {
+				return err
+			}
+			last = serving
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-h.done:
+			if last != healthpb.HealthCheckResponse_NOT_SERVING {
+				return stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING})
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// greeterService is the gRPC counterpart of handleRoot
+type greeterService struct {
+	greeterv1.UnimplementedGreeterServiceServer
+}
+
+func (greeterService) Greet(ctx context.Context, _ *greeterv1.GreetRequest) (*greeterv1.GreetResponse, error) {
+	start := time.Now()
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName)
+
+	// The child logger carries service and method to every record logged for this call
+	logger := telemetry.ScopedLogger(telemetry.ScopeServer).With(
+		slog.String(telemetry.RPCServiceKey, greeterv1.GreeterService_ServiceDesc.ServiceName),
+		slog.String(telemetry.RPCMethodKey, "Greet"),
+	)
+	ctx = telemetry.ContextWithLogger(ctx, logger)
+	logger.InfoContext(ctx, "Received request")
+
+	// Simulate some business logic with a trace span, as GET / does
+	ctx, businessSpan := tracer.Start(ctx, "business.logic")
+	businessSpan.SetAttributes(attribute.String("business.operation", "generate_response"))
+	telemetry.LogInfo(ctx, "Processing business logic for Greet")
+	if err := sleepContext(ctx, 10*time.Millisecond); err != nil {
+		businessSpan.RecordError(err)
+		businessSpan.End()
+		// DeadlineExceeded when the client's deadline passed, Canceled when it went away
+		return nil, status.FromContextError(err).Err()
+	}
+	businessSpan.End()
+
+	logger.InfoContext(ctx, "Request completed",
+		slog.Int(telemetry.RPCGRPCStatusCodeKey, int(codes.OK)),
+		slog.Float64(telemetry.DurationMsKey, float64(time.Since(start).Nanoseconds())/1e6),
+	)
+	return &greeterv1.GreetResponse{Message: greetingMessage, Version: appVersion}, nil
+}
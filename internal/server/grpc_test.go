@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+
+	greeterv1 "github.com/deepak-muley/dm-nkp-gitops-custom-app/api/greeter/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("GRPCServer", func() {
+	var (
+		s        *GRPCServer
+		conn     *grpc.ClientConn
+		exporter *tracetest.InMemoryExporter
+		reader   *sdkmetric.ManualReader
+	)
+
+	BeforeEach(func() {
+		exporter = tracetest.NewInMemoryExporter()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		DeferCleanup(tp.Shutdown, context.Background())
+		reader = sdkmetric.NewManualReader()
+		mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+		DeferCleanup(mp.Shutdown, context.Background())
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		s = NewGRPCServer(WithListener(l), WithTracerProvider(tp), WithMeterProvider(mp))
+		go func() { _ = s.Start() }()
+		DeferCleanup(s.Shutdown, context.Background())
+
+		conn, err = grpc.NewClient(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(conn.Close)
+	})
+
+	It("should default to port 9090", func() {
+		Expect(NewGRPCServer().addr).To(Equal(DefaultGRPCAddr))
+		Expect(NewGRPCServer(WithPort("50051")).addr).To(Equal(":50051"))
+	})
+
+	Describe("health", func() {
+		It("should report the liveness and readiness checks", func() {
+			client := healthpb.NewHealthClient(conn)
+			for _, service := range []string{"", GRPCHealthLiveness, GRPCHealthReadiness, "dmnkp.greeter.v1.GreeterService"} {
+				resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.GetStatus()).To(Equal(healthpb.HealthCheckResponse_SERVING), service)
+			}
+
+			names := make([]string, 0)
+			for _, span := range exporter.GetSpans() {
+				names = append(names, span.Name)
+			}
+			Expect(names).To(ContainElements("health.check", "readiness.check", "grpc.health.v1.Health/Check"))
+		})
+
+		It("should answer NOT_FOUND for unknown services", func() {
+			_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{Service: "nope"})
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+		})
+
+		It("should list the services", func() {
+			resp, err := healthpb.NewHealthClient(conn).List(context.Background(), &healthpb.HealthListRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetStatuses()).To(HaveLen(4))
+			Expect(resp.GetStatuses()).To(HaveKey(GRPCHealthLiveness))
+		})
+
+		It("should tell watchers the server is shutting down", func() {
+			stream, err := healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			resp, err := stream.Recv()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetStatus()).To(Equal(healthpb.HealthCheckResponse_SERVING))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			Expect(s.Shutdown(ctx)).To(Succeed())
+
+			resp, err = stream.Recv()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetStatus()).To(Equal(healthpb.HealthCheckResponse_NOT_SERVING))
+			_, err = stream.Recv()
+			Expect(err).To(MatchError(io.EOF))
+		})
+	})
+
+	It("should greet like GET /", func() {
+		resp, err := greeterv1.NewGreeterServiceClient(conn).Greet(context.Background(), &greeterv1.GreetRequest{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.GetMessage()).To(Equal("Hello from dm-nkp-gitops-custom-app"))
+		Expect(resp.GetVersion()).To(Equal("0.1.0"))
+	})
+
+	It("should stop greeting once the caller's deadline passes", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Millisecond)
+		defer cancel()
+		_, err := greeterv1.NewGreeterServiceClient(conn).Greet(ctx, &greeterv1.GreetRequest{})
+		Expect(status.Code(err)).To(Equal(codes.DeadlineExceeded))
+
+		Eventually(func() []attribute.KeyValue {
+			for _, span := range exporter.GetSpans() {
+				if span.Name == "dmnkp.greeter.v1.GreeterService/Greet" {
+					return span.Attributes
+				}
+			}
+			return nil
+		}).Should(ContainElement(Or(
+			// The server's copy of the deadline, or the client's cancellation, may come first
+			Equal(attribute.Int64("rpc.grpc.status_code", int64(codes.DeadlineExceeded))),
+			Equal(attribute.Int64("rpc.grpc.status_code", int64(codes.Canceled))),
+		)))
+	})
+
+	It("should emit rpc spans and metrics", func() {
+		_, err := greeterv1.NewGreeterServiceClient(conn).Greet(context.Background(), &greeterv1.GreetRequest{})
+		Expect(err).NotTo(HaveOccurred())
+
+		spans := exporter.GetSpans()
+		server := spans[len(spans)-1]
+		Expect(server.Name).To(Equal("dmnkp.greeter.v1.GreeterService/Greet"))
+		Expect(server.Attributes).To(ContainElements(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.service", "dmnkp.greeter.v1.GreeterService"),
+			attribute.String("rpc.method", "Greet"),
+			attribute.Int64("rpc.grpc.status_code", 0),
+		))
+		Expect(spans[0].Name).To(Equal("business.logic"))
+		Expect(spans[0].Parent.SpanID()).To(Equal(server.SpanContext.SpanID()))
+
+		var rm metricdata.ResourceMetrics
+		Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+		var names []string
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				names = append(names, m.Name)
+			}
+		}
+		Expect(names).To(ContainElement("rpc.server.duration"))
+	})
+
+	It("should serve reflection", func() {
+		stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stream.Send(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+		})).To(Succeed())
+		resp, err := stream.Recv()
+		Expect(err).NotTo(HaveOccurred())
+
+		var services []string
+		for _, service := range resp.GetListServicesResponse().GetService() {
+			services = append(services, service.GetName())
+		}
+		Expect(services).To(ContainElements("grpc.health.v1.Health", "dmnkp.greeter.v1.GreeterService"))
+	})
+})
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// checkLiveness runs the liveness checks behind GET /health and the gRPC health
+// service, in a health.check span. endpoint names the caller on the span. It is
+// false when ctx is done before the checks complete.
+func checkLiveness(ctx context.Context, endpoint string) bool {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName)
+	ctx, healthSpan := tracer.Start(ctx, "health.check", trace.WithAttributes(
+		attribute.String("check.type", "liveness"),
+		attribute.String("endpoint", endpoint),
+	))
+	defer healthSpan.End()
+
+	// Log health check with structured logging
+	logger := telemetry.ScopedLogger(telemetry.ScopeServer).With(slog.String(telemetry.CheckTypeKey, "liveness"))
+	logger.InfoContext(ctx, "Health check requested")
+
+	// Perform health checks (simulate)
+	ctx, checkSpan := tracer.Start(ctx, "health.checks.run")
+	checkSpan.SetAttributes(
+		attribute.String("check.component", "application"),
+		attribute.Bool("check.status", true),
+	)
+	logger.InfoContext(ctx, "Running health checks",
+		slog.String(telemetry.CheckComponentKey, "application"), slog.String(telemetry.CheckStatusKey, "healthy"))
+	// Simulate check time, giving up once the caller is gone
+	if err := sleepContext(ctx, 5*time.Millisecond); err != nil {
+		checkSpan.RecordError(err)
+		checkSpan.End()
+		logger.WarnContext(ctx, "Health check cancelled", slog.String(telemetry.ErrorKey, err.Error()))
+		return false
+	}
+	checkSpan.End()
+
+	logger.InfoContext(ctx, "Health check completed", slog.String(telemetry.CheckStatusKey, "healthy"))
+	return true
+}
+
+// checkReadiness runs the readiness checks behind GET /ready and the gRPC health
+// service, in a readiness.check span. endpoint names the caller on the span. It is
+// false when ctx is done before the checks complete.
+func checkReadiness(ctx context.Context, endpoint string) bool {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName)
+	ctx, readySpan := tracer.Start(ctx, "readiness.check", trace.WithAttributes(
+		attribute.String("check.type", "readiness"),
+		attribute.String("endpoint", endpoint),
+	))
+	defer readySpan.End()
+
+	// Log readiness check with structured logging
+	logger := telemetry.ScopedLogger(telemetry.ScopeServer).With(slog.String(telemetry.CheckTypeKey, "readiness"))
+	logger.InfoContext(ctx, "Readiness check requested")
+
+	// Perform readiness checks (simulate)
+	ctx, checkSpan := tracer.Start(ctx, "readiness.checks.run")
+	checkSpan.SetAttributes(
+		attribute.String("check.component", "metrics"),
+		attribute.Bool("check.status", true),
+	)
+	logger.InfoContext(ctx, "Running readiness checks",
+		slog.String(telemetry.CheckComponentKey, "metrics"), slog.String(telemetry.CheckStatusKey, "ready"))
+	// Simulate check time, giving up once the caller is gone
+	if err := sleepContext(ctx, 5*time.Millisecond); err != nil {
+		checkSpan.RecordError(err)
+		checkSpan.End()
+		logger.WarnContext(ctx, "Readiness check cancelled", slog.String(telemetry.ErrorKey, err.Error()))
+		return false
+	}
+	checkSpan.End()
+
+	logger.InfoContext(ctx, "Readiness check completed", slog.String(telemetry.CheckStatusKey, "ready"))
+	return true
+}
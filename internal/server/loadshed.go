@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/loadshed"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// shedRetryAfter is the Retry-After of shed requests, in seconds: the limit
+// adapts within about a request's latency, so clients may retry soon
+const shedRetryAfter = "1"
+
+// loadShedder applies the adaptive concurrency limit of WithLoadShedding
+type loadShedder struct {
+	limiter *loadshed.Limiter
+	// routes resolves the route of the metric and the admin routes sharing the
+	// listener, which are critical
+	routes     routeResolver
+	priorities map[string]loadshed.Priority
+}
+
+func newLoadShedder(cfg loadshed.Config, routes routeResolver, priorities map[string]loadshed.Priority) *loadShedder {
+	ls := &loadShedder{limiter: loadshed.New(cfg), routes: routes, priorities: priorities}
+	ls.report()
+	return ls
+}
+
+// middleware answers 503 with Retry-After when the request would exceed the
+// concurrency limit of its priority. Shed requests are counted in
+// http_server_requests_shed_total and added to the request span as a load_shed
+// event; the limit and the requests in flight are exported as gauges.
+func (ls *loadShedder) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pattern, admin := ls.routes.resolve(r)
+		priority := ls.priorities[pattern]
+		if admin {
+			priority = loadshed.PriorityCritical
+		}
+
+		release, ok := ls.limiter.Acquire(priority)
+		if !ok {
+			ctx := r.Context()
+			route := ls.routes.route(pattern)
+			trace.SpanFromContext(ctx).AddEvent("load_shed", trace.WithAttributes(
+				attribute.String(telemetry.LoadShedPriorityKey, priority.String()),
+			))
+			telemetry.LogDebug(ctx, "Request shed", map[string]string{
+				telemetry.HTTPRouteKey:        route,
+				telemetry.LoadShedPriorityKey: priority.String(),
+			})
+			metrics.IncrementShedCounter(route, priority.String())
+
+			w.Header().Set("Retry-After", shedRetryAfter)
+			w.Header().Set("Content-Type", "application/json")
+			writeJSONError(w, http.StatusServiceUnavailable, "server overloaded")
+			return
+		}
+		if priority == loadshed.PriorityCritical {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ls.report()
+		defer func() {
+			release()
+			ls.report()
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// report exports the limit and the requests in flight
+func (ls *loadShedder) report() {
+	stats := ls.limiter.Stats()
+	metrics.UpdateConcurrency(float64(stats.Limit), float64(stats.InFlight))
+}
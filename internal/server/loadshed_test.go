@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/loadshed"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var _ = Describe("Load shedding", func() {
+	var (
+		exporter *tracetest.InMemoryExporter
+		s        *Server
+		started  chan struct{}
+		unblock  chan struct{}
+	)
+
+	BeforeEach(func() {
+		exporter = tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		DeferCleanup(provider.Shutdown, context.Background())
+
+		started, unblock = make(chan struct{}), make(chan struct{})
+		blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-unblock
+		})
+		s = New(WithAccessLog(AccessLogOff, nil), WithTracerProvider(provider),
+			WithHandler("GET /slow", blocking),
+			WithHandler("GET /reports", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})),
+			WithRoutePriority("GET /reports", loadshed.PriorityLow),
+			WithLoadShedding(loadshed.Config{InitialLimit: 2, MinLimit: 1, MaxLimit: 2, LatencyTolerance: 2}))
+	})
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	// hold keeps a /slow request in flight until unblock is closed
+	hold := func() {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			serve("/slow")
+		}()
+		<-started
+		DeferCleanup(func() { <-done })
+	}
+
+	It("should shed requests beyond the limit of their priority with 503", func() {
+		defer close(unblock)
+		hold()
+		// Low priority requests may use 80% of the limit of 2, so one request
+		Expect(serve("/reports").Code).To(Equal(http.StatusServiceUnavailable))
+		hold()
+
+		exporter.Reset()
+		w := serve("/")
+		Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(w.Header().Get("Retry-After")).To(Equal("1"))
+		Expect(w.Body.String()).To(MatchJSON(`{"error": "server overloaded"}`))
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Events).To(HaveLen(1))
+		Expect(spans[0].Events[0].Name).To(Equal("load_shed"))
+		Expect(spans[0].Events[0].Attributes).To(ContainElement(attribute.String("loadshed.priority", "normal")))
+
+		// Probes and admin routes are critical
+		Expect(serve("/health").Code).To(Equal(http.StatusOK))
+		Expect(serve("/admin/loglevel").Code).To(Equal(http.StatusOK))
+	})
+
+	It("should admit requests again once others complete", func() {
+		hold()
+		hold()
+		Expect(serve("/").Code).To(Equal(http.StatusServiceUnavailable))
+		close(unblock)
+		Eventually(func() int { return serve("/").Code }).Should(Equal(http.StatusOK))
+	})
+})
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/loadshed"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/ratelimit"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option configures a Server created by New
+type Option func(*config)
+
+// Middleware wraps the handler of every route
+type Middleware func(http.Handler) http.Handler
+
+// route is a handler registered with WithHandler
+type route struct {
+	pattern string
+	handler http.Handler
+}
+
+// config holds the settings Options change. defaultConfig is the server New
+// builds without options.
+type config struct {
+	addr           string
+	listener       net.Listener
+	routes         []route
+	middleware     []Middleware
+	readTimeout    time.Duration
+	writeTimeout   time.Duration
+	idleTimeout    time.Duration
+	maxHeaderBytes int
+	tls            *TLSConfig
+	adminAddr      string
+	adminListener  net.Listener
+	debugToken     string
+	rateLimit      *ratelimit.Config
+	loadShedding   *loadshed.Config
+	priorities     map[string]loadshed.Priority
+	routeTimeout   time.Duration
+	routeTimeouts  map[string]time.Duration
+
+	tracerProvider  trace.TracerProvider
+	meterProvider   metric.MeterProvider
+	accessLogger    *slog.Logger
+	accessLogFormat string
+	auditLogger     *slog.Logger
+}
+
+func defaultConfig() config {
+	return config{
+		addr:           ":8080",
+		readTimeout:    15 * time.Second,
+		writeTimeout:   15 * time.Second,
+		idleTimeout:    60 * time.Second,
+		maxHeaderBytes: http.DefaultMaxHeaderBytes,
+		routeTimeout:   DefaultRouteTimeout,
+	}
+}
+
+// WithPort listens on port on every interface (default 8080)
+func WithPort(port string) Option {
+	return func(c *config) {
+		c.addr = net.JoinHostPort("", port)
+	}
+}
+
+// WithAddr listens on addr, in the host:port form of http.Server.Addr
+func WithAddr(addr string) Option {
+	return func(c *config) {
+		c.addr = addr
+	}
+}
+
+// WithListener serves on l instead of listening on the address. Start serves
+// until Shutdown, which closes l.
+func WithListener(l net.Listener) Option {
+	return func(c *config) {
+		c.listener = l
+	}
+}
+
+// WithAdminAddr serves the probes (/health, /ready), the admin controls
+// (/admin/...), /slo and the debug routes on a second listener at addr, and
+// removes them from the public one. Bind it to localhost or the pod IP so it is
+// never exposed. The admin listener is not traced, access-logged or counted in
+// the SLOs.
+func WithAdminAddr(addr string) Option {
+	return func(c *config) {
+		c.adminAddr = addr
+	}
+}
+
+// WithAdminListener serves the admin routes on l (see WithAdminAddr)
+func WithAdminListener(l net.Listener) Option {
+	return func(c *config) {
+		c.adminListener = l
+	}
+}
+
+// WithDebugToken requires token as a bearer token on the profiling and runtime
+// debugging endpoints (/debug/pprof/..., /debug/goroutines, /debug/runtime/metrics).
+// Those endpoints are always served on the admin listener; without one, they are
+// served on the public listener only when a token is set.
+func WithDebugToken(token string) Option {
+	return func(c *config) {
+		c.debugToken = token
+	}
+}
+
+// WithRateLimit limits the requests of the public routes per cfg (see
+// ratelimit.Config), before the WithMiddleware chain runs. Admin routes sharing
+// the public listener are never limited. The limits can be changed at runtime
+// with PUT /admin/ratelimits, also when cfg starts without any: on the admin
+// listener, or on the public one with the WithDebugToken bearer token. Without
+// either, the limits are only reported there.
+func WithRateLimit(cfg ratelimit.Config) Option {
+	return func(c *config) {
+		c.rateLimit = &cfg
+	}
+}
+
+// WithLoadShedding limits the public requests in flight to an adaptive limit
+// (see loadshed.Config) and answers the excess with 503 at once. It runs after
+// rate limiting and before the WithMiddleware chain. Admin routes sharing the
+// public listener are never shed.
+func WithLoadShedding(cfg loadshed.Config) Option {
+	return func(c *config) {
+		c.loadShedding = &cfg
+	}
+}
+
+// WithRoutePriority sets the load shedding priority of the route registered
+// with pattern (default loadshed.PriorityNormal). Low priority routes are shed
+// first; critical ones never.
+func WithRoutePriority(pattern string, p loadshed.Priority) Option {
+	return func(c *config) {
+		if c.priorities == nil {
+			c.priorities = make(map[string]loadshed.Priority)
+		}
+		c.priorities[pattern] = p
+	}
+}
+
+// WithHandler registers h for a method and path pattern of http.ServeMux
+// ("GET /items/{id}"), next to the built-in routes. New panics on patterns that
+// conflict with another route, as http.ServeMux.Handle does.
+func WithHandler(pattern string, h http.Handler) Option {
+	return func(c *config) {
+		c.routes = append(c.routes, route{pattern: pattern, handler: h})
+	}
+}
+
+// WithMiddleware appends middleware to the chain around the routes. The first
+// middleware is the outermost. The chain runs inside the tracing, access log,
+// SLO and panic recovery middleware, so its responses and panics are accounted
+// like those of handlers; requests no route matches go through it too.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(c *config) {
+		c.middleware = append(c.middleware, mw...)
+	}
+}
+
+// WithTimeouts sets the read, write and idle timeouts of the http.Server
+// (default 15s, 15s and 60s). Zero means no timeout.
+func WithTimeouts(read, write, idle time.Duration) Option {
+	return func(c *config) {
+		c.readTimeout = read
+		c.writeTimeout = write
+		c.idleTimeout = idle
+	}
+}
+
+// WithDefaultRouteTimeout sets the deadline of requests to routes without a
+// timeout of their own (default DefaultRouteTimeout). Zero means no deadline.
+// Keep it below the write timeout, or the 504 can't be written in time.
+func WithDefaultRouteTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.routeTimeout = d
+	}
+}
+
+// WithRouteTimeout sets the deadline of requests to the route registered with
+// pattern. When it passes, the request context is done and the client gets a
+// 504 JSON error. Zero means no deadline. Admin routes get none.
+func WithRouteTimeout(pattern string, d time.Duration) Option {
+	return func(c *config) {
+		if c.routeTimeouts == nil {
+			c.routeTimeouts = make(map[string]time.Duration)
+		}
+		c.routeTimeouts[pattern] = d
+	}
+}
+
+// WithMaxHeaderBytes bounds the size of request headers (default
+// http.DefaultMaxHeaderBytes, 1 MB)
+func WithMaxHeaderBytes(n int) Option {
+	return func(c *config) {
+		c.maxHeaderBytes = n
+	}
+}
+
+// WithTLS serves HTTPS with cfg (see TLSConfig). Start fails if the files can't
+// be loaded or the policy is invalid.
+func WithTLS(cfg TLSConfig) Option {
+	return func(c *config) {
+		c.tls = &cfg
+	}
+}
+
+// WithTracerProvider creates the server spans, and through them the handlers'
+// child spans, with tp instead of the global tracer provider
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider records the otelhttp request metrics with mp instead of the
+// global meter provider. For server.New, the instruments of the metrics package
+// and the SLO gauges move to mp as well; they are package-level, so this applies
+// to every server of the process.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// WithAuditLog records the debug sessions to logger instead of the audit scope logger
+func WithAuditLog(logger *slog.Logger) Option {
+	return func(c *config) {
+		c.auditLogger = logger
+	}
+}
+
+// WithAccessLog logs access records to logger in format (see ACCESS_LOG_FORMAT).
+// An empty format keeps ACCESS_LOG_FORMAT and a nil logger the access scope logger.
+func WithAccessLog(format string, logger *slog.Logger) Option {
+	return func(c *config) {
+		c.accessLogFormat = format
+		c.accessLogger = logger
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/ratelimit"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxRateLimitsBody bounds the PUT /admin/ratelimits body
+const maxRateLimitsBody = 4096
+
+// rateLimiter applies the limits of WithRateLimit to the public routes
+type rateLimiter struct {
+	limiter        *ratelimit.Limiter
+	trustedProxies []netip.Prefix
+	keyHeader      string
+	// routes resolves the route of the metric and the admin routes sharing the
+	// listener, which are never limited so probes keep working
+	routes routeResolver
+}
+
+func newRateLimiter(cfg ratelimit.Config, routes routeResolver) *rateLimiter {
+	keyHeader := cfg.KeyHeader
+	if keyHeader == "" {
+		keyHeader = ratelimit.DefaultKeyHeader
+	}
+	return &rateLimiter{
+		limiter:        ratelimit.New(cfg.Limits),
+		trustedProxies: cfg.TrustedProxies,
+		keyHeader:      keyHeader,
+		routes:         routes,
+	}
+}
+
+// middleware answers 429 with Retry-After once a bucket of the request has run
+// out. Limited responses carry the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers of the bucket closest to running out. Rejections are
+// counted in http_requests_rate_limited_total and added to the request span as a
+// rate_limited event.
+func (rl *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pattern, admin := rl.routes.resolve(r)
+		if admin {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ip := ratelimit.ClientIP(r, rl.trustedProxies)
+		decision := rl.limiter.Allow(ip, r.Header.Get(rl.keyHeader))
+		if decision.Scope == "" {
+			// No limit applies
+			next.ServeHTTP(w, r)
+			return
+		}
+		header := w.Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+		if decision.Allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		route := rl.routes.route(pattern)
+		trace.SpanFromContext(ctx).AddEvent("rate_limited", trace.WithAttributes(
+			attribute.String(telemetry.RateLimitScopeKey, decision.Scope),
+			semconv.ClientAddress(ip),
+		))
+		telemetry.LogDebug(ctx, "Request rate limited", map[string]string{
+			telemetry.HTTPRouteKey:      route,
+			telemetry.ClientAddressKey:  ip,
+			telemetry.RateLimitScopeKey: decision.Scope,
+		})
+		metrics.IncrementRateLimitedCounter(route, decision.Scope)
+
+		header.Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+		header.Set("Content-Type", "application/json")
+		writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded")
+	})
+}
+
+// ceilSeconds rounds d up to whole seconds, as the Retry-After and RateLimit-*
+// headers count them
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// registerRateLimitRoutes registers the runtime control of the rate limits.
+// Admin routes aren't rate limited themselves, so on the public listener
+// changing the limits takes the debug bearer token, and is left out without one.
+func registerRateLimitRoutes(mux *http.ServeMux, rl *rateLimiter, public bool, token string) {
+	handle(mux, "GET /admin/ratelimits", rl.handleLimits)
+	switch {
+	case !public:
+		handle(mux, "PUT /admin/ratelimits", rl.handleLimits)
+	case token != "":
+		handle(mux, "PUT /admin/ratelimits", requireBearerToken(token, rl.handleLimits))
+	}
+}
+
+// handleLimits reports (GET) or changes (PUT) the rate limits. A PUT body holds
+// the limits to change ({"per_ip": {"rate": 5, "burst": 10}}); the others are
+// kept. A zero rate turns a limit off.
+func (rl *rateLimiter) handleLimits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limits := rl.limiter.Limits()
+	if r.Method == http.MethodPut {
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRateLimitsBody))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&limits); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+		if err := rl.limiter.SetLimits(limits); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		telemetry.LogInfo(r.Context(), "Rate limits changed via admin endpoint")
+	}
+
+	body, err := json.Marshal(limits)
+	if err != nil {
+		telemetry.LogError(r.Context(), "Failed to encode rate limits", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to encode rate limits")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/ratelimit"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var _ = Describe("Rate limiting", func() {
+	serve := func(s *Server, method, target, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.RemoteAddr = "192.0.2.10:51234"
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	It("should answer 429 with Retry-After once a client's bucket runs out", func() {
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		DeferCleanup(provider.Shutdown, context.Background())
+		s := New(WithAccessLog(AccessLogOff, nil), WithTracerProvider(provider),
+			WithRateLimit(ratelimit.Config{Limits: ratelimit.Limits{PerIP: ratelimit.Limit{Rate: 0.5, Burst: 2}}}))
+
+		w := serve(s, "GET", "/", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("RateLimit-Limit")).To(Equal("2"))
+		Expect(w.Header().Get("RateLimit-Remaining")).To(Equal("1"))
+		Expect(w.Header().Get("RateLimit-Reset")).To(Equal("2"))
+		Expect(serve(s, "GET", "/", "").Code).To(Equal(http.StatusOK))
+
+		exporter.Reset()
+		w = serve(s, "GET", "/", "")
+		Expect(w.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(w.Body.String()).To(MatchJSON(`{"error": "rate limit exceeded"}`))
+		Expect(w.Header().Get("Retry-After")).To(Equal("2"))
+		Expect(w.Header().Get("RateLimit-Remaining")).To(Equal("0"))
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Events).To(HaveLen(1))
+		Expect(spans[0].Events[0].Name).To(Equal("rate_limited"))
+		Expect(spans[0].Events[0].Attributes).To(ContainElements(
+			attribute.String("ratelimit.scope", "ip"),
+			attribute.String("client.address", "192.0.2.10"),
+		))
+	})
+
+	It("should limit per API key and leave the probes alone", func() {
+		s := New(WithAccessLog(AccessLogOff, nil), WithRateLimit(ratelimit.Config{
+			Limits:         ratelimit.Limits{PerKey: ratelimit.Limit{Rate: 1, Burst: 1}},
+			TrustedProxies: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+		}))
+
+		Expect(serve(s, "GET", "/", "", "X-API-Key", "tenant-a").Code).To(Equal(http.StatusOK))
+		Expect(serve(s, "GET", "/", "", "X-API-Key", "tenant-a").Code).To(Equal(http.StatusTooManyRequests))
+		Expect(serve(s, "GET", "/", "", "X-API-Key", "tenant-b").Code).To(Equal(http.StatusOK))
+		// No key, no limit
+		w := serve(s, "GET", "/", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("RateLimit-Limit")).To(BeEmpty())
+
+		for range 3 {
+			w = serve(s, "GET", "/health", "", "X-API-Key", "tenant-a")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("RateLimit-Limit")).To(BeEmpty())
+		}
+	})
+
+	It("should report and change the limits at runtime", func() {
+		s := New(WithAccessLog(AccessLogOff, nil), WithRateLimit(ratelimit.Config{}), WithDebugToken("s3cret"))
+		auth := []string{"Authorization", "Bearer s3cret"}
+
+		w := serve(s, "GET", "/admin/ratelimits", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(MatchJSON(`{
+			"global": {"rate": 0, "burst": 0},
+			"per_ip": {"rate": 0, "burst": 0},
+			"per_key": {"rate": 0, "burst": 0}
+		}`))
+
+		w = serve(s, "PUT", "/admin/ratelimits", `{"per_ip": {"rate": 1}}`, auth...)
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		Expect(w.Body.String()).To(ContainSubstring("burst must be at least 1"))
+		Expect(serve(s, "PUT", "/admin/ratelimits", `{"per_client": {}}`, auth...).Code).To(Equal(http.StatusBadRequest))
+
+		w = serve(s, "PUT", "/admin/ratelimits", `{"per_ip": {"rate": 1, "burst": 1}}`, auth...)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring(`"per_ip":{"rate":1,"burst":1}`))
+
+		Expect(serve(s, "GET", "/", "").Code).To(Equal(http.StatusOK))
+		Expect(serve(s, "GET", "/", "").Code).To(Equal(http.StatusTooManyRequests))
+		// The admin routes are exempt, so the limits can always be lifted again
+		Expect(serve(s, "PUT", "/admin/ratelimits", `{"per_ip": {"rate": 0}}`, auth...).Code).To(Equal(http.StatusOK))
+		Expect(serve(s, "GET", "/", "").Code).To(Equal(http.StatusOK))
+	})
+
+	It("should only let the debug token change the limits on the public listener", func() {
+		s := New(WithAccessLog(AccessLogOff, nil), WithRateLimit(ratelimit.Config{}))
+		Expect(serve(s, "GET", "/admin/ratelimits", "").Code).To(Equal(http.StatusOK))
+		w := serve(s, "PUT", "/admin/ratelimits", `{"global": {"rate": 0}}`)
+		Expect(w.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(w.Header().Get("Allow")).To(Equal("GET, HEAD"))
+
+		s = New(WithAccessLog(AccessLogOff, nil), WithRateLimit(ratelimit.Config{}), WithDebugToken("s3cret"))
+		w = serve(s, "PUT", "/admin/ratelimits", `{"global": {"rate": 0}}`, "Authorization", "Bearer guess")
+		Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		Expect(w.Header().Get("WWW-Authenticate")).To(HavePrefix("Bearer"))
+		Expect(w.Body.String()).To(MatchJSON(`{"error": "unauthorized"}`))
+	})
+
+	It("should serve the limits on the admin listener when there is one", func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		s := New(WithAdminListener(l), WithAccessLog(AccessLogOff, nil), WithRateLimit(ratelimit.Config{}))
+		Expect(s.startAdmin()).To(Succeed())
+		DeferCleanup(s.Shutdown, context.Background())
+
+		Expect(serve(s, "GET", "/admin/ratelimits", "").Code).To(Equal(http.StatusNotFound))
+		resp, err := http.Get("http://" + l.Addr().String() + "/admin/ratelimits")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		// No token needed on the admin listener
+		req, err := http.NewRequest("PUT", "http://"+l.Addr().String()+"/admin/ratelimits", strings.NewReader(`{"global": {"rate": 0}}`))
+		Expect(err).NotTo(HaveOccurred())
+		resp, err = http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+})
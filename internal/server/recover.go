@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
+)
+
+// unmatchedRoute labels panics of requests no mux pattern matched
+const unmatchedRoute = "unmatched"
+
+// recoverMiddleware turns handler panics into a 500 JSON response. The panic is
+// logged with its stack, recorded as an exception on the request span and counted
+// in http_server_panics_total. http.ErrAbortHandler is re-panicked so net/http
+// aborts the response as usual. routes resolves the route of the panic counter.
+func recoverMiddleware(next http.Handler, routes routeResolver) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := newResponseRecorder(w)
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			pattern, _ := routes.resolve(r)
+			route := routes.route(pattern)
+			// LogError captures the stack from here, which still holds the panicking frames
+			telemetry.LogError(r.Context(), "Handler panicked", panicError(v), map[string]string{
+				telemetry.HTTPMethodKey: r.Method,
+				telemetry.HTTPRouteKey:  route,
+			})
+			metrics.IncrementPanicCounter(route)
+
+			if rec.wroteHeader {
+				// Too late for a 500; the client gets whatever was written
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			writeJSONError(w, http.StatusInternalServerError, "internal server error")
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// panicError wraps a recovered value, keeping it in the chain when it is an error
+func panicError(v any) error {
+	if err, ok := v.(error); ok {
+		return fmt.Errorf("panic: %w", err)
+	}
+	return errors.New(fmt.Sprint("panic: ", v))
+}
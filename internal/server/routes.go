@@ -0,0 +1,154 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// newMux registers the public and the admin routes, for servers without an
+// admin listener. All routes use method+path patterns. Requests no pattern
+// matches get the mux's 404, or its 405 with an Allow header when only the method
+// is wrong; errorsAsJSON turns both into JSON.
+func newMux(extra ...route) *http.ServeMux {
+	mux := newPublicMux(extra...)
+	registerAdminRoutes(mux)
+	return mux
+}
+
+// newPublicMux registers the business routes: the built-in root route and extra
+func newPublicMux(extra ...route) *http.ServeMux {
+	mux := http.NewServeMux()
+	handle(mux, "GET /{$}", handleRoot)
+	for _, r := range extra {
+		handle(mux, r.pattern, r.handler.ServeHTTP)
+	}
+	return mux
+}
+
+// newAdminMux registers the admin routes alone, for the admin listener
+func newAdminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	registerAdminRoutes(mux)
+	return mux
+}
+
+// registerAdminRoutes registers the probes, the admin controls and the debug routes
+func registerAdminRoutes(mux *http.ServeMux) {
+	handle(mux, "GET /health", handleHealth)
+	handle(mux, "GET /ready", handleReady)
+	handle(mux, "POST /admin/metrics/flush", handleMetricsFlush)
+	handle(mux, "GET /admin/loglevel", handleLogLevel)
+	handle(mux, "PUT /admin/loglevel", handleLogLevel)
+	handle(mux, "DELETE /admin/loglevel", handleLogLevel)
+	handle(mux, "GET /slo", handleSLO)
+	handle(mux, "GET /debug/metrics/catalog", handleMetricsCatalog)
+}
+
+// handle registers h for pattern. The route is set as http.route on the request
+// span and on the otelhttp metrics.
+func handle(mux *http.ServeMux, pattern string, h http.HandlerFunc) {
+	mux.Handle(pattern, otelhttp.WithRouteTag(httpRoute(pattern), h))
+}
+
+// httpRoute is the path template of a mux pattern, without method and host:
+// "GET /items/{id}" becomes "/items/{id}" and "GET /{$}" becomes "/".
+// It is "" for requests no pattern matched.
+func httpRoute(pattern string) string {
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		pattern = strings.TrimLeft(path, " \t")
+	}
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:]
+	}
+	return strings.TrimSuffix(pattern, "{$}")
+}
+
+// spanName names server spans "METHOD route", or just "METHOD" for requests no
+// pattern matches, so unknown paths don't create a span name each. The span
+// starts before routing, so the route is looked up in the mux.
+func spanName(mux *http.ServeMux) func(string, *http.Request) string {
+	return func(_ string, r *http.Request) string {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			return fmt.Sprintf("%s %s", r.Method, httpRoute(pattern))
+		}
+		return r.Method
+	}
+}
+
+// routeResolver finds the route of a request before the mux serves it, for
+// the middleware that runs outside the mux
+type routeResolver struct {
+	mux *http.ServeMux
+	// admin holds the admin routes when they share the listener (newMux)
+	admin *http.ServeMux
+}
+
+// resolve returns the mux pattern of r ("" when none matches) and whether it
+// is an admin route
+func (rr routeResolver) resolve(r *http.Request) (pattern string, admin bool) {
+	_, pattern = rr.mux.Handler(r)
+	if rr.admin != nil && pattern != "" {
+		_, adminPattern := rr.admin.Handler(r)
+		admin = adminPattern == pattern
+	}
+	return pattern, admin
+}
+
+// route is the path template of pattern for metrics, unmatchedRoute when no
+// pattern matched
+func (rr routeResolver) route(pattern string) string {
+	if route := httpRoute(pattern); route != "" {
+		return route
+	}
+	return unmatchedRoute
+}
+
+// errorsAsJSON serves the mux's 404 and 405 responses with a JSON body. Responses
+// of matched routes are left alone.
+func errorsAsJSON(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(&jsonErrorWriter{ResponseWriter: w}, r)
+	})
+}
+
+// jsonErrorWriter replaces the plain text body of 404 and 405 responses. Other
+// statuses (the mux's redirects) pass through.
+type jsonErrorWriter struct {
+	http.ResponseWriter
+	replaced bool
+}
+
+func (w *jsonErrorWriter) WriteHeader(status int) {
+	var message string
+	switch status {
+	case http.StatusNotFound:
+		message = "not found"
+	case http.StatusMethodNotAllowed:
+		message = "method not allowed"
+	default:
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.replaced = true
+	w.Header().Set("Content-Type", "application/json")
+	writeJSONError(w.ResponseWriter, status, message)
+}
+
+func (w *jsonErrorWriter) Write(b []byte) (int, error) {
+	if w.replaced {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *jsonErrorWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
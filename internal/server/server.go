@@ -2,18 +2,32 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/slo"
 	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
-	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// tracerName is the instrumentation scope of the handlers' spans. They are
+// created with the provider of the request span, so WithTracerProvider covers them.
+const tracerName = "dm-nkp-gitops-custom-app/server"
+
+// greetingMessage and appVersion are the greeting of GET / and of the gRPC
+// GreeterService
+const (
+	greetingMessage = "Hello from dm-nkp-gitops-custom-app"
+	appVersion      = "0.1.0"
+)
+
 // shutdowner is an interface for shutting down servers (for testing)
 type shutdowner interface {
 	Shutdown(ctx context.Context) error
@@ -21,49 +35,193 @@ type shutdowner interface {
 
 type Server struct {
 	httpServer *http.Server
+	// listener is served instead of httpServer.Addr when set (WithListener)
+	listener net.Listener
+	// tls serves HTTPS when set (WithTLS)
+	tls *TLSConfig
+	// adminServer serves the admin routes when set (WithAdminAddr, WithAdminListener)
+	adminServer   *http.Server
+	adminListener net.Listener
 	// test hooks for mocking (only set in tests)
 	httpShutdowner shutdowner
 }
 
-func New(port string) *Server {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", handleRoot)
-	mux.HandleFunc("/health", handleHealth)
-	mux.HandleFunc("/ready", handleReady)
-
-	// Wrap handler with OpenTelemetry HTTP instrumentation
-	otelHandler := otelhttp.NewHandler(
-		mux,
-		"http-server",
-		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
-			return fmt.Sprintf("%s %s", r.Method, r.URL.Path)
-		}),
-	)
+// New builds the server: the built-in routes plus those of WithHandler, wrapped
+// by WithMiddleware, route deadlines, load shedding, rate limiting, panic
+// recovery, SLO tracking, the access log and otelhttp.
+// Without options it listens on :8080 with 15s read/write and 60s idle timeouts.
+// With an admin listener the admin routes are served there instead.
+func New(opts ...Option) *Server {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.meterProvider != nil {
+		// The app's instruments and the SLO gauges are package-level: they move
+		// to the injected provider along with the otelhttp metrics
+		if err := metrics.UseMeterProvider(cfg.meterProvider); err != nil {
+			telemetry.LogError(context.Background(), "Failed to register metrics on the meter provider", err)
+		}
+		if err := slo.UseMeterProvider(cfg.meterProvider); err != nil {
+			telemetry.LogError(context.Background(), "Failed to register SLO gauges on the meter provider", err)
+		}
+	}
+
+	var adminServer *http.Server
+	mux := newMux(cfg.routes...)
+	// adminMux serves the admin routes: on the admin listener, or in mux, where
+	// routes tells them apart so they are never rate limited, shed or given a
+	// deadline
+	adminMux := mux
+	routes := routeResolver{mux: mux}
+	if cfg.adminAddr != "" || cfg.adminListener != nil {
+		mux = newPublicMux(cfg.routes...)
+		adminMux = newAdminMux()
+		routes = routeResolver{mux: mux}
+		registerDebugRoutes(adminMux, cfg)
+		// No deadlines on the admin routes, but cancelled requests get their 499 too
+		adminDeadlines := &routeDeadlines{routes: routeResolver{mux: adminMux}}
+		adminServer = &http.Server{
+			Addr:        cfg.adminAddr,
+			Handler:     recoverMiddleware(adminDeadlines.middleware(errorsAsJSON(adminMux)), adminDeadlines.routes),
+			ReadTimeout: cfg.readTimeout,
+			// No write timeout: CPU profiles and execution traces stream for as
+			// long as the caller asks (?seconds=N)
+			IdleTimeout:    cfg.idleTimeout,
+			MaxHeaderBytes: cfg.maxHeaderBytes,
+		}
+	} else {
+		routes.admin = newAdminMux()
+		if cfg.debugToken != "" {
+			registerDebugRoutes(mux, cfg)
+			registerDebugRoutes(routes.admin, cfg)
+		}
+	}
+
+	var handler http.Handler = errorsAsJSON(mux)
+	for i := len(cfg.middleware) - 1; i >= 0; i-- {
+		handler = cfg.middleware[i](handler)
+	}
+	deadlines := &routeDeadlines{routes: routes, fallback: cfg.routeTimeout, timeouts: cfg.routeTimeouts}
+	handler = deadlines.middleware(handler)
+	if cfg.loadShedding != nil && cfg.loadShedding.Enabled() {
+		handler = newLoadShedder(*cfg.loadShedding, routes, cfg.priorities).middleware(handler)
+	}
+	if cfg.rateLimit != nil {
+		limiter := newRateLimiter(*cfg.rateLimit, routes)
+		public := adminServer == nil
+		registerRateLimitRoutes(adminMux, limiter, public, cfg.debugToken)
+		if routes.admin != nil {
+			registerRateLimitRoutes(routes.admin, limiter, public, cfg.debugToken)
+		}
+		handler = limiter.middleware(handler)
+	}
+
+	// One access log record per request, in the access scope (see ACCESS_LOG_FORMAT)
+	accessLogger := cfg.accessLogger
+	if accessLogger == nil {
+		accessLogger = telemetry.ScopedLogger(telemetry.ScopeAccess)
+	}
+	handler = accessLogMiddleware(sloMiddleware(recoverMiddleware(handler, routes), routes), resolveAccessLogFormat(cfg.accessLogFormat), accessLogger, routes)
+
+	// Wrap handler with OpenTelemetry HTTP instrumentation. Spans are named after
+	// the matched route rather than the raw path.
+	otelOpts := []otelhttp.Option{otelhttp.WithSpanNameFormatter(spanName(mux))}
+	if cfg.tracerProvider != nil {
+		otelOpts = append(otelOpts, otelhttp.WithTracerProvider(cfg.tracerProvider))
+	}
+	if cfg.meterProvider != nil {
+		otelOpts = append(otelOpts, otelhttp.WithMeterProvider(cfg.meterProvider))
+	}
+	otelHandler := otelhttp.NewHandler(tlsMiddleware(handler), "http-server", otelOpts...)
 
 	return &Server{
 		httpServer: &http.Server{
-			Addr:         fmt.Sprintf(":%s", port),
-			Handler:      otelHandler,
-			ReadTimeout:  15 * time.Second,
-			WriteTimeout: 15 * time.Second,
-			IdleTimeout:  60 * time.Second,
+			Addr:           cfg.addr,
+			Handler:        otelHandler,
+			ReadTimeout:    cfg.readTimeout,
+			WriteTimeout:   cfg.writeTimeout,
+			IdleTimeout:    cfg.idleTimeout,
+			MaxHeaderBytes: cfg.maxHeaderBytes,
 		},
+		listener:      cfg.listener,
+		tls:           cfg.tls,
+		adminServer:   adminServer,
+		adminListener: cfg.adminListener,
 	}
 }
 
+// Start serves until Shutdown. The admin listener, if any, is opened first and
+// served in the background.
 func (s *Server) Start() error {
+	if s.adminServer != nil {
+		if err := s.startAdmin(); err != nil {
+			return err
+		}
+	}
+	if s.tls != nil {
+		return s.startTLS()
+	}
+	if s.listener != nil {
+		return s.httpServer.Serve(s.listener)
+	}
 	return s.httpServer.ListenAndServe()
 }
 
+// startTLS serves HTTPS, reloading the certificate files until Shutdown
+func (s *Server) startTLS() error {
+	policy, err := s.tls.policy()
+	if err != nil {
+		return err
+	}
+	reloader, err := newCertReloader(*s.tls)
+	if err != nil {
+		return err
+	}
+	s.httpServer.TLSConfig = reloader.tlsConfig(policy)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.httpServer.RegisterOnShutdown(cancel)
+	go reloader.watch(ctx)
+
+	if s.listener != nil {
+		return s.httpServer.ServeTLS(s.listener, "", "")
+	}
+	return s.httpServer.ListenAndServeTLS("", "")
+}
+
+// startAdmin opens the admin listener and serves the admin routes on it
+func (s *Server) startAdmin() error {
+	l := s.adminListener
+	if l == nil {
+		var err error
+		if l, err = net.Listen("tcp", s.adminServer.Addr); err != nil {
+			return fmt.Errorf("admin listener: %w", err)
+		}
+	}
+	go func() {
+		if err := s.adminServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			telemetry.LogError(context.Background(), "Admin server failed", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the public and the admin listener gracefully
 func (s *Server) Shutdown(ctx context.Context) error {
-	// Use test hook if set, otherwise use real server
+	var errs []error
+	// The public listener first, so probes keep answering while requests drain.
+	// Use test hook if set, otherwise use real server.
 	if s.httpShutdowner != nil {
-		return s.httpShutdowner.Shutdown(ctx)
+		errs = append(errs, s.httpShutdowner.Shutdown(ctx))
+	} else if s.httpServer != nil {
+		errs = append(errs, s.httpServer.Shutdown(ctx))
 	}
-	if s.httpServer != nil {
-		return s.httpServer.Shutdown(ctx)
+	if s.adminServer != nil {
+		errs = append(errs, s.adminServer.Shutdown(ctx))
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 func handleRoot(w http.ResponseWriter, r *http.Request) {
@@ -72,7 +230,7 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 	
 	// Get span from context for tracing
 	span := trace.SpanFromContext(ctx)
-	tracer := otel.Tracer("dm-nkp-gitops-custom-app/server")
+	tracer := span.TracerProvider().Tracer(tracerName)
 	
 	// Create a child span for processing
 	ctx, processSpan := tracer.Start(ctx, "process.request")
@@ -82,7 +240,6 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 		span.SetAttributes(
 			attribute.String("http.method", r.Method),
 			attribute.String("http.url", r.URL.String()),
-			attribute.String("http.route", "/"),
 			attribute.String("user_agent", r.UserAgent()),
 			attribute.String("http.client_ip", r.RemoteAddr),
 		)
@@ -92,31 +249,46 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 		)
 	}
 	
-	// Log request with structured logging
-	telemetry.LogInfo(ctx, fmt.Sprintf("Received request: method=%s path=%s remote_addr=%s", 
-		r.Method, r.URL.Path, r.RemoteAddr))
+	// Log request with structured logging. The child logger carries method and
+	// path to every record logged for this request.
+	logger := telemetry.ScopedLogger(telemetry.ScopeServer).With(
+		slog.String(telemetry.HTTPMethodKey, r.Method),
+		slog.String(telemetry.URLPathKey, r.URL.Path),
+	)
+	ctx = telemetry.ContextWithLogger(ctx, logger)
+	logger.InfoContext(ctx, "Received request", slog.String(telemetry.ClientAddressKey, r.RemoteAddr))
 	
 	// Update metrics
 	metrics.IncrementRequestCounter()
-	metrics.IncrementRequestCounterVec(r.Method, "200")
 	metrics.UpdateActiveConnections(1)
+	defer metrics.UpdateActiveConnections(0)
 	
 	// Simulate some business logic with a trace span
 	ctx, businessSpan := tracer.Start(ctx, "business.logic")
 	businessSpan.SetAttributes(attribute.String("business.operation", "generate_response"))
 	telemetry.LogInfo(ctx, "Processing business logic for root endpoint")
 	
-	// Simulate processing time
-	time.Sleep(10 * time.Millisecond)
+	// Simulate processing time. Work stops as soon as the request is cancelled
+	// or out of time; the deadline middleware then answers.
+	if err := sleepContext(ctx, 10*time.Millisecond); err != nil {
+		businessSpan.RecordError(err)
+		businessSpan.End()
+		status := contextErrorStatus(err)
+		logger.WarnContext(ctx, "Request abandoned",
+			slog.Int(telemetry.HTTPStatusCodeKey, status),
+			slog.Float64(telemetry.DurationMsKey, float64(time.Since(start).Nanoseconds())/1e6),
+		)
+		return
+	}
 	
 	businessSpan.End()
+	metrics.IncrementRequestCounterVec(r.Method, "200")
 	
-	responseBody := `{"message": "Hello from dm-nkp-gitops-custom-app", "version": "0.1.0"}`
+	responseBody := fmt.Sprintf(`{"message": %q, "version": %q}`, greetingMessage, appVersion)
 	
 	defer func() {
 		duration := time.Since(start)
 		metrics.UpdateRequestDuration(duration)
-		metrics.UpdateActiveConnections(0)
 		metrics.UpdateResponseSize(float64(len(responseBody)))
 		
 		// Add span attributes with timing information
@@ -133,8 +305,11 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 		}
 		
 		// Log completion with structured information
-		telemetry.LogInfo(ctx, fmt.Sprintf("Request completed: status=%d duration_ms=%.2f response_size=%d",
-			http.StatusOK, float64(duration.Nanoseconds())/1e6, len(responseBody)))
+		logger.InfoContext(ctx, "Request completed",
+			slog.Int(telemetry.HTTPStatusCodeKey, http.StatusOK),
+			slog.Float64(telemetry.DurationMsKey, float64(duration.Nanoseconds())/1e6),
+			slog.Int(telemetry.ResponseBodySizeKey, len(responseBody)),
+		)
 	}()
 	
 	w.Header().Set("Content-Type", "application/json")
@@ -143,81 +318,47 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleHealth(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	span := trace.SpanFromContext(ctx)
-	tracer := otel.Tracer("dm-nkp-gitops-custom-app/server")
-	
-	ctx, healthSpan := tracer.Start(ctx, "health.check")
-	defer healthSpan.End()
-	
+	span := trace.SpanFromContext(r.Context())
 	if span.IsRecording() {
 		span.SetAttributes(
 			attribute.String("http.method", r.Method),
 			attribute.String("http.url", r.URL.String()),
 			attribute.String("health.check.type", "liveness"),
 		)
-		healthSpan.SetAttributes(
-			attribute.String("check.type", "liveness"),
-			attribute.String("endpoint", "/health"),
-		)
 	}
-	
-	// Log health check with structured logging
-	telemetry.LogInfo(ctx, "Health check requested: type=liveness")
-	
-	// Perform health checks (simulate)
-	ctx, checkSpan := tracer.Start(ctx, "health.checks.run")
-	checkSpan.SetAttributes(
-		attribute.String("check.component", "application"),
-		attribute.Bool("check.status", true),
-	)
-	telemetry.LogInfo(ctx, "Running health checks: component=application status=healthy")
-	time.Sleep(5 * time.Millisecond) // Simulate check time
-	checkSpan.End()
-	
+
 	w.Header().Set("Content-Type", "application/json")
+	if !checkLiveness(r.Context(), "/health") {
+		if r.Context().Err() != nil {
+			return // cancelled: the deadline middleware answers
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprint(w, `{"status": "unhealthy"}`)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	_, _ = fmt.Fprint(w, `{"status": "healthy"}`)
-	
-	telemetry.LogInfo(ctx, "Health check completed: status=healthy")
 }
 
 func handleReady(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	span := trace.SpanFromContext(ctx)
-	tracer := otel.Tracer("dm-nkp-gitops-custom-app/server")
-	
-	ctx, readySpan := tracer.Start(ctx, "readiness.check")
-	defer readySpan.End()
-	
+	span := trace.SpanFromContext(r.Context())
 	if span.IsRecording() {
 		span.SetAttributes(
 			attribute.String("http.method", r.Method),
 			attribute.String("http.url", r.URL.String()),
 			attribute.String("health.check.type", "readiness"),
 		)
-		readySpan.SetAttributes(
-			attribute.String("check.type", "readiness"),
-			attribute.String("endpoint", "/ready"),
-		)
 	}
-	
-	// Log readiness check with structured logging
-	telemetry.LogInfo(ctx, "Readiness check requested: type=readiness")
-	
-	// Perform readiness checks (simulate)
-	ctx, checkSpan := tracer.Start(ctx, "readiness.checks.run")
-	checkSpan.SetAttributes(
-		attribute.String("check.component", "metrics"),
-		attribute.Bool("check.status", true),
-	)
-	telemetry.LogInfo(ctx, "Running readiness checks: component=metrics status=ready")
-	time.Sleep(5 * time.Millisecond) // Simulate check time
-	checkSpan.End()
-	
+
 	w.Header().Set("Content-Type", "application/json")
+	if !checkReadiness(r.Context(), "/ready") {
+		if r.Context().Err() != nil {
+			return // cancelled: the deadline middleware answers
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprint(w, `{"status": "not ready"}`)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	_, _ = fmt.Fprint(w, `{"status": "ready"}`)
-	
-	telemetry.LogInfo(ctx, "Readiness check completed: status=ready")
 }
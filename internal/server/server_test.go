@@ -1,15 +1,32 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/slo"
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
 )
 
 // mockShutdowner is a mock implementation of shutdowner for testing
@@ -30,7 +47,7 @@ var _ = Describe("Server", func() {
 	var srv *Server
 
 	BeforeEach(func() {
-		srv = New("8080")
+		srv = New(WithPort("8080"))
 	})
 
 	AfterEach(func() {
@@ -41,7 +58,7 @@ var _ = Describe("Server", func() {
 
 	Describe("Server creation", func() {
 		It("should create a new server with specified port", func() {
-			testSrv := New("8081")
+			testSrv := New(WithPort("8081"))
 			Expect(testSrv).NotTo(BeNil())
 			Expect(testSrv.httpServer).NotTo(BeNil())
 			Expect(testSrv.httpServer.Addr).To(Equal(":8081"))
@@ -53,10 +70,10 @@ var _ = Describe("Server", func() {
 		})
 
 		It("should create a server with different ports", func() {
-			testSrv1 := New("9000")
+			testSrv1 := New(WithPort("9000"))
 			Expect(testSrv1.httpServer.Addr).To(Equal(":9000"))
 			
-			testSrv2 := New("9001")
+			testSrv2 := New(WithPort("9001"))
 			Expect(testSrv2.httpServer.Addr).To(Equal(":9001"))
 
 			// Clean up
@@ -67,7 +84,7 @@ var _ = Describe("Server", func() {
 		})
 
 		It("should create server with handler configured", func() {
-			testSrv := New("8084")
+			testSrv := New(WithPort("8084"))
 			Expect(testSrv.httpServer.Handler).NotTo(BeNil())
 
 			// Clean up
@@ -151,7 +168,7 @@ var _ = Describe("Server", func() {
 
 	Describe("Start", func() {
 		It("should start server", func() {
-			testSrv := New("8083")
+			testSrv := New(WithPort("8083"))
 			started := make(chan bool, 1)
 			errChan := make(chan error, 1)
 
@@ -186,7 +203,7 @@ var _ = Describe("Server", func() {
 
 	Describe("Shutdown", func() {
 		It("should shutdown server gracefully", func() {
-			testSrv := New("8082")
+			testSrv := New(WithPort("8082"))
 			ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 			defer cancel()
 
@@ -218,4 +235,537 @@ var _ = Describe("Server", func() {
 			Expect(err.Error()).To(ContainSubstring("http shutdown error"))
 		})
 	})
+
+	Describe("Admin endpoints", func() {
+		It("should flush metrics on POST", func() {
+			req := httptest.NewRequest("POST", "/admin/metrics/flush", nil)
+			w := httptest.NewRecorder()
+
+			handleMetricsFlush(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(ContainSubstring("flushed"))
+		})
+
+		It("should report flush errors as JSON", func() {
+			DeferCleanup(func(previous func(context.Context) error) { flushMetrics = previous }, flushMetrics)
+			flushMetrics = func(context.Context) error { return errors.New("export failed:\x00\a \xff") }
+
+			w := httptest.NewRecorder()
+			handleMetricsFlush(w, httptest.NewRequest("POST", "/admin/metrics/flush", nil))
+
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+			Expect(w.Body.String()).To(MatchJSON(`{"status": "error", "error": "export failed:\u0000\u0007 \ufffd"}`))
+		})
+
+		It("should reject other methods", func() {
+			req := httptest.NewRequest("GET", "/admin/metrics/flush", nil)
+			w := httptest.NewRecorder()
+
+			errorsAsJSON(newMux()).ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(w.Header().Get("Allow")).To(Equal("POST"))
+		})
+	})
+
+	Describe("Log levels", func() {
+		AfterEach(func() {
+			telemetry.ResetLogLevels(context.Background())
+		})
+
+		It("should report the current levels", func() {
+			w := httptest.NewRecorder()
+			handleLogLevel(w, httptest.NewRequest("GET", "/admin/loglevel", nil))
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`{"level": "info"}`))
+		})
+
+		It("should change levels with a TTL", func() {
+			w := httptest.NewRecorder()
+			handleLogLevel(w, httptest.NewRequest("PUT", "/admin/loglevel",
+				strings.NewReader(`{"scopes": {"server": "debug"}, "ttl": "5m"}`)))
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			var config telemetry.LogLevelConfig
+			Expect(json.Unmarshal(w.Body.Bytes(), &config)).To(Succeed())
+			Expect(config.Scopes).To(Equal(map[string]string{"server": "debug"}))
+			Expect(config.ExpiresAt).NotTo(BeNil())
+			Expect(time.Until(*config.ExpiresAt)).To(BeNumerically("~", 5*time.Minute, time.Minute))
+		})
+
+		It("should reset levels on DELETE", func() {
+			_, err := telemetry.SetLogLevels(context.Background(), "debug", nil, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+
+			w := httptest.NewRecorder()
+			handleLogLevel(w, httptest.NewRequest("DELETE", "/admin/loglevel", nil))
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`{"level": "info"}`))
+		})
+
+		It("should reject invalid requests", func() {
+			for _, body := range []string{`{"level": "loud"}`, `{"scopes": {"db": "debug"}}`, `{"ttl": "soon"}`, `not json`} {
+				w := httptest.NewRecorder()
+				handleLogLevel(w, httptest.NewRequest("PUT", "/admin/loglevel", strings.NewReader(body)))
+
+				Expect(w.Code).To(Equal(http.StatusBadRequest), body)
+			}
+
+			w := httptest.NewRecorder()
+			errorsAsJSON(newMux()).ServeHTTP(w, httptest.NewRequest("POST", "/admin/loglevel", nil))
+			Expect(w.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(w.Header().Get("Allow")).To(Equal("DELETE, GET, HEAD, PUT"))
+		})
+		It("should encode error messages as JSON", func() {
+			w := httptest.NewRecorder()
+			writeJSONError(w, http.StatusBadRequest, "bad \x01 \"byte\" \xff")
+
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(w.Body.String()).To(MatchJSON(`{"error": "bad \u0001 \"byte\" \ufffd"}`))
+		})
+	})
+
+	Describe("Metrics catalog", func() {
+		It("should serve the catalog as JSON", func() {
+			w := httptest.NewRecorder()
+			handleMetricsCatalog(w, httptest.NewRequest("GET", "/debug/metrics/catalog", nil))
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+			var body struct {
+				Instruments []metrics.Instrument `json:"instruments"`
+			}
+			Expect(json.Unmarshal(w.Body.Bytes(), &body)).To(Succeed())
+			Expect(body.Instruments).To(Equal(metrics.Catalog()))
+		})
+
+		It("should serve the catalog as Markdown", func() {
+			for _, req := range []*http.Request{
+				httptest.NewRequest("GET", "/debug/metrics/catalog?format=markdown", nil),
+				func() *http.Request {
+					r := httptest.NewRequest("GET", "/debug/metrics/catalog", nil)
+					r.Header.Set("Accept", "text/markdown")
+					return r
+				}(),
+			} {
+				w := httptest.NewRecorder()
+				handleMetricsCatalog(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Header().Get("Content-Type")).To(HavePrefix("text/markdown"))
+				Expect(w.Body.String()).To(ContainSubstring("| `http_requests_total` | counter |"))
+			}
+		})
+	})
+
+	Describe("SLO tracking", func() {
+		It("should report SLO status as JSON", func() {
+			Expect(slo.Initialize()).To(Succeed())
+
+			// Serve a request through the middleware so it is accounted
+			handler := sloMiddleware(http.HandlerFunc(handleRoot), routeResolver{mux: newPublicMux()})
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			w := httptest.NewRecorder()
+			handleSLO(w, httptest.NewRequest("GET", "/slo", nil))
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			var body struct {
+				SLOs []slo.Status `json:"slos"`
+			}
+			Expect(json.Unmarshal(w.Body.Bytes(), &body)).To(Succeed())
+			Expect(body.SLOs).NotTo(BeEmpty())
+			Expect(body.SLOs[0].TotalEvents).To(BeNumerically(">=", 1))
+		})
+
+		It("should record the status written by the handler", func() {
+			rec := newResponseRecorder(httptest.NewRecorder())
+			rec.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = rec.Write([]byte("unavailable"))
+
+			Expect(rec.status).To(Equal(http.StatusServiceUnavailable))
+			Expect(rec.bytes).To(BeEquivalentTo(len("unavailable")))
+		})
+	})
+
+	Describe("Access log", func() {
+		var buf *bytes.Buffer
+
+		serve := func(format string, status int, target string) map[string]any {
+			buf = &bytes.Buffer{}
+			logger := slog.New(slog.NewJSONHandler(buf, nil))
+			mux := http.NewServeMux()
+			mux.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte("hello"))
+			})
+			req := httptest.NewRequest("GET", target, nil)
+			req.RemoteAddr = "192.0.2.77:51234"
+			req.Header.Set("User-Agent", "curl/8.5.0")
+			req.Header.Set("Referer", "https://example.com/")
+			accessLogMiddleware(mux, format, logger, routeResolver{mux: mux}).ServeHTTP(httptest.NewRecorder(), req)
+
+			if buf.Len() == 0 {
+				return nil
+			}
+			var record map[string]any
+			Expect(json.Unmarshal(buf.Bytes(), &record)).To(Succeed())
+			return record
+		}
+
+		It("should log one combined record per request", func() {
+			record := serve(AccessLogCombined, http.StatusOK, "/items/7?token=s3cret&page=2")
+
+			Expect(record["level"]).To(Equal("INFO"))
+			Expect(record["msg"]).To(MatchRegexp(
+				`^192\.0\.2\.0 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /items/7\?page=2 HTTP/1\.1" 200 5 "https://example.com/" "[0-9a-f]{16}"$`))
+			Expect(record).To(HaveKeyWithValue(telemetry.HTTPMethodKey, "GET"))
+			Expect(record).To(HaveKeyWithValue(telemetry.HTTPRouteKey, "/items/{id}"))
+			Expect(record).To(HaveKeyWithValue(telemetry.URLPathKey, "/items/7"))
+			Expect(record).To(HaveKeyWithValue(telemetry.HTTPStatusCodeKey, BeEquivalentTo(200)))
+			Expect(record).To(HaveKeyWithValue(telemetry.ResponseBodySizeKey, BeEquivalentTo(5)))
+			Expect(record).To(HaveKey(telemetry.DurationMsKey))
+			Expect(record).To(HaveKeyWithValue(telemetry.UserAgentKey, "curl/8.5.0"))
+			Expect(record).To(HaveKeyWithValue(telemetry.HTTPRefererKey, "https://example.com/"))
+			Expect(record).To(HaveKeyWithValue(telemetry.ClientAddressKey, "192.0.2.77"))
+		})
+
+		It("should log the Common Log Format", func() {
+			record := serve(AccessLogCommon, http.StatusOK, "/items/7")
+			Expect(record["msg"]).To(HaveSuffix(`"GET /items/7 HTTP/1.1" 200 5`))
+		})
+
+		It("should log JSON bodies", func() {
+			record := serve(AccessLogJSON, http.StatusOK, "/items/7")
+
+			var body map[string]any
+			Expect(json.Unmarshal([]byte(record["msg"].(string)), &body)).To(Succeed())
+			Expect(body).To(HaveKeyWithValue("method", "GET"))
+			Expect(body).To(HaveKeyWithValue("route", "/items/{id}"))
+			Expect(body).To(HaveKeyWithValue("status", BeEquivalentTo(200)))
+			Expect(body).To(HaveKeyWithValue("client_ip", "192.0.2.0"))
+			Expect(body).To(HaveKey("duration_ms"))
+		})
+
+		It("should log 5xx responses at warn", func() {
+			record := serve(AccessLogCommon, http.StatusBadGateway, "/items/7")
+			Expect(record["level"]).To(Equal("WARN"))
+		})
+
+		It("should log nothing when off", func() {
+			Expect(serve(AccessLogOff, http.StatusOK, "/items/7")).To(BeNil())
+		})
+
+		It("should reject unknown formats", func() {
+			_, err := parseAccessLogFormat("apache")
+			Expect(err).To(MatchError(ContainSubstring(`invalid access log format "apache"`)))
+			Expect(parseAccessLogFormat(" JSON ")).To(Equal(AccessLogJSON))
+		})
+	})
+
+	Describe("Panic recovery", func() {
+		serve := func(handler http.HandlerFunc) (*httptest.ResponseRecorder, tracetest.SpanStubs) {
+			exporter := tracetest.NewInMemoryExporter()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			DeferCleanup(provider.Shutdown, context.Background())
+
+			mux := http.NewServeMux()
+			mux.Handle("/items/{id}", handler)
+			ctx, span := provider.Tracer("test").Start(context.Background(), "GET /items/{id}")
+			req := httptest.NewRequest("GET", "/items/7", nil).WithContext(ctx)
+			w := httptest.NewRecorder()
+			recoverMiddleware(mux, routeResolver{mux: mux}).ServeHTTP(w, req)
+			span.End()
+			return w, exporter.GetSpans()
+		}
+
+		It("should return a 500 JSON body and record the panic on the span", func() {
+			w, spans := serve(func(http.ResponseWriter, *http.Request) {
+				panic("boom")
+			})
+
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+			Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(w.Body.String()).To(MatchJSON(`{"error": "internal server error"}`))
+
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Status.Code).To(Equal(codes.Error))
+			Expect(spans[0].Status.Description).To(Equal("panic: boom"))
+			Expect(spans[0].Events).To(HaveLen(1))
+			Expect(spans[0].Events[0].Name).To(Equal("exception"))
+			attrs := map[string]string{}
+			for _, a := range spans[0].Events[0].Attributes {
+				attrs[string(a.Key)] = a.Value.Emit()
+			}
+			Expect(attrs).To(HaveKeyWithValue(telemetry.ExceptionMessageKey, "panic: boom"))
+			// The stack goes down to the panicking handler
+			Expect(attrs[telemetry.ExceptionStacktraceKey]).To(ContainSubstring("server_test.go"))
+		})
+
+		It("should keep panicking errors in the chain", func() {
+			_, spans := serve(func(http.ResponseWriter, *http.Request) {
+				var m map[string]int
+				m["x"] = 1
+			})
+
+			attrs := map[string]string{}
+			for _, a := range spans[0].Events[0].Attributes {
+				attrs[string(a.Key)] = a.Value.Emit()
+			}
+			Expect(attrs).To(HaveKeyWithValue(telemetry.ExceptionTypeKey, "runtime.plainError"))
+		})
+
+		It("should leave responses already started alone", func() {
+			w, _ := serve(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("late")
+			})
+			Expect(w.Code).To(Equal(http.StatusAccepted))
+			Expect(w.Body.String()).To(BeEmpty())
+		})
+
+		It("should re-panic http.ErrAbortHandler", func() {
+			Expect(func() {
+				serve(func(http.ResponseWriter, *http.Request) {
+					panic(http.ErrAbortHandler)
+				})
+			}).To(PanicWith(http.ErrAbortHandler))
+		})
+	})
+
+	Describe("Routing", func() {
+		serve := func(method, target string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			errorsAsJSON(newMux()).ServeHTTP(w, httptest.NewRequest(method, target, nil))
+			return w
+		}
+
+		It("should serve the root only on its exact path", func() {
+			Expect(serve("GET", "/").Code).To(Equal(http.StatusOK))
+
+			w := serve("GET", "/wp-login.php")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+			Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(w.Body.String()).To(MatchJSON(`{"error": "not found"}`))
+		})
+
+		It("should answer wrong methods with 405 and the allowed ones", func() {
+			w := serve("DELETE", "/health")
+			Expect(w.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(w.Header().Get("Allow")).To(Equal("GET, HEAD"))
+			Expect(w.Body.String()).To(MatchJSON(`{"error": "method not allowed"}`))
+		})
+
+		It("should leave handler responses alone", func() {
+			w := serve("PUT", "/admin/loglevel")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(w.Body.String()).To(ContainSubstring("invalid request body"))
+		})
+
+		It("should turn patterns into routes", func() {
+			for pattern, route := range map[string]string{
+				"GET /{$}":                  "/",
+				"GET /health":               "/health",
+				"/items/{id}":               "/items/{id}",
+				"POST example.com/a/{b...}": "/a/{b...}",
+				"":                          "",
+			} {
+				Expect(httpRoute(pattern)).To(Equal(route), pattern)
+			}
+		})
+
+		It("should name spans after the route", func() {
+			name := spanName(newMux())
+			Expect(name("", httptest.NewRequest("GET", "/", nil))).To(Equal("GET /"))
+			Expect(name("", httptest.NewRequest("POST", "/admin/metrics/flush", nil))).To(Equal("POST /admin/metrics/flush"))
+			Expect(name("", httptest.NewRequest("GET", "/.env", nil))).To(Equal("GET"))
+		})
+
+		It("should set http.route on the request span", func() {
+			exporter := tracetest.NewInMemoryExporter()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			DeferCleanup(provider.Shutdown, context.Background())
+
+			ctx, span := provider.Tracer("test").Start(context.Background(), "GET /ready")
+			errorsAsJSON(newMux()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ready", nil).WithContext(ctx))
+			span.End()
+
+			// The handler's child spans come from the same provider
+			spans := exporter.GetSpans()
+			Expect(spans).To(ContainElement(HaveField("Name", "readiness.check")))
+			Expect(spans[len(spans)-1].Name).To(Equal("GET /ready"))
+			Expect(spans[len(spans)-1].Attributes).To(ContainElement(attribute.String("http.route", "/ready")))
+		})
+	})
+
+	Describe("Options", func() {
+		It("should keep today's defaults", func() {
+			s := New()
+			Expect(s.httpServer.Addr).To(Equal(":8080"))
+			Expect(s.httpServer.ReadTimeout).To(Equal(15 * time.Second))
+			Expect(s.httpServer.WriteTimeout).To(Equal(15 * time.Second))
+			Expect(s.httpServer.IdleTimeout).To(Equal(60 * time.Second))
+			Expect(s.httpServer.MaxHeaderBytes).To(Equal(http.DefaultMaxHeaderBytes))
+		})
+
+		It("should set the address, timeouts and header limit", func() {
+			s := New(WithAddr("127.0.0.1:9090"), WithTimeouts(time.Second, 2*time.Second, 0), WithMaxHeaderBytes(8<<10))
+			Expect(s.httpServer.Addr).To(Equal("127.0.0.1:9090"))
+			Expect(s.httpServer.ReadTimeout).To(Equal(time.Second))
+			Expect(s.httpServer.WriteTimeout).To(Equal(2 * time.Second))
+			Expect(s.httpServer.IdleTimeout).To(BeZero())
+			Expect(s.httpServer.MaxHeaderBytes).To(Equal(8 << 10))
+		})
+
+		It("should serve extra routes through the middleware in order", func() {
+			tag := func(name string) Middleware {
+				return func(next http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						w.Header().Add("X-Middleware", name)
+						next.ServeHTTP(w, r)
+					})
+				}
+			}
+			exporter := tracetest.NewInMemoryExporter()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			DeferCleanup(provider.Shutdown, context.Background())
+
+			s := New(
+				WithHandler("GET /items/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = fmt.Fprintf(w, "item %s", r.PathValue("id"))
+				})),
+				WithMiddleware(tag("outer"), tag("inner")),
+				WithTracerProvider(provider),
+				WithAccessLog(AccessLogOff, nil),
+			)
+
+			w := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/items/7", nil))
+			Expect(w.Body.String()).To(Equal("item 7"))
+			Expect(w.Header().Values("X-Middleware")).To(Equal([]string{"outer", "inner"}))
+
+			spans := exporter.GetSpans()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Name).To(Equal("GET /items/{id}"))
+			Expect(spans[0].Attributes).To(ContainElement(attribute.String("http.route", "/items/{id}")))
+
+			w = httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/unknown", nil))
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+			Expect(w.Header().Values("X-Middleware")).To(Equal([]string{"outer", "inner"}))
+		})
+
+		It("should record the app's metrics and SLO gauges with the injected meter provider", func() {
+			reader := sdkmetric.NewManualReader()
+			provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+			DeferCleanup(provider.Shutdown, context.Background())
+			DeferCleanup(func() {
+				Expect(metrics.UseMeterProvider(otel.GetMeterProvider())).To(Succeed())
+				Expect(slo.UseMeterProvider(nil)).To(Succeed())
+			})
+			Expect(slo.Initialize()).To(Succeed())
+
+			s := New(WithMeterProvider(provider), WithAccessLog(AccessLogOff, nil))
+			s.httpServer.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			var rm metricdata.ResourceMetrics
+			Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+			var names []string
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					names = append(names, m.Name)
+				}
+			}
+			Expect(names).To(ContainElements(metrics.RequestsTotalName, metrics.RequestDurationName,
+				metrics.SLOErrorBudgetRemainingName))
+		})
+
+		It("should serve on the given listener", func() {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			s := New(WithListener(l), WithAccessLog(AccessLogOff, nil))
+			go func() { _ = s.Start() }()
+			DeferCleanup(s.Shutdown, context.Background())
+
+			resp, err := http.Get("http://" + l.Addr().String() + "/health")
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("Admin listener", func() {
+		var (
+			public, admin string
+			exporter      *tracetest.InMemoryExporter
+			s             *Server
+		)
+
+		BeforeEach(func() {
+			exporter = tracetest.NewInMemoryExporter()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			DeferCleanup(provider.Shutdown, context.Background())
+
+			publicListener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			adminListener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			public = "http://" + publicListener.Addr().String()
+			admin = "http://" + adminListener.Addr().String()
+
+			s = New(
+				WithListener(publicListener),
+				WithAdminListener(adminListener),
+				WithTracerProvider(provider),
+				WithAccessLog(AccessLogOff, nil),
+			)
+			go func() { _ = s.Start() }()
+			DeferCleanup(s.Shutdown, context.Background())
+		})
+
+		get := func(url string) (int, string) {
+			resp, err := http.Get(url)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			var body bytes.Buffer
+			_, err = body.ReadFrom(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			return resp.StatusCode, body.String()
+		}
+		statusOf := func(url string) int {
+			status, _ := get(url)
+			return status
+		}
+
+		It("should serve only business routes on the public listener", func() {
+			Expect(statusOf(public + "/")).To(Equal(http.StatusOK))
+			for _, path := range []string{"/health", "/ready", "/admin/loglevel", "/slo", "/debug/metrics/catalog"} {
+				status, body := get(public + path)
+				Expect(status).To(Equal(http.StatusNotFound), path)
+				Expect(body).To(MatchJSON(`{"error": "not found"}`))
+			}
+		})
+
+		It("should serve the probes and admin routes untraced on the admin listener", func() {
+			exporter.Reset()
+			status, body := get(admin + "/health")
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(body).To(ContainSubstring("healthy"))
+			Expect(statusOf(admin + "/ready")).To(Equal(http.StatusOK))
+			Expect(statusOf(admin + "/admin/loglevel")).To(Equal(http.StatusOK))
+			Expect(statusOf(admin + "/")).To(Equal(http.StatusNotFound))
+			Expect(exporter.GetSpans()).To(BeEmpty())
+		})
+
+		It("should stop both listeners on shutdown", func() {
+			Expect(s.Shutdown(context.Background())).To(Succeed())
+			_, err := http.Get(admin + "/health")
+			Expect(err).To(HaveOccurred())
+			_, err = http.Get(public + "/")
+			Expect(err).To(HaveOccurred())
+		})
+	})
 })
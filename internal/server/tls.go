@@ -0,0 +1,284 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry"
+	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Client certificate policies accepted by TLS_CLIENT_AUTH
+const (
+	// TLSClientAuthNone doesn't ask for client certificates (the default)
+	TLSClientAuthNone = "none"
+	// TLSClientAuthRequest verifies client certificates against the CA bundle
+	// when clients send one, and accepts clients without
+	TLSClientAuthRequest = "request"
+	// TLSClientAuthRequire rejects clients without a certificate the CA bundle verifies
+	TLSClientAuthRequire = "require"
+)
+
+// DefaultTLSReloadInterval is how often the certificate files are checked for changes
+const DefaultTLSReloadInterval = 30 * time.Second
+
+// TLSConfig makes the server serve HTTPS from PEM files, typically the tls.crt
+// and tls.key of a mounted cert-manager secret. The files are re-read every
+// ReloadInterval, so rotated certificates are served without a restart.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is the PEM bundle client certificates are verified against.
+	// Required unless ClientAuth is none.
+	ClientCAFile string
+	// ClientAuth is none, request or require (default none)
+	ClientAuth string
+	// MinVersion is "1.2" or "1.3" (default 1.2)
+	MinVersion string
+	// CipherSuites are the TLS 1.2 cipher suites, by IANA name
+	// (TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256). Empty means Go's defaults; TLS 1.3
+	// suites are not configurable.
+	CipherSuites   []string
+	ReloadInterval time.Duration
+}
+
+// TLSConfigFromEnv reads TLS_CERT_FILE, TLS_KEY_FILE, TLS_CLIENT_CA_FILE,
+// TLS_CLIENT_AUTH, TLS_MIN_VERSION, TLS_CIPHER_SUITES (comma-separated) and
+// TLS_RELOAD_INTERVAL. It returns nil without TLS_CERT_FILE: the server then
+// serves plain HTTP.
+func TLSConfigFromEnv() (*TLSConfig, error) {
+	cfg := &TLSConfig{
+		CertFile:     os.Getenv("TLS_CERT_FILE"),
+		KeyFile:      os.Getenv("TLS_KEY_FILE"),
+		ClientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+		ClientAuth:   os.Getenv("TLS_CLIENT_AUTH"),
+		MinVersion:   os.Getenv("TLS_MIN_VERSION"),
+	}
+	if cfg.CertFile == "" {
+		return nil, nil
+	}
+	for _, name := range strings.Split(os.Getenv("TLS_CIPHER_SUITES"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.CipherSuites = append(cfg.CipherSuites, name)
+		}
+	}
+	if value := os.Getenv("TLS_RELOAD_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS_RELOAD_INTERVAL: %w", err)
+		}
+		cfg.ReloadInterval = interval
+	}
+	if _, err := cfg.policy(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// policy builds the parts of the tls.Config that don't depend on the files
+func (c TLSConfig) policy() (*tls.Config, error) {
+	var errs []error
+	if c.KeyFile == "" {
+		errs = append(errs, errors.New("tls: no key file"))
+	}
+
+	policy := &tls.Config{MinVersion: tls.VersionTLS12}
+	switch c.MinVersion {
+	case "", "1.2":
+	case "1.3":
+		policy.MinVersion = tls.VersionTLS13
+	default:
+		errs = append(errs, fmt.Errorf("tls: invalid min version %q (want 1.2 or 1.3)", c.MinVersion))
+	}
+
+	switch strings.ToLower(c.ClientAuth) {
+	case "", TLSClientAuthNone:
+		policy.ClientAuth = tls.NoClientCert
+	case TLSClientAuthRequest:
+		policy.ClientAuth = tls.VerifyClientCertIfGiven
+	case TLSClientAuthRequire:
+		policy.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		errs = append(errs, fmt.Errorf("tls: invalid client auth %q (want %s, %s or %s)",
+			c.ClientAuth, TLSClientAuthNone, TLSClientAuthRequest, TLSClientAuthRequire))
+	}
+	if policy.ClientAuth != tls.NoClientCert && c.ClientCAFile == "" {
+		errs = append(errs, fmt.Errorf("tls: client auth %s needs a client CA file", c.ClientAuth))
+	}
+
+	if len(c.CipherSuites) > 0 {
+		ids := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			ids[suite.Name] = suite.ID
+		}
+		for _, name := range c.CipherSuites {
+			id, ok := ids[name]
+			if !ok {
+				errs = append(errs, fmt.Errorf("tls: unknown or insecure cipher suite %q", name))
+				continue
+			}
+			policy.CipherSuites = append(policy.CipherSuites, id)
+		}
+	}
+	return policy, errors.Join(errs...)
+}
+
+// certReloader serves the certificate and client CAs last loaded from the files
+type certReloader struct {
+	cfg       TLSConfig
+	cert      atomic.Pointer[tls.Certificate]
+	clientCAs atomic.Pointer[x509.CertPool]
+	// contents are the file contents last loaded, to skip unchanged files
+	contents [][]byte
+}
+
+func newCertReloader(cfg TLSConfig) (*certReloader, error) {
+	r := &certReloader{cfg: cfg}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload loads the files if any of them changed since the last load. A failed
+// load keeps the previous certificate.
+func (r *certReloader) reload() (bool, error) {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	contents := make([][]byte, len(files))
+	changed := r.contents == nil
+	for i, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return false, fmt.Errorf("tls: %w", err)
+		}
+		contents[i] = b
+		changed = changed || !bytes.Equal(b, r.contents[i])
+	}
+	if !changed {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(contents[0], contents[1])
+	if err != nil {
+		return false, fmt.Errorf("tls: %s, %s: %w", r.cfg.CertFile, r.cfg.KeyFile, err)
+	}
+	var pool *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(contents[2]) {
+			return false, fmt.Errorf("tls: no certificates in %s", r.cfg.ClientCAFile)
+		}
+	}
+
+	r.cert.Store(&cert)
+	r.clientCAs.Store(pool)
+	r.contents = contents
+	return true, nil
+}
+
+// watch reloads the files every interval until ctx is done
+func (r *certReloader) watch(ctx context.Context) {
+	interval := r.cfg.ReloadInterval
+	if interval <= 0 {
+		interval = DefaultTLSReloadInterval
+	}
+	logger := telemetry.ScopedLogger(telemetry.ScopeServer)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reloaded, err := r.reload()
+		if err != nil {
+			logger.WarnContext(ctx, "Failed to reload TLS certificate, serving the previous one",
+				slog.String(telemetry.ErrorKey, err.Error()))
+			continue
+		}
+		if reloaded {
+			leaf := r.cert.Load().Leaf
+			logger.InfoContext(ctx, "Reloaded TLS certificate",
+				slog.String(telemetry.TLSServerSubjectKey, leaf.Subject.String()),
+				slog.String(telemetry.TLSServerNotAfterKey, leaf.NotAfter.UTC().Format(time.RFC3339)))
+		}
+	}
+}
+
+// tlsConfig serves the reloader's current certificate and verifies clients
+// against its current CA bundle
+func (r *certReloader) tlsConfig(policy *tls.Config) *tls.Config {
+	cfg := policy.Clone()
+	cfg.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return r.cert.Load(), nil
+	}
+	if cfg.ClientAuth != tls.NoClientCert {
+		base := cfg.Clone()
+		cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c := base.Clone()
+			c.ClientCAs = r.clientCAs.Load()
+			return c, nil
+		}
+	}
+	return cfg
+}
+
+// clientCertKey is the context key of the verified client certificate
+type clientCertKey struct{}
+
+// ClientCertFromContext returns the verified certificate the client presented
+// over mTLS, for handlers to authorize on its subject
+func ClientCertFromContext(ctx context.Context) (*x509.Certificate, bool) {
+	cert, ok := ctx.Value(clientCertKey{}).(*x509.Certificate)
+	return cert, ok
+}
+
+// tlsMiddleware sets the TLS version and cipher on the request span and, for
+// clients that presented a certificate, its subject, issuer, expiry and hash. The
+// certificate is also put in the request context (see ClientCertFromContext).
+func tlsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := r.TLS
+		if state == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		span := trace.SpanFromContext(r.Context())
+		span.SetAttributes(
+			semconv.TLSProtocolVersion(strings.TrimPrefix(tls.VersionName(state.Version), "TLS ")),
+			semconv.TLSCipher(tls.CipherSuiteName(state.CipherSuite)),
+		)
+		if len(state.PeerCertificates) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cert := state.PeerCertificates[0]
+		hash := sha256.Sum256(cert.Raw)
+		span.SetAttributes(
+			semconv.TLSClientSubject(cert.Subject.String()),
+			semconv.TLSClientIssuer(cert.Issuer.String()),
+			semconv.TLSClientNotAfter(cert.NotAfter.UTC().Format(time.RFC3339)),
+			semconv.TLSClientHashSha256(strings.ToUpper(hex.EncodeToString(hash[:]))),
+		)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientCertKey{}, cert)))
+	})
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// testCert is a certificate and key, parsed and PEM-encoded
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// issueCert creates a certificate for cn signed by ca, or a self-signed CA
+// when ca is nil
+func issueCert(cn string, ca *testCert) testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn, Organization: []string{"dm-nkp"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	parent, signer := template, key
+	if ca == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		parent, signer = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	Expect(err).NotTo(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	return testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+var _ = Describe("TLS", func() {
+	var (
+		dir string
+		ca  testCert
+		cfg TLSConfig
+	)
+
+	writeServerCert := func(cn string) {
+		server := issueCert(cn, &ca)
+		Expect(os.WriteFile(cfg.CertFile, server.certPEM, 0o600)).To(Succeed())
+		Expect(os.WriteFile(cfg.KeyFile, server.keyPEM, 0o600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		ca = issueCert("test-ca", nil)
+		cfg = TLSConfig{
+			CertFile:       filepath.Join(dir, "tls.crt"),
+			KeyFile:        filepath.Join(dir, "tls.key"),
+			ClientCAFile:   filepath.Join(dir, "ca.crt"),
+			ReloadInterval: 20 * time.Millisecond,
+		}
+		Expect(os.WriteFile(cfg.ClientCAFile, ca.certPEM, 0o600)).To(Succeed())
+		writeServerCert("server-1")
+	})
+
+	Describe("policy", func() {
+		It("should default to TLS 1.2 without client certificates", func() {
+			policy, err := cfg.policy()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy.MinVersion).To(BeEquivalentTo(tls.VersionTLS12))
+			Expect(policy.ClientAuth).To(Equal(tls.NoClientCert))
+			Expect(policy.CipherSuites).To(BeEmpty())
+		})
+
+		It("should map versions, client auth and cipher suites", func() {
+			cfg.MinVersion = "1.3"
+			cfg.ClientAuth = "Require"
+			cfg.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}
+			policy, err := cfg.policy()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy.MinVersion).To(BeEquivalentTo(tls.VersionTLS13))
+			Expect(policy.ClientAuth).To(Equal(tls.RequireAndVerifyClientCert))
+			Expect(policy.CipherSuites).To(Equal([]uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}))
+		})
+
+		It("should reject invalid settings", func() {
+			_, err := TLSConfig{
+				CertFile:     "tls.crt",
+				MinVersion:   "1.1",
+				ClientAuth:   TLSClientAuthRequest,
+				CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"},
+			}.policy()
+			Expect(err).To(MatchError(ContainSubstring("tls: no key file")))
+			Expect(err).To(MatchError(ContainSubstring(`tls: invalid min version "1.1"`)))
+			Expect(err).To(MatchError(ContainSubstring("tls: client auth request needs a client CA file")))
+			Expect(err).To(MatchError(ContainSubstring(`tls: unknown or insecure cipher suite "TLS_RSA_WITH_RC4_128_SHA"`)))
+		})
+	})
+
+	Describe("TLSConfigFromEnv", func() {
+		It("should return nil without a certificate file", func() {
+			Expect(TLSConfigFromEnv()).To(BeNil())
+		})
+
+		It("should read the environment", func() {
+			for key, value := range map[string]string{
+				"TLS_CERT_FILE":       cfg.CertFile,
+				"TLS_KEY_FILE":        cfg.KeyFile,
+				"TLS_CLIENT_CA_FILE":  cfg.ClientCAFile,
+				"TLS_CLIENT_AUTH":     TLSClientAuthRequire,
+				"TLS_MIN_VERSION":     "1.3",
+				"TLS_CIPHER_SUITES":   "TLS_AES_128_GCM_SHA256, TLS_CHACHA20_POLY1305_SHA256",
+				"TLS_RELOAD_INTERVAL": "1m",
+			} {
+				os.Setenv(key, value)
+				DeferCleanup(os.Unsetenv, key)
+			}
+
+			Expect(TLSConfigFromEnv()).To(Equal(&TLSConfig{
+				CertFile:       cfg.CertFile,
+				KeyFile:        cfg.KeyFile,
+				ClientCAFile:   cfg.ClientCAFile,
+				ClientAuth:     TLSClientAuthRequire,
+				MinVersion:     "1.3",
+				CipherSuites:   []string{"TLS_AES_128_GCM_SHA256", "TLS_CHACHA20_POLY1305_SHA256"},
+				ReloadInterval: time.Minute,
+			}))
+		})
+	})
+
+	Describe("serving", func() {
+		var (
+			addr     string
+			exporter *tracetest.InMemoryExporter
+		)
+
+		start := func() {
+			exporter = tracetest.NewInMemoryExporter()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			DeferCleanup(provider.Shutdown, context.Background())
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			addr = l.Addr().String()
+			s := New(
+				WithListener(l),
+				WithTLS(cfg),
+				WithTracerProvider(provider),
+				WithAccessLog(AccessLogOff, nil),
+				WithHandler("GET /whoami", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if cert, ok := ClientCertFromContext(r.Context()); ok {
+						_, _ = io.WriteString(w, cert.Subject.CommonName)
+					}
+				})),
+			)
+			go func() { _ = s.Start() }()
+			DeferCleanup(s.Shutdown, context.Background())
+		}
+
+		// get requests path on a new connection, presenting client when set
+		get := func(path string, client *testCert) (*http.Response, string, error) {
+			pool := x509.NewCertPool()
+			pool.AddCert(ca.cert)
+			tlsConfig := &tls.Config{RootCAs: pool}
+			if client != nil {
+				tlsConfig.Certificates = []tls.Certificate{{
+					Certificate: [][]byte{client.cert.Raw},
+					PrivateKey:  client.key,
+				}}
+			}
+			httpClient := &http.Client{
+				Transport: &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true},
+				Timeout:   5 * time.Second,
+			}
+			resp, err := httpClient.Get("https://" + addr + path)
+			if err != nil {
+				return nil, "", err
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			return resp, string(body), err
+		}
+
+		It("should serve HTTPS and reload rotated certificates", func() {
+			start()
+
+			resp, _, err := get("/health", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.TLS.PeerCertificates[0].Subject.CommonName).To(Equal("server-1"))
+
+			writeServerCert("server-2")
+			Eventually(func() string {
+				resp, _, err := get("/health", nil)
+				if err != nil {
+					return err.Error()
+				}
+				return resp.TLS.PeerCertificates[0].Subject.CommonName
+			}).Should(Equal("server-2"))
+		})
+
+		It("should keep serving the previous certificate when the files are broken", func() {
+			start()
+			_, _, err := get("/health", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(cfg.KeyFile, []byte("not a key"), 0o600)).To(Succeed())
+
+			Consistently(func() error {
+				_, _, err := get("/health", nil)
+				return err
+			}, 100*time.Millisecond, 20*time.Millisecond).Should(Succeed())
+		})
+
+		It("should fail to start without the files", func() {
+			cfg.CertFile = filepath.Join(dir, "missing.crt")
+			Expect(New(WithTLS(cfg)).Start()).To(MatchError(ContainSubstring("missing.crt")))
+		})
+
+		It("should require client certificates and expose them to handlers and spans", func() {
+			cfg.ClientAuth = TLSClientAuthRequire
+			start()
+
+			client := issueCert("client-a", &ca)
+			resp, body, err := get("/whoami", &client)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("client-a"))
+
+			spans := exporter.GetSpans()
+			server := spans[len(spans)-1]
+			Expect(server.Attributes).To(ContainElements(
+				attribute.String("tls.client.subject", "CN=client-a,O=dm-nkp"),
+				attribute.String("tls.client.issuer", "CN=test-ca,O=dm-nkp"),
+				attribute.String("tls.protocol.version", "1.3"),
+			))
+
+			_, _, err = get("/whoami", nil)
+			Expect(err).To(HaveOccurred())
+
+			stranger := issueCert("client-b", nil)
+			_, _, err = get("/whoami", &stranger)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should accept clients without certificates when requested", func() {
+			cfg.ClientAuth = TLSClientAuthRequest
+			start()
+
+			resp, body, err := get("/whoami", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,206 @@
+package slo
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Config is the on-disk SLO configuration (see SLO_CONFIG_FILE)
+type Config struct {
+	SLOs []Definition `yaml:"slos"`
+}
+
+// Definition describes a single service level objective.
+// An event (request) is bad when its status matches BadStatus, or when
+// LatencyThreshold is set and the request took longer than it.
+type Definition struct {
+	// Name identifies the SLO in metrics and in the /slo report
+	Name string `yaml:"name" json:"name"`
+	// Description is a human readable summary
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// Target is the fraction of good events, e.g. 0.999
+	Target float64 `yaml:"target" json:"target"`
+	// Window is the compliance period, e.g. 30d
+	Window Duration `yaml:"window" json:"window"`
+	// Routes limits the SLO to these route patterns (empty means all routes)
+	Routes []string `yaml:"routes,omitempty" json:"routes,omitempty"`
+	// BadStatus lists status codes ("503") or classes ("5xx") that count as bad events
+	BadStatus []string `yaml:"bad_status,omitempty" json:"bad_status,omitempty"`
+	// LatencyThreshold marks slower requests as bad events (0 disables the latency criterion)
+	LatencyThreshold Duration `yaml:"latency_threshold,omitempty" json:"latency_threshold,omitempty"`
+}
+
+// Duration is a time.Duration that also accepts a day suffix ("30d") in config files
+type Duration time.Duration
+
+// UnmarshalYAML parses durations such as "250ms", "6h" or "30d"
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := ParseDuration(value.Value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalYAML writes the duration in the same format it is parsed from
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+// MarshalJSON writes the duration as a string (e.g. "30d")
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// UnmarshalJSON parses a duration string such as "30d"
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	raw, err := strconv.Unquote(string(data))
+	if err != nil {
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	parsed, err := ParseDuration(raw)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// String formats whole days, hours and minutes compactly ("30d", "6h", "5m")
+// and everything else like time.Duration
+func (d Duration) String() string {
+	td := time.Duration(d)
+	switch {
+	case td <= 0:
+		return td.String()
+	case td%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", td/(24*time.Hour))
+	case td%time.Hour == 0:
+		return fmt.Sprintf("%dh", td/time.Hour)
+	case td%time.Minute == 0:
+		return fmt.Sprintf("%dm", td/time.Minute)
+	default:
+		return td.String()
+	}
+}
+
+// ParseDuration parses a Go duration, additionally accepting whole days ("30d")
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// DefaultDefinitions returns the SLOs used when no config file is provided:
+// 99.9% availability and 99% of requests under 250ms for the root route over 30 days.
+func DefaultDefinitions() []Definition {
+	return []Definition{
+		{
+			Name:        "availability",
+			Description: "Requests to / that don't fail with a server error",
+			Target:      0.999,
+			Window:      Duration(30 * 24 * time.Hour),
+			Routes:      []string{"/"},
+			BadStatus:   []string{"5xx"},
+		},
+		{
+			Name:             "latency",
+			Description:      "Requests to / served in under 250ms",
+			Target:           0.99,
+			Window:           Duration(30 * 24 * time.Hour),
+			Routes:           []string{"/"},
+			LatencyThreshold: Duration(250 * time.Millisecond),
+		},
+	}
+}
+
+// LoadConfig reads SLO definitions from a YAML file
+func LoadConfig(path string) ([]Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SLO config: %w", err)
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig parses and validates YAML SLO definitions
+func ParseConfig(data []byte) ([]Definition, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse SLO config: %w", err)
+	}
+	if err := Validate(cfg.SLOs); err != nil {
+		return nil, err
+	}
+	return cfg.SLOs, nil
+}
+
+// Validate checks that the definitions are complete and consistent
+func Validate(defs []Definition) error {
+	seen := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		if def.Name == "" {
+			return fmt.Errorf("SLO definition without a name")
+		}
+		if seen[def.Name] {
+			return fmt.Errorf("duplicate SLO %q", def.Name)
+		}
+		seen[def.Name] = true
+
+		if def.Target <= 0 || def.Target >= 1 {
+			return fmt.Errorf("SLO %q: target must be between 0 and 1 (exclusive), got %v", def.Name, def.Target)
+		}
+		if time.Duration(def.Window) < time.Hour {
+			return fmt.Errorf("SLO %q: window must be at least 1h", def.Name)
+		}
+		if len(def.BadStatus) == 0 && def.LatencyThreshold <= 0 {
+			return fmt.Errorf("SLO %q: needs bad_status and/or latency_threshold", def.Name)
+		}
+		for _, pattern := range def.BadStatus {
+			if _, err := parseStatusPattern(pattern); err != nil {
+				return fmt.Errorf("SLO %q: %w", def.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// statusPattern matches a single status code or a whole class ("5xx")
+type statusPattern struct {
+	code  int
+	class int
+}
+
+func parseStatusPattern(s string) (statusPattern, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) == 3 && strings.HasSuffix(s, "xx") && s[0] >= '1' && s[0] <= '5' {
+		return statusPattern{class: int(s[0] - '0')}, nil
+	}
+	code, err := strconv.Atoi(s)
+	if err != nil || code < 100 || code > 599 {
+		return statusPattern{}, fmt.Errorf("invalid status pattern %q (want e.g. 503 or 5xx)", s)
+	}
+	return statusPattern{code: code}, nil
+}
+
+func (p statusPattern) matches(status int) bool {
+	if p.class != 0 {
+		return status/100 == p.class
+	}
+	return status == p.code
+}
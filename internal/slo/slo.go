@@ -0,0 +1,163 @@
+package slo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Metric names of the SLO gauges (listed in the metrics catalog)
+const (
+	ErrorBudgetRemainingName = metrics.SLOErrorBudgetRemainingName
+	BurnRateName             = metrics.SLOBurnRateName
+	TargetName               = metrics.SLOTargetName
+)
+
+var (
+	// defaultTracker evaluates the configured SLOs (nil until Initialize)
+	defaultTracker *Tracker
+	// meterProvider holds the SLO gauges (nil for the global meter provider) and
+	// registration the callback observing them
+	meterProvider metric.MeterProvider
+	registration  metric.Registration
+	mu            sync.RWMutex
+)
+
+// Initialize loads SLO definitions and registers the SLO gauges on the global meter provider,
+// or on the one of UseMeterProvider.
+// Definitions are read from the YAML file in SLO_CONFIG_FILE, or DefaultDefinitions() when unset.
+// Call it after metrics.Initialize so the gauges are exported with the other metrics.
+func Initialize() error {
+	defs := DefaultDefinitions()
+	if path := os.Getenv("SLO_CONFIG_FILE"); path != "" {
+		loaded, err := LoadConfig(path)
+		if err != nil {
+			return err
+		}
+		defs = loaded
+	}
+
+	tracker := NewTracker(defs)
+	mu.Lock()
+	err := registerLocked(tracker)
+	if err == nil {
+		defaultTracker = tracker
+	}
+	mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Note: Use log.Printf here since telemetry logger may not be initialized yet
+	log.Printf("SLO tracking initialized with %d objective(s)", len(defs))
+	return nil
+}
+
+// UseMeterProvider registers the SLO gauges on mp instead of the global meter
+// provider (nil), now and on later calls of Initialize
+func UseMeterProvider(mp metric.MeterProvider) error {
+	mu.Lock()
+	defer mu.Unlock()
+	meterProvider = mp
+	if defaultTracker == nil {
+		return nil
+	}
+	return registerLocked(defaultTracker)
+}
+
+// registerLocked registers the gauges of tracker, replacing those registered
+// before. mu must be held.
+func registerLocked(tracker *Tracker) error {
+	mp := meterProvider
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	reg, err := tracker.registerMetrics(mp.Meter("dm-nkp-gitops-custom-app/slo"))
+	if err != nil {
+		return err
+	}
+	if registration != nil {
+		_ = registration.Unregister()
+	}
+	registration = reg
+	return nil
+}
+
+// Record accounts a request outcome against the configured SLOs.
+// It is a no-op before Initialize.
+func Record(route string, status int, duration time.Duration) {
+	mu.RLock()
+	tracker := defaultTracker
+	mu.RUnlock()
+
+	if tracker != nil {
+		tracker.Record(route, status, duration)
+	}
+}
+
+// Statuses returns the current compliance of every configured SLO
+func Statuses() []Status {
+	mu.RLock()
+	tracker := defaultTracker
+	mu.RUnlock()
+
+	if tracker == nil {
+		return []Status{}
+	}
+	return tracker.Statuses()
+}
+
+// registerMetrics exports error budget and burn rate gauges for every SLO
+func (t *Tracker) registerMetrics(meter metric.Meter) (metric.Registration, error) {
+	budget, err := catalogGauge(meter, ErrorBudgetRemainingName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SLO error budget gauge: %w", err)
+	}
+
+	burnRate, err := catalogGauge(meter, BurnRateName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SLO burn rate gauge: %w", err)
+	}
+
+	target, err := catalogGauge(meter, TargetName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SLO target gauge: %w", err)
+	}
+
+	reg, err := meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		for _, status := range t.Statuses() {
+			sloAttr := attribute.String("slo", status.Name)
+			o.ObserveFloat64(budget, status.ErrorBudgetRemaining, metric.WithAttributes(sloAttr))
+			o.ObserveFloat64(target, status.Target, metric.WithAttributes(sloAttr))
+			for window, rate := range status.BurnRates {
+				o.ObserveFloat64(burnRate, rate, metric.WithAttributes(sloAttr, attribute.String("window", window)))
+			}
+		}
+		return nil
+	}, budget, burnRate, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register SLO callback: %w", err)
+	}
+	return reg, nil
+}
+
+// catalogGauge registers a gauge with the unit and description from the metrics catalog
+func catalogGauge(meter metric.Meter, name string) (metric.Float64ObservableGauge, error) {
+	inst, err := metrics.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return meter.Float64ObservableGauge(
+		inst.Name,
+		metric.WithDescription(inst.Description),
+		metric.WithUnit(inst.Unit),
+	)
+}
@@ -0,0 +1,212 @@
+package slo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestSLO(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SLO Suite")
+}
+
+const testConfig = `
+slos:
+  - name: api-availability
+    target: 0.99
+    window: 7d
+    routes: ["/"]
+    bad_status: ["5xx", "429"]
+  - name: api-latency
+    target: 0.9
+    window: 1d
+    latency_threshold: 100ms
+`
+
+var _ = Describe("Config", func() {
+	It("should parse definitions with day windows", func() {
+		defs, err := ParseConfig([]byte(testConfig))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defs).To(HaveLen(2))
+		Expect(time.Duration(defs[0].Window)).To(Equal(7 * 24 * time.Hour))
+		Expect(defs[0].BadStatus).To(ConsistOf("5xx", "429"))
+		Expect(time.Duration(defs[1].LatencyThreshold)).To(Equal(100 * time.Millisecond))
+	})
+
+	It("should load definitions from a file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "slo.yaml")
+		Expect(os.WriteFile(path, []byte(testConfig), 0o600)).To(Succeed())
+
+		defs, err := LoadConfig(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defs).To(HaveLen(2))
+	})
+
+	It("should reject invalid definitions", func() {
+		_, err := ParseConfig([]byte("slos:\n  - name: x\n    target: 1.5\n    window: 1d\n    bad_status: [5xx]\n"))
+		Expect(err).To(MatchError(ContainSubstring("target")))
+
+		_, err = ParseConfig([]byte("slos:\n  - name: x\n    target: 0.9\n    window: 1d\n"))
+		Expect(err).To(MatchError(ContainSubstring("bad_status")))
+
+		_, err = ParseConfig([]byte("slos:\n  - name: x\n    target: 0.9\n    window: 1d\n    bad_status: [6xx]\n"))
+		Expect(err).To(MatchError(ContainSubstring("invalid status pattern")))
+
+		_, err = ParseConfig([]byte("slos:\n  - name: x\n    target: 0.9\n    window: forever\n    bad_status: [5xx]\n"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should ship valid defaults", func() {
+		Expect(Validate(DefaultDefinitions())).To(Succeed())
+	})
+})
+
+var _ = Describe("Tracker", func() {
+	var (
+		tracker *Tracker
+		now     time.Time
+	)
+
+	BeforeEach(func() {
+		defs, err := ParseConfig([]byte(testConfig))
+		Expect(err).NotTo(HaveOccurred())
+		tracker = NewTracker(defs)
+		now = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+		tracker.now = func() time.Time { return now }
+	})
+
+	It("should report full compliance without events", func() {
+		report := tracker.Statuses()
+		Expect(report).To(HaveLen(2))
+		Expect(report[0].Compliance).To(Equal(1.0))
+		Expect(report[0].ErrorBudgetRemaining).To(Equal(1.0))
+		Expect(report[0].Met).To(BeTrue())
+	})
+
+	It("should classify events by status and route", func() {
+		for i := 0; i < 98; i++ {
+			tracker.Record("/", 200, time.Millisecond)
+		}
+		tracker.Record("/", 503, time.Millisecond)
+		tracker.Record("/", 429, time.Millisecond)
+		tracker.Record("/health", 500, time.Millisecond) // not covered by api-availability
+
+		availability := tracker.Statuses()[0]
+		Expect(availability.Name).To(Equal("api-availability"))
+		Expect(availability.TotalEvents).To(BeEquivalentTo(100))
+		Expect(availability.GoodEvents).To(BeEquivalentTo(98))
+		Expect(availability.Compliance).To(BeNumerically("~", 0.98, 1e-9))
+		// 2% errors against a 1% budget: budget overspent by 100%
+		Expect(availability.ErrorBudgetRemaining).To(BeNumerically("~", -1.0, 1e-9))
+		Expect(availability.BurnRates["5m"]).To(BeNumerically("~", 2.0, 1e-9))
+		Expect(availability.Met).To(BeFalse())
+	})
+
+	It("should count slow requests as bad for latency SLOs", func() {
+		tracker.Record("/anything", 200, 50*time.Millisecond)
+		tracker.Record("/anything", 200, 150*time.Millisecond)
+
+		latency := tracker.Statuses()[1]
+		Expect(latency.Name).To(Equal("api-latency"))
+		Expect(latency.TotalEvents).To(BeEquivalentTo(2))
+		Expect(latency.GoodEvents).To(BeEquivalentTo(1))
+		// Windows longer than the 1d SLO window are not reported
+		Expect(latency.BurnRates).NotTo(HaveKey("3d"))
+		Expect(latency.BurnRates).To(HaveKey("1d"))
+	})
+
+	It("should age events out of burn-rate windows and the SLO window", func() {
+		tracker.Record("/", 500, time.Millisecond)
+
+		now = now.Add(10 * time.Minute)
+		tracker.Record("/", 200, time.Millisecond)
+
+		availability := tracker.Statuses()[0]
+		Expect(availability.BurnRates["5m"]).To(Equal(0.0))
+		Expect(availability.BurnRates["30m"]).To(BeNumerically("~", 50.0, 1e-9))
+		Expect(availability.TotalEvents).To(BeEquivalentTo(2))
+
+		now = now.Add(8 * 24 * time.Hour)
+		availability = tracker.Statuses()[0]
+		Expect(availability.TotalEvents).To(BeZero())
+	})
+})
+
+var _ = Describe("Package tracker", func() {
+	AfterEach(func() {
+		os.Unsetenv("SLO_CONFIG_FILE")
+	})
+
+	It("should ignore records before Initialize", func() {
+		mu.Lock()
+		defaultTracker = nil
+		mu.Unlock()
+
+		Expect(func() { Record("/", 500, time.Millisecond) }).NotTo(Panic())
+		Expect(Statuses()).To(BeEmpty())
+	})
+
+	It("should initialize with default definitions", func() {
+		Expect(Initialize()).To(Succeed())
+		Record("/", 200, time.Millisecond)
+
+		report := Statuses()
+		Expect(report).To(HaveLen(2))
+		Expect(report[0].TotalEvents).To(BeEquivalentTo(1))
+	})
+
+	It("should fail on an unreadable config file", func() {
+		os.Setenv("SLO_CONFIG_FILE", "/nonexistent/slo.yaml")
+		Expect(Initialize()).To(MatchError(ContainSubstring("failed to read SLO config")))
+	})
+
+	It("should export error budget and burn rate gauges", func() {
+		reader := sdkmetric.NewManualReader()
+		provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+		tracker := NewTracker(DefaultDefinitions())
+		_, err := tracker.registerMetrics(provider.Meter("test"))
+		Expect(err).NotTo(HaveOccurred())
+		tracker.Record("/", 500, time.Millisecond)
+
+		var rm metricdata.ResourceMetrics
+		Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+
+		names := map[string]int{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				names[m.Name] = len(m.Data.(metricdata.Gauge[float64]).DataPoints)
+			}
+		}
+		Expect(names).To(HaveKeyWithValue("slo_error_budget_remaining", 2))
+		Expect(names).To(HaveKeyWithValue("slo_target", 2))
+		Expect(names).To(HaveKeyWithValue("slo_burn_rate", 2*len(BurnRateWindows)))
+	})
+
+	It("should move the gauges to the meter provider in use", func() {
+		reader := sdkmetric.NewManualReader()
+		provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+		DeferCleanup(func() error { return UseMeterProvider(nil) })
+		Expect(Initialize()).To(Succeed())
+		Expect(UseMeterProvider(provider)).To(Succeed())
+		// Later initializations keep it
+		Expect(Initialize()).To(Succeed())
+		Record("/", 500, time.Millisecond)
+
+		var rm metricdata.ResourceMetrics
+		Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+		Expect(rm.ScopeMetrics).To(HaveLen(1))
+		var names []string
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			names = append(names, m.Name)
+		}
+		Expect(names).To(ConsistOf("slo_error_budget_remaining", "slo_target", "slo_burn_rate"))
+	})
+})
@@ -0,0 +1,221 @@
+package slo
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// BurnRateWindows are the windows burn rates are computed over.
+// They cover the short/long pairs of the multi-window, multi-burn-rate alerting
+// approach from the Google SRE workbook (5m/1h, 30m/6h, 2h/1d, 6h/3d).
+var BurnRateWindows = []time.Duration{
+	5 * time.Minute,
+	30 * time.Minute,
+	1 * time.Hour,
+	2 * time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	72 * time.Hour,
+}
+
+const (
+	// fineBucketWidth is the resolution used for burn-rate windows
+	fineBucketWidth = time.Minute
+	// coarseBucketWidth is the resolution used for the full SLO window
+	coarseBucketWidth = time.Hour
+)
+
+// Status is the current state of one SLO, as reported by /slo
+type Status struct {
+	Definition
+	TotalEvents uint64 `json:"total_events"`
+	GoodEvents  uint64 `json:"good_events"`
+	// Compliance is the fraction of good events over the window (1 when there were no events)
+	Compliance float64 `json:"compliance"`
+	// ErrorBudgetRemaining is the fraction of the error budget left (negative when overspent)
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+	// BurnRates maps a window (e.g. "1h") to how fast the error budget is being consumed
+	BurnRates map[string]float64 `json:"burn_rates"`
+	// Met reports whether compliance is currently at or above the target
+	Met bool `json:"met"`
+}
+
+// Tracker evaluates SLOs in process from request outcomes
+type Tracker struct {
+	mu         sync.Mutex
+	objectives []*objective
+	now        func() time.Time
+}
+
+type objective struct {
+	def       Definition
+	routes    map[string]bool
+	badStatus []statusPattern
+	fine      *bucketRing
+	coarse    *bucketRing
+}
+
+// NewTracker creates a tracker for already validated definitions
+func NewTracker(defs []Definition) *Tracker {
+	t := &Tracker{now: time.Now}
+	for _, def := range defs {
+		o := &objective{
+			def:    def,
+			fine:   newBucketRing(fineBucketWidth, longestBurnWindow(def)),
+			coarse: newBucketRing(coarseBucketWidth, time.Duration(def.Window)),
+		}
+		if len(def.Routes) > 0 {
+			o.routes = make(map[string]bool, len(def.Routes))
+			for _, route := range def.Routes {
+				o.routes[route] = true
+			}
+		}
+		for _, pattern := range def.BadStatus {
+			if p, err := parseStatusPattern(pattern); err == nil {
+				o.badStatus = append(o.badStatus, p)
+			}
+		}
+		t.objectives = append(t.objectives, o)
+	}
+	return t
+}
+
+// Record accounts one request outcome against every SLO that covers its route
+func (t *Tracker) Record(route string, status int, duration time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for _, o := range t.objectives {
+		if o.routes != nil && !o.routes[route] {
+			continue
+		}
+		bad := o.isBad(status, duration)
+		o.fine.add(now, bad)
+		o.coarse.add(now, bad)
+	}
+}
+
+// Statuses returns the current status of every SLO, sorted by name
+func (t *Tracker) Statuses() []Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	report := make([]Status, 0, len(t.objectives))
+	for _, o := range t.objectives {
+		total, bad := o.coarse.sum(now, time.Duration(o.def.Window))
+		status := Status{
+			Definition:           o.def,
+			TotalEvents:          total,
+			GoodEvents:           total - bad,
+			Compliance:           1,
+			ErrorBudgetRemaining: 1,
+			BurnRates:            make(map[string]float64),
+		}
+		if total > 0 {
+			errorRatio := float64(bad) / float64(total)
+			status.Compliance = 1 - errorRatio
+			status.ErrorBudgetRemaining = 1 - errorRatio/o.errorBudget()
+		}
+		status.Met = status.Compliance >= o.def.Target
+
+		for _, window := range burnWindows(o.def) {
+			status.BurnRates[Duration(window).String()] = o.burnRate(now, window)
+		}
+		report = append(report, status)
+	}
+
+	sort.Slice(report, func(i, j int) bool { return report[i].Name < report[j].Name })
+	return report
+}
+
+func (o *objective) isBad(status int, duration time.Duration) bool {
+	for _, p := range o.badStatus {
+		if p.matches(status) {
+			return true
+		}
+	}
+	return o.def.LatencyThreshold > 0 && duration > time.Duration(o.def.LatencyThreshold)
+}
+
+// errorBudget is the allowed fraction of bad events
+func (o *objective) errorBudget() float64 {
+	return 1 - o.def.Target
+}
+
+// burnRate is the observed error ratio over window divided by the error budget.
+// A burn rate of 1 spends exactly the whole budget over the SLO window.
+func (o *objective) burnRate(now time.Time, window time.Duration) float64 {
+	total, bad := o.fine.sum(now, window)
+	if total == 0 {
+		return 0
+	}
+	return (float64(bad) / float64(total)) / o.errorBudget()
+}
+
+// burnWindows returns the burn-rate windows that fit in the SLO window
+func burnWindows(def Definition) []time.Duration {
+	var windows []time.Duration
+	for _, w := range BurnRateWindows {
+		if w <= time.Duration(def.Window) {
+			windows = append(windows, w)
+		}
+	}
+	return windows
+}
+
+func longestBurnWindow(def Definition) time.Duration {
+	windows := burnWindows(def)
+	if len(windows) == 0 {
+		return fineBucketWidth
+	}
+	return windows[len(windows)-1]
+}
+
+// bucketRing counts total and bad events in fixed-width time buckets
+type bucketRing struct {
+	width   time.Duration
+	buckets []bucket
+}
+
+type bucket struct {
+	index int64
+	total uint64
+	bad   uint64
+}
+
+func newBucketRing(width, span time.Duration) *bucketRing {
+	n := int((span + width - 1) / width)
+	return &bucketRing{width: width, buckets: make([]bucket, n)}
+}
+
+func (r *bucketRing) add(t time.Time, bad bool) {
+	index := t.UnixNano() / int64(r.width)
+	b := &r.buckets[index%int64(len(r.buckets))]
+	if b.index != index {
+		*b = bucket{index: index}
+	}
+	b.total++
+	if bad {
+		b.bad++
+	}
+}
+
+// sum adds up the buckets covering the given span ending at now
+func (r *bucketRing) sum(now time.Time, span time.Duration) (total, bad uint64) {
+	current := now.UnixNano() / int64(r.width)
+	n := int64((span + r.width - 1) / r.width)
+	if n > int64(len(r.buckets)) {
+		n = int64(len(r.buckets))
+	}
+	for index := current - n + 1; index <= current; index++ {
+		b := r.buckets[index%int64(len(r.buckets))]
+		if b.index == index {
+			total += b.total
+			bad += b.bad
+		}
+	}
+	return total, bad
+}
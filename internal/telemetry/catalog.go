@@ -0,0 +1,96 @@
+package telemetry
+
+// Attribute keys the Log* functions set on every OTLP log record
+const (
+	LogLevelKey   = "log.level"
+	LogMessageKey = "log.message"
+	ErrorKey      = "error"
+)
+
+// LogScopeKey names the logger scope (server, metrics, telemetry, stdlib, access, audit) of records
+// logged through ScopedLogger
+const LogScopeKey = "log.scope"
+
+// Attribute keys of the request and health check logs. HTTP keys follow the
+// OTel semantic conventions.
+const (
+	HTTPMethodKey       = "http.request.method"
+	URLPathKey          = "url.path"
+	ClientAddressKey    = "client.address"
+	HTTPStatusCodeKey   = "http.response.status_code"
+	ResponseBodySizeKey = "http.response.body.size"
+	DurationMsKey       = "duration_ms"
+	CheckTypeKey        = "check.type"
+	CheckComponentKey   = "check.component"
+	CheckStatusKey      = "check.status"
+)
+
+// Attribute keys of the access log records (see ScopeAccess)
+const (
+	HTTPRouteKey   = "http.route"
+	URLQueryKey    = "url.query"
+	UserAgentKey   = "user_agent.original"
+	HTTPRefererKey = "http.request.header.referer"
+	TraceIDKey     = "trace_id"
+)
+
+// Attribute keys of the TLS certificate reload records, following the OTel
+// semantic conventions
+const (
+	TLSServerSubjectKey  = "tls.server.subject"
+	TLSServerNotAfterKey = "tls.server.not_after"
+)
+
+// Attribute keys of the gRPC request records, following the OTel semantic
+// conventions
+const (
+	RPCServiceKey        = "rpc.service"
+	RPCMethodKey         = "rpc.method"
+	RPCGRPCStatusCodeKey = "rpc.grpc.status_code"
+)
+
+// Attribute keys of the audit log records (see ScopeAudit). The records also carry
+// the URL, client and status keys of the access log.
+const (
+	// DebugEndpointKey is the debug endpoint used: cpu, heap, goroutine, trace, ...
+	DebugEndpointKey = "debug.endpoint"
+	// DebugAuthKey is how the caller was let in: token or admin_listener
+	DebugAuthKey = "debug.auth"
+)
+
+// RateLimitScopeKey is the bucket that rejected a rate limited request: global,
+// ip or key. It is also an attribute of the rate_limited span event.
+const RateLimitScopeKey = "ratelimit.scope"
+
+// LoadShedPriorityKey is the priority class of a request shed by the concurrency
+// limiter: low or normal. It is also an attribute of the load_shed span event.
+const LoadShedPriorityKey = "loadshed.priority"
+
+// LogAttributeKeys lists the attribute keys the app sets on OTLP log records.
+// Loki stores them as structured metadata (with dots replaced by underscores);
+// the dashboard linter uses this list to catch queries on attributes the app no longer emits.
+func LogAttributeKeys() []string {
+	return []string{
+		LogLevelKey, LogMessageKey, ErrorKey, LogScopeKey, LogRecordUIDKey,
+		ExceptionTypeKey, ExceptionMessageKey, ExceptionStacktraceKey,
+		SamplingSuppressedKey, SamplingMessagesKey,
+		HTTPMethodKey, URLPathKey, ClientAddressKey, HTTPStatusCodeKey, ResponseBodySizeKey, DurationMsKey,
+		CheckTypeKey, CheckComponentKey, CheckStatusKey,
+		HTTPRouteKey, URLQueryKey, UserAgentKey, HTTPRefererKey, TraceIDKey,
+		TLSServerSubjectKey, TLSServerNotAfterKey,
+		RPCServiceKey, RPCMethodKey, RPCGRPCStatusCodeKey,
+		DebugEndpointKey, DebugAuthKey,
+		RateLimitScopeKey, LoadShedPriorityKey,
+	}
+}
+
+// LogResourceKeys lists the resource attributes attached to every log record.
+// Loki indexes them as stream labels (e.g. service.name becomes service_name).
+func LogResourceKeys() []string {
+	return []string{"service.name", "service.version"}
+}
+
+// LogLevels lists the values of the log.level attribute
+func LogLevels() []string {
+	return []string{"debug", "info", "warn", "error"}
+}
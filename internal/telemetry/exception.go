@@ -0,0 +1,175 @@
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys of recorded errors, following the OTel exception semantic
+// conventions. They are set on log records and on the span's "exception" event.
+const (
+	ExceptionTypeKey       = "exception.type"
+	ExceptionMessageKey    = "exception.message"
+	ExceptionStacktraceKey = "exception.stacktrace"
+)
+
+// exceptionEventName is the span event name of the exception conventions
+const exceptionEventName = "exception"
+
+// maxStackFrames bounds the frames captured for exception.stacktrace
+const maxStackFrames = 32
+
+// exception is an error with the stack trace where it was recorded
+type exception struct {
+	typ        string
+	message    string
+	stacktrace string
+}
+
+// newException describes err. skip is the number of frames above the caller of
+// newException to leave out of the stack trace.
+func newException(err error, skip int) exception {
+	return exception{
+		typ:        errorType(rootCause(err)),
+		message:    err.Error(),
+		stacktrace: stacktrace(skip+1) + causeChain(err),
+	}
+}
+
+func (e exception) attrs() []slog.Attr {
+	return []slog.Attr{
+		slog.String(ExceptionTypeKey, e.typ),
+		slog.String(ExceptionMessageKey, e.message),
+		slog.String(ExceptionStacktraceKey, e.stacktrace),
+	}
+}
+
+// record adds the exception event to the span and sets its status to Error
+func (e exception) record(span trace.Span) {
+	if !span.IsRecording() {
+		return
+	}
+	span.AddEvent(exceptionEventName, trace.WithAttributes(
+		attribute.String(ExceptionTypeKey, e.typ),
+		attribute.String(ExceptionMessageKey, e.message),
+		attribute.String(ExceptionStacktraceKey, e.stacktrace),
+	))
+	span.SetStatus(codes.Error, e.message)
+}
+
+// ExceptionAttrs returns the exception.* attributes of err, with the stack
+// trace of the caller, for logging errors through slog directly
+func ExceptionAttrs(err error) []slog.Attr {
+	if err == nil {
+		return nil
+	}
+	return newException(err, 1).attrs()
+}
+
+// RecordError records err as an exception event on the active span of ctx and
+// sets the span status to Error. Use LogError to log the error as well.
+func RecordError(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	newException(err, 1).record(trace.SpanFromContext(ctx))
+}
+
+// rootCause follows Unwrap to the innermost error. For errors joining several
+// (errors.Join, fmt.Errorf with several %w), the first one is followed.
+func rootCause(err error) error {
+	for {
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			next := u.Unwrap()
+			if next == nil {
+				return err
+			}
+			err = next
+		case interface{ Unwrap() []error }:
+			errs := u.Unwrap()
+			if len(errs) == 0 || errs[0] == nil {
+				return err
+			}
+			err = errs[0]
+		default:
+			return err
+		}
+	}
+}
+
+// errorType is the fully qualified type name, e.g. "*io/fs.PathError"
+func errorType(err error) string {
+	t := reflect.TypeOf(err)
+	prefix := ""
+	for t.Kind() == reflect.Pointer {
+		prefix += "*"
+		t = t.Elem()
+	}
+	if t.PkgPath() == "" || t.Name() == "" {
+		return prefix + t.String()
+	}
+	return prefix + t.PkgPath() + "." + t.Name()
+}
+
+// causeChain lists the wrapped errors below err, one "caused by" line each,
+// depth first for errors joining several
+func causeChain(err error) string {
+	var b strings.Builder
+	var walk func(err error, depth int)
+	walk = func(err error, depth int) {
+		var next []error
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			next = []error{u.Unwrap()}
+		case interface{ Unwrap() []error }:
+			next = u.Unwrap()
+		}
+		for _, cause := range next {
+			if cause == nil {
+				continue
+			}
+			b.WriteString("\n")
+			b.WriteString(strings.Repeat("  ", depth))
+			b.WriteString("caused by: ")
+			b.WriteString(errorType(cause))
+			b.WriteString(": ")
+			b.WriteString(cause.Error())
+			walk(cause, depth+1)
+		}
+	}
+	walk(err, 0)
+	return b.String()
+}
+
+// stacktrace formats the caller's stack like runtime/debug.Stack, skipping
+// skip frames above stacktrace's caller
+func stacktrace(skip int) string {
+	pcs := make([]uintptr, maxStackFrames)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(frame.Function)
+		b.WriteString("()\n\t")
+		b.WriteString(frame.File)
+		b.WriteString(":")
+		b.WriteString(strconv.Itoa(frame.Line))
+		if !more {
+			return b.String()
+		}
+	}
+}
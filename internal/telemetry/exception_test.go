@@ -0,0 +1,112 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var _ = Describe("Exceptions", func() {
+	var (
+		processor *recordingProcessor
+		exporter  *tracetest.InMemoryExporter
+		ctx       context.Context
+	)
+
+	// startSpan starts a span recorded by exporter and returns its context
+	startSpan := func() (context.Context, func()) {
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		DeferCleanup(provider.Shutdown, context.Background())
+		spanCtx, s := provider.Tracer("test").Start(context.Background(), "op")
+		return spanCtx, func() { s.End() }
+	}
+
+	eventAttrs := func(event sdktrace.Event) map[attribute.Key]string {
+		attrs := map[attribute.Key]string{}
+		for _, kv := range event.Attributes {
+			attrs[kv.Key] = kv.Value.AsString()
+		}
+		return attrs
+	}
+
+	BeforeEach(func() {
+		processor, _ = useRecordingLogger()
+		exporter = tracetest.NewInMemoryExporter()
+		ctx = context.Background()
+	})
+
+	It("should describe the root cause of wrapped errors", func() {
+		_, err := os.Open("/does/not/exist")
+		wrapped := fmt.Errorf("load config: %w", err)
+
+		LogError(ctx, "startup failed", wrapped)
+
+		attrs := recordAttrs(processor.Records()[0])
+		Expect(attrs[ExceptionTypeKey].AsString()).To(Equal("syscall.Errno"))
+		Expect(attrs[ExceptionMessageKey].AsString()).To(Equal("load config: open /does/not/exist: no such file or directory"))
+
+		stack := attrs[ExceptionStacktraceKey].AsString()
+		// The stack starts at LogError's caller
+		Expect(stack).To(HavePrefix("github.com/deepak-muley/dm-nkp-gitops-custom-app/internal/telemetry.init."))
+		Expect(stack).To(ContainSubstring("exception_test.go:"))
+		Expect(stack).To(HaveSuffix("\ncaused by: *io/fs.PathError: open /does/not/exist: no such file or directory" +
+			"\n  caused by: syscall.Errno: no such file or directory"))
+		Expect(errors.Is(wrapped, fs.ErrNotExist)).To(BeTrue())
+	})
+
+	It("should list every joined error in the chain", func() {
+		Expect(causeChain(errors.Join(errors.New("a"), fmt.Errorf("b: %w", errors.New("c"))))).To(Equal(
+			"\ncaused by: *errors.errorString: a" +
+				"\ncaused by: *fmt.wrapError: b: c" +
+				"\n  caused by: *errors.errorString: c"))
+	})
+
+	It("should record the exception on the span and set its status", func() {
+		spanCtx, end := startSpan()
+		LogError(spanCtx, "request failed", errors.New("backend unavailable"))
+		end()
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Status.Code).To(Equal(codes.Error))
+		Expect(spans[0].Status.Description).To(Equal("backend unavailable"))
+		Expect(spans[0].Events).To(HaveLen(1))
+		Expect(spans[0].Events[0].Name).To(Equal("exception"))
+		attrs := eventAttrs(spans[0].Events[0])
+		Expect(attrs[ExceptionTypeKey]).To(Equal("*errors.errorString"))
+		Expect(attrs[ExceptionMessageKey]).To(Equal("backend unavailable"))
+
+		// The log record carries the same exception and the span's trace context
+		record := processor.Records()[0]
+		Expect(recordAttrs(record)[ExceptionStacktraceKey].AsString()).To(Equal(attrs[ExceptionStacktraceKey]))
+		Expect(record.TraceID()).To(Equal(spans[0].SpanContext.TraceID()))
+	})
+
+	It("should record errors on the span without logging", func() {
+		spanCtx, end := startSpan()
+		RecordError(spanCtx, errors.New("cache miss storm"))
+		RecordError(spanCtx, nil)
+		end()
+
+		Expect(processor.Records()).To(BeEmpty())
+		spans := exporter.GetSpans()
+		Expect(spans[0].Status.Code).To(Equal(codes.Error))
+		Expect(spans[0].Events).To(HaveLen(1))
+		Expect(eventAttrs(spans[0].Events[0])[ExceptionStacktraceKey]).To(ContainSubstring("exception_test.go:"))
+	})
+
+	It("should log without a span", func() {
+		LogError(ctx, "no span", errors.New("boom"))
+		Expect(processor.Records()).To(HaveLen(1))
+		Expect(ExceptionAttrs(nil)).To(BeNil())
+	})
+})
@@ -0,0 +1,245 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Stdout/stderr formats accepted by LOG_FORMAT
+const (
+	LogFormatText   = "text"
+	LogFormatJSON   = "json"
+	LogFormatLogfmt = "logfmt"
+)
+
+// Field names of the JSON and logfmt formats. They are the OTel log data model
+// fields, spelled as the spec recommends for non-OTLP formats (trace_id, span_id).
+const (
+	fieldTimestamp      = "timestamp"
+	fieldSeverityText   = "severity_text"
+	fieldSeverityNumber = "severity_number"
+	fieldBody           = "body"
+	fieldTraceID        = "trace_id"
+	fieldSpanID         = "span_id"
+	fieldTraceFlags     = "trace_flags"
+	fieldResource       = "resource"
+	fieldAttributes     = "attributes"
+)
+
+var (
+	logFormat atomic.Value // string
+	// logRes holds the resource written by the JSON and logfmt formats
+	logRes atomic.Pointer[resource.Resource]
+	// stdoutMu serializes structured lines, which bypass the log.Logger's own lock
+	stdoutMu sync.Mutex
+)
+
+// ParseLogFormat parses text, json or logfmt, case-insensitively
+func ParseLogFormat(s string) (string, error) {
+	switch format := strings.ToLower(strings.TrimSpace(s)); format {
+	case LogFormatText, LogFormatJSON, LogFormatLogfmt:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid log format %q (want %s, %s or %s)", s, LogFormatText, LogFormatJSON, LogFormatLogfmt)
+	}
+}
+
+// SetLogFormat selects the stdout/stderr format and the resource its JSON and
+// logfmt lines carry (nil for none)
+func SetLogFormat(format string, res *resource.Resource) error {
+	format, err := ParseLogFormat(format)
+	if err != nil {
+		return err
+	}
+	logFormat.Store(format)
+	logRes.Store(res)
+	return nil
+}
+
+func currentLogFormat() string {
+	if format, ok := logFormat.Load().(string); ok {
+		return format
+	}
+	return LogFormatText
+}
+
+func logResource() *resource.Resource {
+	return logRes.Load()
+}
+
+// encodeJSON formats a record as one JSON object:
+//
+//	{"timestamp": "...", "severity_text": "INFO", "severity_number": 9, "body": "...",
+//	 "trace_id": "...", "span_id": "...", "trace_flags": "01",
+//	 "resource": {"service.name": "..."}, "attributes": {"http.request.method": "GET"}}
+func encodeJSON(ctx context.Context, r slog.Record, attrs []slog.Attr, res *resource.Resource) []byte {
+	b := []byte{'{'}
+	b = appendJSONField(b, fieldTimestamp, r.Time.UTC().Format(time.RFC3339Nano))
+	b = append(b, ',')
+	b = appendJSONField(b, fieldSeverityText, r.Level.String())
+	b = append(b, ',')
+	b = appendJSONString(b, fieldSeverityNumber)
+	b = append(b, ':')
+	b = strconv.AppendInt(b, int64(severity(r.Level)), 10)
+	b = append(b, ',')
+	b = appendJSONField(b, fieldBody, r.Message)
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		b = append(b, ',')
+		b = appendJSONField(b, fieldTraceID, sc.TraceID().String())
+		b = append(b, ',')
+		b = appendJSONField(b, fieldSpanID, sc.SpanID().String())
+		b = append(b, ',')
+		b = appendJSONField(b, fieldTraceFlags, sc.TraceFlags().String())
+	}
+
+	if res != nil && res.Len() > 0 {
+		b = append(b, ',')
+		b = appendJSONString(b, fieldResource)
+		b = append(b, ':', '{')
+		for i, kv := range res.Attributes() {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendJSONString(b, string(kv.Key))
+			b = append(b, ':')
+			b = appendJSONAny(b, kv.Value.AsInterface())
+		}
+		b = append(b, '}')
+	}
+
+	if len(attrs) > 0 {
+		b = append(b, ',')
+		b = appendJSONString(b, fieldAttributes)
+		b = append(b, ':')
+		b = appendJSONAttrs(b, attrs)
+	}
+	return append(b, '}')
+}
+
+func appendJSONField(b []byte, key, value string) []byte {
+	b = appendJSONString(b, key)
+	b = append(b, ':')
+	return appendJSONString(b, value)
+}
+
+// appendJSONAttrs writes attributes as an object; groups become nested objects
+// and groups with an empty key are inlined
+func appendJSONAttrs(b []byte, attrs []slog.Attr) []byte {
+	b = append(b, '{')
+	b = appendJSONMembers(b, attrs, true)
+	return append(b, '}')
+}
+
+func appendJSONMembers(b []byte, attrs []slog.Attr, first bool) []byte {
+	for _, a := range attrs {
+		if a.Key == "" && a.Value.Kind() == slog.KindGroup {
+			before := len(b)
+			b = appendJSONMembers(b, a.Value.Group(), first)
+			first = first && len(b) == before
+			continue
+		}
+		if !first {
+			b = append(b, ',')
+		}
+		first = false
+		b = appendJSONString(b, a.Key)
+		b = append(b, ':')
+		b = appendJSONValue(b, a.Value)
+	}
+	return b
+}
+
+func appendJSONValue(b []byte, v slog.Value) []byte {
+	switch v.Kind() {
+	case slog.KindString:
+		return appendJSONString(b, v.String())
+	case slog.KindInt64:
+		return strconv.AppendInt(b, v.Int64(), 10)
+	case slog.KindUint64:
+		return strconv.AppendUint(b, v.Uint64(), 10)
+	case slog.KindFloat64:
+		f := v.Float64()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return appendJSONString(b, strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		return strconv.AppendFloat(b, f, 'g', -1, 64)
+	case slog.KindBool:
+		return strconv.AppendBool(b, v.Bool())
+	case slog.KindDuration:
+		return appendJSONString(b, v.Duration().String())
+	case slog.KindTime:
+		return appendJSONString(b, v.Time().Format(time.RFC3339Nano))
+	case slog.KindGroup:
+		return appendJSONAttrs(b, v.Group())
+	default:
+		return appendJSONAny(b, v.Any())
+	}
+}
+
+// appendJSONAny writes errors and Stringers as strings, like the OTel handler,
+// and everything else as encoding/json does
+func appendJSONAny(b []byte, v any) []byte {
+	switch a := v.(type) {
+	case error:
+		return appendJSONString(b, a.Error())
+	case fmt.Stringer:
+		return appendJSONString(b, a.String())
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return appendJSONString(b, fmt.Sprintf("%+v", v))
+	}
+	return append(b, encoded...)
+}
+
+func appendJSONString(b []byte, s string) []byte {
+	// Marshaling a string can't fail
+	encoded, _ := json.Marshal(s)
+	return append(b, encoded...)
+}
+
+// encodeLogfmt formats a record as logfmt. Attributes are top-level keys (groups
+// become dotted keys) and resource attributes are prefixed with "resource.".
+func encodeLogfmt(ctx context.Context, r slog.Record, attrs []slog.Attr, res *resource.Resource) []byte {
+	var b strings.Builder
+	writeLogfmtPair(&b, fieldTimestamp, r.Time.UTC().Format(time.RFC3339Nano))
+	writeLogfmtPair(&b, fieldSeverityText, r.Level.String())
+	writeLogfmtPair(&b, fieldSeverityNumber, strconv.Itoa(int(severity(r.Level))))
+	writeLogfmtPair(&b, fieldBody, r.Message)
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		writeLogfmtPair(&b, fieldTraceID, sc.TraceID().String())
+		writeLogfmtPair(&b, fieldSpanID, sc.SpanID().String())
+		writeLogfmtPair(&b, fieldTraceFlags, sc.TraceFlags().String())
+	}
+
+	if res != nil {
+		for _, kv := range res.Attributes() {
+			writeLogfmtPair(&b, fieldResource+"."+string(kv.Key), kv.Value.Emit())
+		}
+	}
+
+	writeTextAttrs(&b, "", attrs)
+	return []byte(b.String())
+}
+
+func writeLogfmtPair(b *strings.Builder, key, value string) {
+	if b.Len() > 0 {
+		b.WriteString(" ")
+	}
+	b.WriteString(key)
+	b.WriteString("=")
+	b.WriteString(textValue(slog.StringValue(value)))
+}
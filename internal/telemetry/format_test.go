@@ -0,0 +1,111 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ = Describe("Log formats", func() {
+	var (
+		out  *bytes.Buffer
+		ctx  context.Context
+		span trace.Span
+		res  *resource.Resource
+	)
+
+	useFormat := func(format string) {
+		Expect(SetLogFormat(format, res)).To(Succeed())
+		DeferCleanup(SetLogFormat, LogFormatText, (*resource.Resource)(nil))
+	}
+
+	BeforeEach(func() {
+		_, out = useRecordingLogger()
+		Expect(SetLogRouting(LogRoutingStdout)).To(Succeed())
+		DeferCleanup(SetLogRouting, LogRoutingBoth)
+		res = resource.NewSchemaless(semconv.ServiceName("test-app"), semconv.ServiceVersion("1.2.3"))
+
+		tp := sdktrace.NewTracerProvider()
+		DeferCleanup(tp.Shutdown, context.Background())
+		ctx, span = tp.Tracer("test").Start(context.Background(), "op")
+		DeferCleanup(func() { span.End() })
+	})
+
+	Describe("ParseLogFormat", func() {
+		It("should accept the known formats", func() {
+			for _, format := range []string{"text", "JSON", " logfmt "} {
+				_, err := ParseLogFormat(format)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			_, err := ParseLogFormat("xml")
+			Expect(err).To(MatchError(ContainSubstring("invalid log format")))
+		})
+	})
+
+	It("should write text lines by default", func() {
+		Logger().InfoContext(ctx, "hello", slog.Int("n", 1))
+
+		Expect(out.String()).To(ContainSubstring("[INFO] hello n=1"))
+		Expect(out.String()).NotTo(ContainSubstring("trace_id"))
+	})
+
+	It("should write JSON with the OTel log data model fields", func() {
+		useFormat(LogFormatJSON)
+
+		ScopedLogger(ScopeServer).WithGroup("http").WarnContext(ctx, "slow request",
+			slog.Int("status", 200), slog.Float64("duration_ms", 12.5), slog.Any("error", errors.New("boom")))
+
+		var line map[string]interface{}
+		Expect(json.Unmarshal(out.Bytes(), &line)).To(Succeed())
+		Expect(line).To(HaveKeyWithValue("severity_text", "WARN"))
+		Expect(line).To(HaveKeyWithValue("severity_number", BeNumerically("==", 13)))
+		Expect(line).To(HaveKeyWithValue("body", "slow request"))
+		Expect(line).To(HaveKeyWithValue("timestamp", MatchRegexp(`^\d{4}-\d\d-\d\dT.*Z$`)))
+		Expect(line).To(HaveKeyWithValue("trace_id", span.SpanContext().TraceID().String()))
+		Expect(line).To(HaveKeyWithValue("span_id", span.SpanContext().SpanID().String()))
+		Expect(line).To(HaveKeyWithValue("trace_flags", "01"))
+		Expect(line).To(HaveKeyWithValue("resource", Equal(map[string]interface{}{
+			"service.name": "test-app", "service.version": "1.2.3",
+		})))
+		Expect(line).To(HaveKeyWithValue("attributes", Equal(map[string]interface{}{
+			LogScopeKey: ScopeServer,
+			"http":      map[string]interface{}{"status": 200.0, "duration_ms": 12.5, "error": "boom"},
+		})))
+		Expect(strings.Count(out.String(), "\n")).To(Equal(1))
+	})
+
+	It("should omit trace context outside a span", func() {
+		useFormat(LogFormatJSON)
+
+		Logger().InfoContext(context.Background(), "no span")
+
+		var line map[string]interface{}
+		Expect(json.Unmarshal(out.Bytes(), &line)).To(Succeed())
+		Expect(line).NotTo(HaveKey("trace_id"))
+		Expect(line).NotTo(HaveKey("attributes"))
+	})
+
+	It("should write logfmt", func() {
+		useFormat(LogFormatLogfmt)
+
+		Logger().With(slog.Group("client", slog.String("address", "10.0.0.1"))).
+			ErrorContext(ctx, "request failed", slog.String("reason", "bad gateway"))
+
+		line := out.String()
+		Expect(line).To(MatchRegexp(`^timestamp=\S+Z severity_text=ERROR severity_number=17 body="request failed" `))
+		Expect(line).To(ContainSubstring(" trace_id=" + span.SpanContext().TraceID().String()))
+		Expect(line).To(ContainSubstring(" span_id=" + span.SpanContext().SpanID().String()))
+		Expect(line).To(ContainSubstring(" resource.service.name=test-app resource.service.version=1.2.3"))
+		Expect(line).To(HaveSuffix(` client.address=10.0.0.0 reason="bad gateway"` + "\n"))
+	})
+})
@@ -0,0 +1,308 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger scopes whose level can be set independently of the global level
+const (
+	ScopeServer    = "server"
+	ScopeMetrics   = "metrics"
+	ScopeTelemetry = "telemetry"
+	// ScopeStdlib is the scope of lines written with the log package (see EnableStdlibBridge)
+	ScopeStdlib = "stdlib"
+	// ScopeAccess is the scope of the HTTP access log, one record per request
+	ScopeAccess = "access"
+	// ScopeAudit is the scope of the audit log: profiling and debug sessions
+	ScopeAudit = "audit"
+)
+
+// Runtime level changes revert after DefaultLogLevelTTL unless the caller asks
+// for another TTL, which may not exceed MaxLogLevelTTL
+const (
+	DefaultLogLevelTTL = 15 * time.Minute
+	MaxLogLevelTTL     = 24 * time.Hour
+)
+
+// LogScopes lists the scopes accepted by ScopedLogger and SetLogLevels
+func LogScopes() []string {
+	return []string{ScopeServer, ScopeMetrics, ScopeTelemetry, ScopeStdlib, ScopeAccess, ScopeAudit}
+}
+
+// LogLevelConfig is the global level and the per-scope overrides. ExpiresAt is
+// set while a runtime change is active and says when it reverts.
+type LogLevelConfig struct {
+	Level     string            `json:"level"`
+	Scopes    map[string]string `json:"scopes,omitempty"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
+}
+
+// levelSet is a global level plus overrides keyed by scope
+type levelSet struct {
+	global slog.Level
+	scopes map[string]slog.Level
+}
+
+func (s levelSet) clone() levelSet {
+	scopes := make(map[string]slog.Level, len(s.scopes))
+	for scope, level := range s.scopes {
+		scopes[scope] = level
+	}
+	return levelSet{global: s.global, scopes: scopes}
+}
+
+// levelState holds the configured (LOG_LEVEL*) and the effective levels
+var levelState = struct {
+	sync.RWMutex
+	base      levelSet
+	current   levelSet
+	revert    *time.Timer
+	expiresAt time.Time
+	// generation identifies the latest change, so a timer that fires while
+	// being replaced can't revert the newer change
+	generation uint64
+}{
+	base:    levelSet{global: slog.LevelInfo},
+	current: levelSet{global: slog.LevelInfo},
+}
+
+// ParseLogLevel parses debug, info, warn (or warning) and error, case-insensitively
+func ParseLogLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("invalid log level %q (want one of %s)", s, strings.Join(LogLevels(), ", "))
+	}
+}
+
+// LoadLogLevels reads LOG_LEVEL and the per-scope LOG_LEVEL_<SCOPE> variables
+// (e.g. LOG_LEVEL_SERVER=debug) and makes them the levels that runtime changes revert to
+func LoadLogLevels() error {
+	set := levelSet{global: slog.LevelInfo, scopes: map[string]slog.Level{}}
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		level, err := ParseLogLevel(value)
+		if err != nil {
+			return fmt.Errorf("LOG_LEVEL: %w", err)
+		}
+		set.global = level
+	}
+	for _, scope := range LogScopes() {
+		key := "LOG_LEVEL_" + strings.ToUpper(scope)
+		if value := os.Getenv(key); value != "" {
+			level, err := ParseLogLevel(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			set.scopes[scope] = level
+		}
+	}
+
+	levelState.Lock()
+	defer levelState.Unlock()
+	stopRevertLocked()
+	levelState.base = set
+	levelState.current = set.clone()
+	return nil
+}
+
+// SetLogLevels changes levels at runtime until the TTL expires; a zero TTL means
+// DefaultLogLevelTTL. An empty level keeps the current global level, and an empty
+// scope level removes that scope's override. Every change restarts the TTL.
+func SetLogLevels(ctx context.Context, level string, scopes map[string]string, ttl time.Duration) (LogLevelConfig, error) {
+	if ttl == 0 {
+		ttl = DefaultLogLevelTTL
+	}
+	if ttl < 0 || ttl > MaxLogLevelTTL {
+		return LogLevelConfig{}, fmt.Errorf("ttl must be between 0 and %s, got %s", MaxLogLevelTTL, ttl)
+	}
+
+	levelState.Lock()
+	next := levelState.current.clone()
+	if level != "" {
+		parsed, err := ParseLogLevel(level)
+		if err != nil {
+			levelState.Unlock()
+			return LogLevelConfig{}, err
+		}
+		next.global = parsed
+	}
+	for scope, value := range scopes {
+		if !isLogScope(scope) {
+			levelState.Unlock()
+			return LogLevelConfig{}, fmt.Errorf("unknown log scope %q (want one of %s)", scope, strings.Join(LogScopes(), ", "))
+		}
+		if value == "" {
+			delete(next.scopes, scope)
+			continue
+		}
+		parsed, err := ParseLogLevel(value)
+		if err != nil {
+			levelState.Unlock()
+			return LogLevelConfig{}, fmt.Errorf("scope %s: %w", scope, err)
+		}
+		next.scopes[scope] = parsed
+	}
+
+	stopRevertLocked()
+	levelState.current = next
+	levelState.expiresAt = time.Now().Add(ttl)
+	generation := levelState.generation
+	levelState.revert = time.AfterFunc(ttl, func() { revertLogLevels(generation) })
+	config := currentLogLevelsLocked()
+	levelState.Unlock()
+
+	ScopedLogger(ScopeTelemetry).InfoContext(ctx, "Log levels changed",
+		slog.Any("levels", config), slog.Duration("ttl", ttl))
+	return config, nil
+}
+
+// ResetLogLevels reverts runtime changes to the configured levels immediately
+func ResetLogLevels(ctx context.Context) LogLevelConfig {
+	levelState.Lock()
+	stopRevertLocked()
+	levelState.current = levelState.base.clone()
+	config := currentLogLevelsLocked()
+	levelState.Unlock()
+
+	ScopedLogger(ScopeTelemetry).InfoContext(ctx, "Log levels reset", slog.Any("levels", config))
+	return config
+}
+
+// ToggleDebugLogging switches every scope to debug for DefaultLogLevelTTL, or
+// reverts to the configured levels when debug logging was already switched on.
+// SIGUSR2 calls it.
+func ToggleDebugLogging(ctx context.Context) LogLevelConfig {
+	levelState.RLock()
+	debugOn := levelState.revert != nil && levelState.current.global <= slog.LevelDebug
+	levelState.RUnlock()
+	if debugOn {
+		return ResetLogLevels(ctx)
+	}
+
+	scopes := make(map[string]string, len(LogScopes()))
+	for _, scope := range LogScopes() {
+		scopes[scope] = "debug"
+	}
+	config, err := SetLogLevels(ctx, "debug", scopes, DefaultLogLevelTTL)
+	if err != nil {
+		// Can't happen: the levels and scopes are all valid
+		panic(err)
+	}
+	return config
+}
+
+// CurrentLogLevels returns the effective levels
+func CurrentLogLevels() LogLevelConfig {
+	levelState.RLock()
+	defer levelState.RUnlock()
+	return currentLogLevelsLocked()
+}
+
+func currentLogLevelsLocked() LogLevelConfig {
+	config := LogLevelConfig{Level: levelName(levelState.current.global)}
+	if len(levelState.current.scopes) > 0 {
+		config.Scopes = make(map[string]string, len(levelState.current.scopes))
+		for scope, level := range levelState.current.scopes {
+			config.Scopes[scope] = levelName(level)
+		}
+	}
+	if levelState.revert != nil {
+		expiresAt := levelState.expiresAt
+		config.ExpiresAt = &expiresAt
+	}
+	return config
+}
+
+// String formats the levels as "level=info server=debug ..."
+func (c LogLevelConfig) String() string {
+	var b strings.Builder
+	b.WriteString("level=" + c.Level)
+	for _, scope := range LogScopes() {
+		if level, ok := c.Scopes[scope]; ok {
+			b.WriteString(" " + scope + "=" + level)
+		}
+	}
+	return b.String()
+}
+
+func revertLogLevels(generation uint64) {
+	levelState.Lock()
+	if generation != levelState.generation {
+		levelState.Unlock()
+		return
+	}
+	levelState.revert = nil
+	levelState.current = levelState.base.clone()
+	config := currentLogLevelsLocked()
+	levelState.Unlock()
+
+	ScopedLogger(ScopeTelemetry).Info("Log level change expired, reverted to configured levels", slog.Any("levels", config))
+}
+
+// stopRevertLocked cancels the pending revert and starts a new generation
+func stopRevertLocked() {
+	levelState.generation++
+	if levelState.revert != nil {
+		levelState.revert.Stop()
+		levelState.revert = nil
+	}
+}
+
+// levelEnabled reports whether a record at level passes the scope's threshold.
+// Scopes without an override follow the global level.
+func levelEnabled(scope string, level slog.Level) bool {
+	levelState.RLock()
+	defer levelState.RUnlock()
+	if threshold, ok := levelState.current.scopes[scope]; ok {
+		return level >= threshold
+	}
+	return level >= levelState.current.global
+}
+
+func isLogScope(scope string) bool {
+	for _, s := range LogScopes() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// levelHandler drops records below the level of its scope
+type levelHandler struct {
+	scope string
+	next  slog.Handler
+}
+
+func newLevelHandler(scope string, next slog.Handler) *levelHandler {
+	return &levelHandler{scope: scope, next: next}
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return levelEnabled(h.scope, level) && h.next.Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.next.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{scope: h.scope, next: h.next.WithAttrs(attrs)}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{scope: h.scope, next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,143 @@
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Log levels", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		useRecordingLogger()
+		ctx = context.Background()
+		DeferCleanup(func() {
+			Expect(LoadLogLevels()).To(Succeed())
+		})
+	})
+
+	Describe("ParseLogLevel", func() {
+		It("should parse the level names", func() {
+			for name, want := range map[string]slog.Level{
+				"debug": slog.LevelDebug, "INFO": slog.LevelInfo, "warning": slog.LevelWarn, " error ": slog.LevelError,
+			} {
+				level, err := ParseLogLevel(name)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(level).To(Equal(want))
+			}
+		})
+
+		It("should reject unknown levels", func() {
+			_, err := ParseLogLevel("verbose")
+			Expect(err).To(MatchError(ContainSubstring("invalid log level")))
+		})
+	})
+
+	Describe("LoadLogLevels", func() {
+		It("should read LOG_LEVEL and the scope overrides", func() {
+			os.Setenv("LOG_LEVEL", "warn")
+			os.Setenv("LOG_LEVEL_SERVER", "debug")
+			DeferCleanup(os.Unsetenv, "LOG_LEVEL")
+			DeferCleanup(os.Unsetenv, "LOG_LEVEL_SERVER")
+
+			Expect(LoadLogLevels()).To(Succeed())
+
+			Expect(CurrentLogLevels()).To(Equal(LogLevelConfig{Level: "warn", Scopes: map[string]string{ScopeServer: "debug"}}))
+			Expect(Logger().Enabled(ctx, slog.LevelInfo)).To(BeFalse())
+			Expect(ScopedLogger(ScopeServer).Enabled(ctx, slog.LevelDebug)).To(BeTrue())
+			Expect(ScopedLogger(ScopeMetrics).Enabled(ctx, slog.LevelInfo)).To(BeFalse())
+		})
+
+		It("should reject invalid levels", func() {
+			os.Setenv("LOG_LEVEL_METRICS", "loud")
+			DeferCleanup(os.Unsetenv, "LOG_LEVEL_METRICS")
+
+			Expect(LoadLogLevels()).To(MatchError(ContainSubstring("LOG_LEVEL_METRICS")))
+		})
+	})
+
+	Describe("SetLogLevels", func() {
+		It("should drop debug records by default", func() {
+			Expect(Logger().Enabled(ctx, slog.LevelDebug)).To(BeFalse())
+			Expect(Logger().Enabled(ctx, slog.LevelInfo)).To(BeTrue())
+		})
+
+		It("should override a single scope", func() {
+			config, err := SetLogLevels(ctx, "", map[string]string{ScopeMetrics: "debug"}, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(config.Level).To(Equal("info"))
+			Expect(config.Scopes).To(Equal(map[string]string{ScopeMetrics: "debug"}))
+			Expect(config.ExpiresAt).NotTo(BeNil())
+			Expect(ScopedLogger(ScopeMetrics).Enabled(ctx, slog.LevelDebug)).To(BeTrue())
+			Expect(ScopedLogger(ScopeServer).Enabled(ctx, slog.LevelDebug)).To(BeFalse())
+			Expect(Logger().Enabled(ctx, slog.LevelDebug)).To(BeFalse())
+		})
+
+		It("should remove a scope override given an empty level", func() {
+			_, err := SetLogLevels(ctx, "", map[string]string{ScopeServer: "error"}, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			config, err := SetLogLevels(ctx, "", map[string]string{ScopeServer: ""}, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(config.Scopes).To(BeEmpty())
+			Expect(ScopedLogger(ScopeServer).Enabled(ctx, slog.LevelInfo)).To(BeTrue())
+		})
+
+		It("should revert after the TTL", func() {
+			_, err := SetLogLevels(ctx, "debug", nil, 50*time.Millisecond)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(Logger().Enabled(ctx, slog.LevelDebug)).To(BeTrue())
+
+			Eventually(func() bool { return Logger().Enabled(ctx, slog.LevelDebug) }).Should(BeFalse())
+			Expect(CurrentLogLevels().ExpiresAt).To(BeNil())
+		})
+
+		It("should restart the TTL on every change", func() {
+			_, err := SetLogLevels(ctx, "debug", nil, 50*time.Millisecond)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = SetLogLevels(ctx, "debug", nil, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+
+			Consistently(func() bool { return Logger().Enabled(ctx, slog.LevelDebug) }, 150*time.Millisecond).Should(BeTrue())
+		})
+
+		It("should reject unknown scopes, bad levels and bad TTLs", func() {
+			_, err := SetLogLevels(ctx, "", map[string]string{"database": "debug"}, 0)
+			Expect(err).To(MatchError(ContainSubstring("unknown log scope")))
+			_, err = SetLogLevels(ctx, "loud", nil, 0)
+			Expect(err).To(MatchError(ContainSubstring("invalid log level")))
+			_, err = SetLogLevels(ctx, "debug", nil, 48*time.Hour)
+			Expect(err).To(MatchError(ContainSubstring("ttl")))
+
+			Expect(CurrentLogLevels().ExpiresAt).To(BeNil())
+		})
+	})
+
+	Describe("ToggleDebugLogging", func() {
+		It("should switch debug logging on and off", func() {
+			config := ToggleDebugLogging(ctx)
+			Expect(config.Level).To(Equal("debug"))
+			Expect(ScopedLogger(ScopeTelemetry).Enabled(ctx, slog.LevelDebug)).To(BeTrue())
+
+			config = ToggleDebugLogging(ctx)
+			Expect(config.Level).To(Equal("info"))
+			Expect(config.ExpiresAt).To(BeNil())
+			Expect(Logger().Enabled(ctx, slog.LevelDebug)).To(BeFalse())
+		})
+	})
+
+	It("should tag scoped records with log.scope", func() {
+		processor, out := useRecordingLogger()
+
+		ScopedLogger(ScopeServer).InfoContext(ctx, "scoped")
+
+		Expect(recordAttrs(processor.Records()[0])[LogScopeKey].AsString()).To(Equal(ScopeServer))
+		Expect(out.String()).To(ContainSubstring("[INFO] scoped log.scope=server"))
+	})
+})
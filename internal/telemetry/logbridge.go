@@ -0,0 +1,93 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// LevelFatal is the level of "[FATAL]" lines, mapped to OTel severity FATAL
+const LevelFatal = slog.LevelError + 4
+
+// stdlibPrefixes maps the "[LEVEL] " prefixes used with the log package to levels.
+// Lines without a known prefix are logged at info.
+var stdlibPrefixes = map[string]slog.Level{
+	"DEBUG":   slog.LevelDebug,
+	"INFO":    slog.LevelInfo,
+	"WARN":    slog.LevelWarn,
+	"WARNING": slog.LevelWarn,
+	"ERROR":   slog.LevelError,
+	"FATAL":   LevelFatal,
+}
+
+// stdlibFlushTimeout bounds how long a FATAL line waits for OTLP before log.Fatal exits
+const stdlibFlushTimeout = 2 * time.Second
+
+var bridgeMu sync.Mutex
+
+// stdlibWriter receives the output of the log package. The log package calls Write
+// once per message, so every call becomes one record.
+type stdlibWriter struct{}
+
+func (stdlibWriter) Write(p []byte) (int, error) {
+	level, message := parseStdlibLine(string(bytes.TrimRight(p, "\n")))
+	ctx := context.Background()
+	ScopedLogger(ScopeStdlib).Log(ctx, level, message)
+
+	// log.Fatal exits right after Write, before the batch processor exports
+	if level >= LevelFatal {
+		flushCtx, cancel := context.WithTimeout(ctx, stdlibFlushTimeout)
+		defer cancel()
+		mu.RLock()
+		provider := loggerProvider
+		mu.RUnlock()
+		if provider != nil {
+			_ = provider.ForceFlush(flushCtx)
+		}
+	}
+	return len(p), nil
+}
+
+// parseStdlibLine splits "[WARN] message" into its level and message
+func parseStdlibLine(line string) (slog.Level, string) {
+	if rest, ok := strings.CutPrefix(line, "["); ok {
+		if name, message, ok := strings.Cut(rest, "]"); ok {
+			if level, known := stdlibPrefixes[strings.ToUpper(name)]; known {
+				return level, strings.TrimPrefix(message, " ")
+			}
+		}
+	}
+	return slog.LevelInfo, line
+}
+
+// EnableStdlibBridge redirects the log package (log.Printf, log.Fatal, the OTel
+// SDK's default error handler, third-party libraries) into the structured logger,
+// so those lines follow LOG_ROUTING, LOG_FORMAT and the stdlib scope's level.
+// The structured logger writes stdout/stderr through its own log.Logger, never
+// through the log package, so bridged lines can't loop or be written twice.
+func EnableStdlibBridge() {
+	bridgeMu.Lock()
+	defer bridgeMu.Unlock()
+	// Timestamps come from the record, as for every other log line
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(stdlibWriter{})
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		ScopedLogger(ScopeTelemetry).Error("OpenTelemetry SDK error", slog.String(ErrorKey, err.Error()))
+	}))
+}
+
+// DisableStdlibBridge points the log package back at stderr
+func DisableStdlibBridge() {
+	bridgeMu.Lock()
+	defer bridgeMu.Unlock()
+	log.SetFlags(log.LstdFlags)
+	log.SetOutput(os.Stderr)
+}
@@ -0,0 +1,96 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	otellog "go.opentelemetry.io/otel/log"
+)
+
+var _ = Describe("Stdlib log bridge", func() {
+	var (
+		processor *recordingProcessor
+		out       *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		processor, out = useRecordingLogger()
+		EnableStdlibBridge()
+		DeferCleanup(DisableStdlibBridge)
+	})
+
+	Describe("parseStdlibLine", func() {
+		It("should parse the level prefixes", func() {
+			for line, want := range map[string]slog.Level{
+				"[DEBUG] m": slog.LevelDebug, "[INFO] m": slog.LevelInfo, "[WARN] m": slog.LevelWarn,
+				"[warning] m": slog.LevelWarn, "[ERROR] m": slog.LevelError, "[FATAL] m": LevelFatal,
+			} {
+				level, message := parseStdlibLine(line)
+				Expect(level).To(Equal(want), line)
+				Expect(message).To(Equal("m"))
+			}
+		})
+
+		It("should log lines without a known prefix at info", func() {
+			level, message := parseStdlibLine("[db] connected")
+			Expect(level).To(Equal(slog.LevelInfo))
+			Expect(message).To(Equal("[db] connected"))
+		})
+	})
+
+	It("should turn log.Printf lines into records", func() {
+		log.Printf("[WARN] disk %d%% full", 91)
+
+		records := processor.Records()
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].Severity()).To(Equal(otellog.SeverityWarn))
+		Expect(records[0].Body().AsString()).To(Equal("disk 91% full"))
+		Expect(recordAttrs(records[0])[LogScopeKey].AsString()).To(Equal(ScopeStdlib))
+	})
+
+	It("should write each line to stdout exactly once", func() {
+		log.Print("[INFO] once")
+
+		Expect(strings.Count(out.String(), "once")).To(Equal(1))
+		Expect(out.String()).To(MatchRegexp(`\[INFO\] once log.scope=stdlib`))
+	})
+
+	It("should apply the stdlib scope's level", func() {
+		log.Print("[DEBUG] hidden")
+		Expect(processor.Records()).To(BeEmpty())
+
+		_, err := SetLogLevels(context.Background(), "", map[string]string{ScopeStdlib: "debug"}, 0)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(ResetLogLevels, context.Background())
+
+		log.Print("[DEBUG] shown")
+		Expect(processor.Records()[len(processor.Records())-1].Body().AsString()).To(Equal("shown"))
+	})
+
+	It("should log OTel SDK errors as errors", func() {
+		otel.Handle(errors.New("export failed"))
+
+		records := processor.Records()
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].Severity()).To(Equal(otellog.SeverityError))
+		Expect(recordAttrs(records[0])[ErrorKey].AsString()).To(Equal("export failed"))
+	})
+
+	It("should write to stderr again once disabled", func() {
+		DisableStdlibBridge()
+
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		log.Print("[INFO] direct")
+
+		Expect(processor.Records()).To(BeEmpty())
+		Expect(buf.String()).To(ContainSubstring("[INFO] direct"))
+	})
+})
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
 	"sync"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	sdklog "go.opentelemetry.io/otel/sdk/log"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -31,6 +34,39 @@ func InitializeLogger() error {
 	serviceName := getEnv("OTEL_SERVICE_NAME", "dm-nkp-gitops-custom-app")
 	otlpEndpoint := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "otel-collector:4317")
 
+	// Level thresholds apply to stdout/stderr and OTLP alike
+	if err := LoadLogLevels(); err != nil {
+		log.Printf("[WARN] Invalid log level configuration: %v (using level info)", err)
+	}
+
+	// The JSON and logfmt stdout formats carry the same resource as OTLP records
+	res, err := newLogResource(ctx, serviceName)
+	if err != nil {
+		return err
+	}
+	if err := SetLogFormat(getEnv("LOG_FORMAT", LogFormatText), res); err != nil {
+		log.Printf("[WARN] %v (using %s)", err, LogFormatText)
+		_ = SetLogFormat(LogFormatText, res)
+	}
+	if err := LoadLogRouting(); err != nil {
+		log.Printf("[WARN] Invalid log routing configuration: %v (using %s)", err, LogRoutingBoth)
+	}
+	// Redaction applies to spans too; on error the default rules stay active
+	redactionRules, err := LoadRedactionConfig()
+	if err == nil {
+		err = SetRedactionRules(redactionRules, os.Getenv("REDACTION_HASH_SALT"))
+	}
+	if err != nil {
+		log.Printf("[WARN] Invalid redaction configuration: %v (using the default rules)", err)
+	}
+	samplingConfig, err := LoadSamplingConfig()
+	if err == nil {
+		err = SetLogSampling(samplingConfig)
+	}
+	if err != nil {
+		log.Printf("[WARN] Invalid log sampling configuration: %v (sampling disabled)", err)
+	}
+
 	// Always log to stdout/stderr for backward compatibility (standard practice)
 	log.Printf("[INFO] OpenTelemetry logging initialized")
 	log.Printf("[INFO] Logs will be sent via stdout/stderr (standard Go logging)")
@@ -39,7 +75,7 @@ func InitializeLogger() error {
 	// Standard practice: Graceful degradation if OTLP unavailable
 	otlpEnabled := getEnv("OTEL_LOGS_ENABLED", "true")
 	if otlpEnabled == "true" {
-		if err := initializeOTLPLogger(ctx, otlpEndpoint, serviceName); err != nil {
+		if err := initializeOTLPLogger(ctx, otlpEndpoint, res); err != nil {
 			log.Printf("[WARN] Failed to initialize OTLP logger: %v (continuing with stdout/stderr only)", err)
 			log.Printf("[INFO] OTLP logging disabled, using stdout/stderr only")
 			mu.Lock()
@@ -58,6 +94,11 @@ func InitializeLogger() error {
 		mu.Unlock()
 	}
 
+	// From here on log.Printf lines are records too (level from their [LEVEL] prefix)
+	if getEnv("LOG_STDLIB_BRIDGE", "true") == "true" {
+		EnableStdlibBridge()
+	}
+
 	return nil
 }
 
@@ -67,18 +108,7 @@ func InitializeLogger() error {
 // 2. Use batch processor for efficiency
 // 3. Use global logger provider for consistency
 // 4. Create logger with instrumentation scope name
-func initializeOTLPLogger(ctx context.Context, otlpEndpoint, serviceName string) error {
-	// Standard practice: Create resource with semantic convention attributes
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(serviceName),
-			semconv.ServiceVersion("0.1.0"),
-		),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create resource: %w", err)
-	}
-
+func initializeOTLPLogger(ctx context.Context, otlpEndpoint string, res *resource.Resource) error {
 	// Standard practice: Use OTLP gRPC exporter for logs
 	logExporter, err := otlploggrpc.New(ctx,
 		otlploggrpc.WithEndpoint(otlpEndpoint),
@@ -92,7 +122,8 @@ func initializeOTLPLogger(ctx context.Context, otlpEndpoint, serviceName string)
 	loggerProvider = sdklog.NewLoggerProvider(
 		sdklog.WithResource(res),
 		sdklog.WithProcessor(
-			sdklog.NewBatchProcessor(logExporter),
+			// healthExporter lets LOG_ROUTING=fallback write to stdout while exports fail
+			sdklog.NewBatchProcessor(healthExporter{logExporter}),
 		),
 	)
 
@@ -105,9 +136,28 @@ func initializeOTLPLogger(ctx context.Context, otlpEndpoint, serviceName string)
 	return nil
 }
 
+// newLogResource creates the resource of log records with semantic convention attributes
+func newLogResource(ctx context.Context, serviceName string) (*resource.Resource, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion("0.1.0"),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+	return res, nil
+}
+
 // ShutdownLogger gracefully shuts down the logger following standard practices.
 // Standard practice: Use context with timeout for graceful shutdown
 func ShutdownLogger(ctx context.Context) error {
+	// Log the last sampling summary while OTLP is still up. The log package must
+	// not write into the provider being shut down.
+	StopLogSampling()
+	DisableStdlibBridge()
+
 	mu.Lock()
 	defer mu.Unlock()
 
@@ -128,177 +178,31 @@ func ShutdownLogger(ctx context.Context) error {
 	return nil
 }
 
-// LogInfo logs an info message following OpenTelemetry standards.
-// Standard practices:
-// 1. Always log to stdout/stderr for backward compatibility
-// 2. Send via OTLP if enabled
-// 3. Use context for trace correlation
-// 4. Include semantic convention attributes
+// LogInfo logs an info message through the context's logger (see LoggerFromContext).
+// attrs are added as string attributes; use the slog logger directly for typed ones.
 func LogInfo(ctx context.Context, message string, attrs ...map[string]string) {
-	// Standard practice: Always log to stdout/stderr (backward compatibility)
-	log.Printf("[INFO] %s", message)
-
-	// Standard practice: Send via OTLP if enabled
-	mu.RLock()
-	useOTLPFlag := useOTLP
-	currentLogger := otlpLogger
-	mu.RUnlock()
-
-	if useOTLPFlag && currentLogger != nil {
-		// Standard practice: Create log record with proper structure
-		record := otellog.Record{}
-		record.SetSeverity(otellog.SeverityInfo)
-		record.SetSeverityText("INFO")
-		record.SetBody(otellog.StringValue(message))
-		record.SetTimestamp(time.Now())
-
-		// Standard practice: Add custom attributes
-		if len(attrs) > 0 {
-			for k, v := range attrs[0] {
-				record.AddAttributes(otellog.String(k, v))
-			}
-		}
-
-		// Standard practice: Add semantic convention attributes
-		record.AddAttributes(
-			otellog.String("log.level", "info"),
-			otellog.String("log.message", message),
-		)
-
-		// Standard practice: Emit log record with context for trace correlation
-		currentLogger.Emit(ctx, record)
-	}
+	LoggerFromContext(ctx).LogAttrs(ctx, slog.LevelInfo, message, mapAttrs(attrs)...)
 }
 
-// LogError logs an error message following OpenTelemetry standards.
-// Standard practices:
-// 1. Always log to stdout/stderr for backward compatibility
-// 2. Send via OTLP if enabled
-// 3. Include error details as attributes
-// 4. Use ERROR severity level
+// LogError logs an error message with the error in the exception.type,
+// exception.message and exception.stacktrace attributes, and records it as an
+// exception on the context's active span, setting the span status to Error
 func LogError(ctx context.Context, message string, err error, attrs ...map[string]string) {
-	// Standard practice: Always log to stdout/stderr (backward compatibility)
+	args := mapAttrs(attrs)
 	if err != nil {
-		log.Printf("[ERROR] %s: %v", message, err)
-	} else {
-		log.Printf("[ERROR] %s", message)
-	}
-
-	// Standard practice: Send via OTLP if enabled
-	mu.RLock()
-	useOTLPFlag := useOTLP
-	currentLogger := otlpLogger
-	mu.RUnlock()
-
-	if useOTLPFlag && currentLogger != nil {
-		// Standard practice: Create log record with ERROR severity
-		record := otellog.Record{}
-		record.SetSeverity(otellog.SeverityError)
-		record.SetSeverityText("ERROR")
-		record.SetBody(otellog.StringValue(message))
-		record.SetTimestamp(time.Now())
-
-		// Standard practice: Add custom attributes
-		if len(attrs) > 0 {
-			for k, v := range attrs[0] {
-				record.AddAttributes(otellog.String(k, v))
-			}
-		}
-
-		// Standard practice: Add semantic convention attributes
-		record.AddAttributes(
-			otellog.String("log.level", "error"),
-			otellog.String("log.message", message),
-		)
-
-		// Standard practice: Include error details as attribute
-		if err != nil {
-			record.AddAttributes(otellog.String("error", err.Error()))
-		}
-
-		// Standard practice: Emit log record with context for trace correlation
-		currentLogger.Emit(ctx, record)
+		e := newException(err, 1)
+		args = append(args, e.attrs()...)
+		e.record(trace.SpanFromContext(ctx))
 	}
+	LoggerFromContext(ctx).LogAttrs(ctx, slog.LevelError, message, args...)
 }
 
-// LogDebug logs a debug message following OpenTelemetry standards.
-// Standard practices:
-// 1. Always log to stdout/stderr for backward compatibility
-// 2. Send via OTLP if enabled
-// 3. Use DEBUG severity level
+// LogDebug logs a debug message
 func LogDebug(ctx context.Context, message string, attrs ...map[string]string) {
-	// Standard practice: Always log to stdout/stderr (backward compatibility)
-	log.Printf("[DEBUG] %s", message)
-
-	// Standard practice: Send via OTLP if enabled
-	mu.RLock()
-	useOTLPFlag := useOTLP
-	currentLogger := otlpLogger
-	mu.RUnlock()
-
-	if useOTLPFlag && currentLogger != nil {
-		// Standard practice: Create log record with DEBUG severity
-		record := otellog.Record{}
-		record.SetSeverity(otellog.SeverityDebug)
-		record.SetSeverityText("DEBUG")
-		record.SetBody(otellog.StringValue(message))
-		record.SetTimestamp(time.Now())
-
-		// Standard practice: Add custom attributes
-		if len(attrs) > 0 {
-			for k, v := range attrs[0] {
-				record.AddAttributes(otellog.String(k, v))
-			}
-		}
-
-		// Standard practice: Add semantic convention attributes
-		record.AddAttributes(
-			otellog.String("log.level", "debug"),
-			otellog.String("log.message", message),
-		)
-
-		// Standard practice: Emit log record with context for trace correlation
-		currentLogger.Emit(ctx, record)
-	}
+	LoggerFromContext(ctx).LogAttrs(ctx, slog.LevelDebug, message, mapAttrs(attrs)...)
 }
 
-// LogWarn logs a warning message following OpenTelemetry standards.
-// Standard practices:
-// 1. Always log to stdout/stderr for backward compatibility
-// 2. Send via OTLP if enabled
-// 3. Use WARN severity level
+// LogWarn logs a warning message
 func LogWarn(ctx context.Context, message string, attrs ...map[string]string) {
-	// Standard practice: Always log to stdout/stderr (backward compatibility)
-	log.Printf("[WARN] %s", message)
-
-	// Standard practice: Send via OTLP if enabled
-	mu.RLock()
-	useOTLPFlag := useOTLP
-	currentLogger := otlpLogger
-	mu.RUnlock()
-
-	if useOTLPFlag && currentLogger != nil {
-		// Standard practice: Create log record with WARN severity
-		record := otellog.Record{}
-		record.SetSeverity(otellog.SeverityWarn)
-		record.SetSeverityText("WARN")
-		record.SetBody(otellog.StringValue(message))
-		record.SetTimestamp(time.Now())
-
-		// Standard practice: Add custom attributes
-		if len(attrs) > 0 {
-			for k, v := range attrs[0] {
-				record.AddAttributes(otellog.String(k, v))
-			}
-		}
-
-		// Standard practice: Add semantic convention attributes
-		record.AddAttributes(
-			otellog.String("log.level", "warn"),
-			otellog.String("log.message", message),
-		)
-
-		// Standard practice: Emit log record with context for trace correlation
-		currentLogger.Emit(ctx, record)
-	}
+	LoggerFromContext(ctx).LogAttrs(ctx, slog.LevelWarn, message, mapAttrs(attrs)...)
 }
@@ -0,0 +1,460 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.yaml.in/yaml/v3"
+)
+
+// Redaction actions of a RedactionRule
+const (
+	// RedactDrop removes the attribute
+	RedactDrop = "drop"
+	// RedactHash replaces the value with the first 16 hex digits of its salted SHA-256
+	RedactHash = "hash"
+	// RedactMask replaces the matches of Pattern with Replacement
+	RedactMask = "mask"
+	// RedactTruncateIP keeps the /24 of IPv4 and the /48 of IPv6 addresses and drops the port
+	RedactTruncateIP = "truncate_ip"
+	// RedactStripQuery removes the Params query parameters from URLs, or the whole query if Params is empty
+	RedactStripQuery = "strip_query"
+)
+
+// RedactAllKeys as a rule key applies the rule to every string attribute and to log bodies
+const RedactAllKeys = "*"
+
+// RedactedValue replaces values a rule can't parse, so that they never leak unredacted
+const RedactedValue = "[REDACTED]"
+
+// RedactionRule redacts the attributes with one of Keys. Inside slog groups a key
+// matches the attribute's dotted path or any suffix of it, so "client.address"
+// matches the address attribute of a "client" group in a "request" group.
+type RedactionRule struct {
+	Action string   `yaml:"action"`
+	Keys   []string `yaml:"keys"`
+	// Pattern and Replacement configure RedactMask; Replacement may refer to
+	// capture groups ($1) and defaults to RedactedValue
+	Pattern     string `yaml:"pattern,omitempty"`
+	Replacement string `yaml:"replacement,omitempty"`
+	// Params are the query parameters RedactStripQuery removes, case-insensitively
+	Params []string `yaml:"params,omitempty"`
+}
+
+// RedactionConfig is the format of the REDACTION_CONFIG_FILE YAML file
+type RedactionConfig struct {
+	Rules []RedactionRule `yaml:"rules"`
+}
+
+// sensitiveQueryParams are the query parameters the default rules strip and mask
+var sensitiveQueryParams = []string{"token", "access_token", "id_token", "api_key", "apikey", "password", "secret"}
+
+// DefaultRedactionRules returns the rules applied unless REDACTION_CONFIG_FILE is set:
+// client IPs are truncated, tokens are stripped from URLs and masked anywhere else,
+// and user agents are hashed
+func DefaultRedactionRules() []RedactionRule {
+	return []RedactionRule{
+		{Action: RedactTruncateIP, Keys: []string{ClientAddressKey, "http.client_ip", "net.peer.ip", "net.sock.peer.addr"}},
+		{Action: RedactStripQuery, Keys: []string{"http.url", "http.target", "url.full", URLQueryKey, HTTPRefererKey}, Params: sensitiveQueryParams},
+		{Action: RedactHash, Keys: []string{"user_agent", "http.user_agent", UserAgentKey}},
+		{
+			Action:      RedactMask,
+			Keys:        []string{RedactAllKeys},
+			Pattern:     `(?i)\b(` + strings.Join(sensitiveQueryParams, "|") + `)=[^&\s"]+`,
+			Replacement: "${1}=" + RedactedValue,
+		},
+		{Action: RedactMask, Keys: []string{RedactAllKeys}, Pattern: `(?i)\b(bearer\s+)[A-Za-z0-9._~+/=-]+`, Replacement: "${1}" + RedactedValue},
+	}
+}
+
+// compiledRule is a validated RedactionRule
+type compiledRule struct {
+	RedactionRule
+	pattern *regexp.Regexp
+	params  map[string]bool
+}
+
+// Redactor applies redaction rules to span and log attributes
+type Redactor struct {
+	byKey    map[string][]compiledRule
+	wildcard []compiledRule
+	salt     string
+}
+
+// NewRedactor validates the rules. salt is prepended to values before hashing,
+// so that hashes of low-entropy values can't be reversed with a lookup table.
+func NewRedactor(rules []RedactionRule, salt string) (*Redactor, error) {
+	r := &Redactor{byKey: map[string][]compiledRule{}, salt: salt}
+	var errs []error
+	for i, rule := range rules {
+		c, err := compileRule(rule)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %d: %w", i+1, err))
+			continue
+		}
+		for _, key := range rule.Keys {
+			if key == RedactAllKeys {
+				r.wildcard = append(r.wildcard, c)
+			} else {
+				r.byKey[key] = append(r.byKey[key], c)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return r, nil
+}
+
+func compileRule(rule RedactionRule) (compiledRule, error) {
+	c := compiledRule{RedactionRule: rule}
+	if len(rule.Keys) == 0 {
+		return c, fmt.Errorf("%s: no keys", rule.Action)
+	}
+	switch rule.Action {
+	case RedactDrop, RedactHash, RedactTruncateIP:
+	case RedactMask:
+		if rule.Pattern == "" {
+			return c, errors.New("mask: no pattern")
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return c, fmt.Errorf("mask: %w", err)
+		}
+		c.pattern = pattern
+		if c.Replacement == "" {
+			c.Replacement = RedactedValue
+		}
+	case RedactStripQuery:
+		c.params = make(map[string]bool, len(rule.Params))
+		for _, param := range rule.Params {
+			c.params[strings.ToLower(param)] = true
+		}
+	default:
+		return c, fmt.Errorf("invalid action %q (want %s, %s, %s, %s or %s)",
+			rule.Action, RedactDrop, RedactHash, RedactMask, RedactTruncateIP, RedactStripQuery)
+	}
+	if rule.Action != RedactMask {
+		for _, key := range rule.Keys {
+			if key == RedactAllKeys {
+				return c, fmt.Errorf("%s: key %q is only supported by %s", rule.Action, RedactAllKeys, RedactMask)
+			}
+		}
+	}
+	return c, nil
+}
+
+// keyRules returns the rules of the keys matching an attribute's dotted path,
+// longest first
+func (r *Redactor) keyRules(path string) []compiledRule {
+	var rules []compiledRule
+	for {
+		rules = append(rules, r.byKey[path]...)
+		_, suffix, ok := strings.Cut(path, ".")
+		if !ok {
+			return rules
+		}
+		path = suffix
+	}
+}
+
+// redactString applies the rules of an attribute, then the wildcard ones, to
+// its value. It returns false if the attribute is dropped.
+func (r *Redactor) redactString(path, value string) (string, bool) {
+	for _, rule := range append(r.keyRules(path), r.wildcard...) {
+		switch rule.Action {
+		case RedactDrop:
+			return "", false
+		case RedactHash:
+			value = r.hash(value)
+		case RedactMask:
+			value = rule.pattern.ReplaceAllString(value, rule.Replacement)
+		case RedactTruncateIP:
+			value = truncateIP(value)
+		case RedactStripQuery:
+			value = stripQuery(value, rule.params)
+		}
+	}
+	return value, true
+}
+
+// RedactValue applies the active rules of key to a value written somewhere other
+// than an attribute, such as an access log line. It returns false if the rules
+// drop the attribute.
+func RedactValue(key, value string) (string, bool) {
+	return currentRedactor().redactString(key, value)
+}
+
+// RedactMessage applies the wildcard mask rules to a log body
+func (r *Redactor) RedactMessage(message string) string {
+	for _, rule := range r.wildcard {
+		message = rule.pattern.ReplaceAllString(message, rule.Replacement)
+	}
+	return message
+}
+
+// RedactAttributes returns the span attributes with the rules applied
+func (r *Redactor) RedactAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	out := make([]attribute.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		key := string(kv.Key)
+		if kv.Value.Type() != attribute.STRING {
+			if len(r.keyRules(key)) == 0 {
+				out = append(out, kv)
+				continue
+			}
+			// Rules on the key apply to the value's string form
+			kv = attribute.String(key, kv.Value.Emit())
+		}
+		if value, ok := r.redactString(key, kv.Value.AsString()); ok {
+			out = append(out, attribute.String(key, value))
+		}
+	}
+	return out
+}
+
+// redactAttr applies the rules to a resolved slog attribute inside the groups of prefix
+func (r *Redactor) redactAttr(prefix string, a slog.Attr) (slog.Attr, bool) {
+	path := a.Key
+	if prefix != "" && a.Key != "" {
+		path = prefix + "." + a.Key
+	} else if a.Key == "" {
+		path = prefix
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := r.redactAttrs(path, a.Value.Group())
+		if len(group) == 0 {
+			return slog.Attr{}, false
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(group...)}, true
+	case slog.KindString:
+	default:
+		err, isErr := a.Value.Any().(error)
+		switch {
+		case isErr && a.Value.Kind() == slog.KindAny:
+			a.Value = slog.StringValue(err.Error())
+		case len(r.keyRules(path)) > 0:
+			a.Value = slog.StringValue(a.Value.String())
+		default:
+			return a, true
+		}
+	}
+	value, ok := r.redactString(path, a.Value.String())
+	return slog.String(a.Key, value), ok
+}
+
+func (r *Redactor) redactAttrs(prefix string, attrs []slog.Attr) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a = resolve(a); a.Equal(slog.Attr{}) {
+			continue
+		}
+		if a, ok := r.redactAttr(prefix, a); ok {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func (r *Redactor) hash(value string) string {
+	sum := sha256.Sum256([]byte(r.salt + value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// truncateIP zeroes the host part of an address, dropping the port of "host:port"
+func truncateIP(value string) string {
+	host := value
+	if h, _, err := net.SplitHostPort(value); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	if ip == nil {
+		return RedactedValue
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// stripQuery removes query parameters from a URL or a bare query string,
+// keeping the others in their order
+func stripQuery(value string, params map[string]bool) string {
+	base, query, hasQuery := strings.Cut(value, "?")
+	if !hasQuery {
+		if strings.HasPrefix(value, "/") || strings.Contains(value, "://") || !strings.Contains(value, "=") {
+			return value
+		}
+		// A bare query string, as in url.query
+		base, query = "", value
+	}
+	query, fragment, hasFragment := strings.Cut(query, "#")
+
+	var kept []string
+	if len(params) > 0 {
+		for _, pair := range strings.Split(query, "&") {
+			name, _, _ := strings.Cut(pair, "=")
+			if unescaped, err := url.QueryUnescape(name); err == nil {
+				name = unescaped
+			}
+			if pair != "" && !params[strings.ToLower(name)] {
+				kept = append(kept, pair)
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(base)
+	if len(kept) > 0 {
+		if hasQuery {
+			b.WriteString("?")
+		}
+		b.WriteString(strings.Join(kept, "&"))
+	}
+	if hasFragment {
+		b.WriteString("#")
+		b.WriteString(fragment)
+	}
+	return b.String()
+}
+
+var (
+	activeRedactor atomic.Pointer[Redactor]
+	// defaultRedactor applies DefaultRedactionRules until SetRedactionRules is called
+	defaultRedactor = mustRedactor(DefaultRedactionRules())
+)
+
+func mustRedactor(rules []RedactionRule) *Redactor {
+	r, err := NewRedactor(rules, "")
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func currentRedactor() *Redactor {
+	if r := activeRedactor.Load(); r != nil {
+		return r
+	}
+	return defaultRedactor
+}
+
+// SetRedactionRules replaces the rules applied to spans and log records. No
+// rules disables redaction.
+func SetRedactionRules(rules []RedactionRule, salt string) error {
+	r, err := NewRedactor(rules, salt)
+	if err != nil {
+		return err
+	}
+	activeRedactor.Store(r)
+	return nil
+}
+
+// LoadRedactionConfig reads the rules from the YAML file in REDACTION_CONFIG_FILE,
+// or returns DefaultRedactionRules() when it is unset
+func LoadRedactionConfig() ([]RedactionRule, error) {
+	path := os.Getenv("REDACTION_CONFIG_FILE")
+	if path == "" {
+		return DefaultRedactionRules(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redaction config: %w", err)
+	}
+	var cfg RedactionConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse redaction config: %w", err)
+	}
+	return cfg.Rules, nil
+}
+
+// redactingHandler applies the active redaction rules to records before they
+// are written to stdout/stderr or OTLP
+type redactingHandler struct {
+	next   slog.Handler
+	groups string // dotted path of the open groups
+}
+
+func newRedactingHandler(next slog.Handler) *redactingHandler {
+	return &redactingHandler{next: next}
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	redactor := currentRedactor()
+	redacted := slog.NewRecord(r.Time, r.Level, redactor.RedactMessage(r.Message), r.PC)
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	redacted.AddAttrs(redactor.redactAttrs(h.groups, attrs)...)
+	return h.next.Handle(ctx, redacted)
+}
+
+// WithAttrs redacts with the rules active when the child logger is created
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &redactingHandler{next: h.next.WithAttrs(currentRedactor().redactAttrs(h.groups, attrs)), groups: h.groups}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	groups := name
+	if h.groups != "" {
+		groups = h.groups + "." + name
+	}
+	return &redactingHandler{next: h.next.WithGroup(name), groups: groups}
+}
+
+// redactingSpanProcessor hands ended spans to the next processor with the active
+// redaction rules applied to their attributes and event attributes
+type redactingSpanProcessor struct {
+	sdktrace.SpanProcessor
+}
+
+func newRedactingSpanProcessor(next sdktrace.SpanProcessor) sdktrace.SpanProcessor {
+	return redactingSpanProcessor{next}
+}
+
+func (p redactingSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.SpanProcessor.OnEnd(redactedSpan{ReadOnlySpan: s, redactor: currentRedactor()})
+}
+
+// redactedSpan overrides the attributes of an ended span
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	redactor *Redactor
+}
+
+func (s redactedSpan) Attributes() []attribute.KeyValue {
+	return s.redactor.RedactAttributes(s.ReadOnlySpan.Attributes())
+}
+
+func (s redactedSpan) Events() []sdktrace.Event {
+	events := s.ReadOnlySpan.Events()
+	out := make([]sdktrace.Event, len(events))
+	for i, event := range events {
+		event.Attributes = s.redactor.RedactAttributes(event.Attributes)
+		out[i] = event
+	}
+	return out
+}
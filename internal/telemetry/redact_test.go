@@ -0,0 +1,247 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ = Describe("Redaction", func() {
+	redact := func(rules []RedactionRule, key, value string) string {
+		r, err := NewRedactor(rules, "")
+		Expect(err).NotTo(HaveOccurred())
+		value, ok := r.redactString(key, value)
+		Expect(ok).To(BeTrue(), "attribute dropped")
+		return value
+	}
+
+	dropped := func(rules []RedactionRule, key, value string) bool {
+		r, err := NewRedactor(rules, "")
+		Expect(err).NotTo(HaveOccurred())
+		_, ok := r.redactString(key, value)
+		return !ok
+	}
+
+	useRules := func(rules []RedactionRule) {
+		Expect(SetRedactionRules(rules, "")).To(Succeed())
+		DeferCleanup(SetRedactionRules, DefaultRedactionRules(), "")
+	}
+
+	Describe("rules", func() {
+		It("should drop attributes", func() {
+			Expect(dropped([]RedactionRule{{Action: RedactDrop, Keys: []string{"enduser.id"}}}, "enduser.id", "alice")).To(BeTrue())
+		})
+
+		It("should hash values with the salt", func() {
+			rules := []RedactionRule{{Action: RedactHash, Keys: []string{"user_agent"}}}
+			hashed := redact(rules, "user_agent", "curl/8.5.0")
+			Expect(hashed).To(MatchRegexp(`^[0-9a-f]{16}$`))
+			Expect(redact(rules, "user_agent", "curl/8.5.0")).To(Equal(hashed))
+
+			salted, err := NewRedactor(rules, "pepper")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(salted.hash("curl/8.5.0")).NotTo(Equal(hashed))
+		})
+
+		It("should mask regex matches", func() {
+			rules := []RedactionRule{{Action: RedactMask, Keys: []string{"card"}, Pattern: `\d{12}(\d{4})`, Replacement: "************$1"}}
+			Expect(redact(rules, "card", "4111111111111111")).To(Equal("************1111"))
+
+			rules[0].Replacement = ""
+			Expect(redact(rules, "card", "card 4111111111111111")).To(Equal("card " + RedactedValue))
+		})
+
+		It("should truncate IPs", func() {
+			rules := []RedactionRule{{Action: RedactTruncateIP, Keys: []string{ClientAddressKey}}}
+			for value, want := range map[string]string{
+				"192.0.2.77":                 "192.0.2.0",
+				"192.0.2.77:51234":           "192.0.2.0",
+				"[2001:db8:1:2::7]:443":      "2001:db8:1::",
+				"2001:db8:1:2:3:4:5:6":       "2001:db8:1::",
+				"not-an-ip.example.com:8080": RedactedValue,
+			} {
+				Expect(redact(rules, ClientAddressKey, value)).To(Equal(want), value)
+			}
+		})
+
+		It("should strip named query parameters", func() {
+			rules := []RedactionRule{{Action: RedactStripQuery, Keys: []string{"http.url"}, Params: []string{"token", "API_KEY"}}}
+			for value, want := range map[string]string{
+				"/?token=s3cret&page=2":                   "/?page=2",
+				"/search?q=go&api_key=k#top":              "/search?q=go#top",
+				"https://example.com/a?Token=x":           "https://example.com/a",
+				"/health":                                 "/health",
+				"page=2&token=s3cret":                     "page=2",
+				"/callback?state=abc&to%6Ben=x&state2=de": "/callback?state=abc&state2=de",
+			} {
+				Expect(redact(rules, "http.url", value)).To(Equal(want), value)
+			}
+		})
+
+		It("should strip the whole query without parameters", func() {
+			rules := []RedactionRule{{Action: RedactStripQuery, Keys: []string{"url.full"}}}
+			Expect(redact(rules, "url.full", "http://localhost:8080/?a=1&b=2")).To(Equal("http://localhost:8080/"))
+		})
+
+		It("should match keys as suffixes of group paths", func() {
+			rules := []RedactionRule{{Action: RedactDrop, Keys: []string{"client.address"}}}
+			Expect(dropped(rules, "request.client.address", "192.0.2.77")).To(BeTrue())
+			Expect(dropped(rules, "request.server.address", "192.0.2.77")).To(BeFalse())
+		})
+
+		It("should reject invalid rules", func() {
+			_, err := NewRedactor([]RedactionRule{
+				{Action: "encrypt", Keys: []string{"a"}},
+				{Action: RedactMask, Keys: []string{"a"}, Pattern: "("},
+				{Action: RedactDrop},
+				{Action: RedactDrop, Keys: []string{RedactAllKeys}},
+			}, "")
+			Expect(err).To(MatchError(ContainSubstring(`rule 1: invalid action "encrypt"`)))
+			Expect(err).To(MatchError(ContainSubstring("rule 2: mask:")))
+			Expect(err).To(MatchError(ContainSubstring("rule 3: drop: no keys")))
+			Expect(err).To(MatchError(ContainSubstring(`rule 4: drop: key "*" is only supported by mask`)))
+		})
+	})
+
+	Describe("default rules", func() {
+		It("should redact what the root handler records", func() {
+			r, err := NewRedactor(DefaultRedactionRules(), "")
+			Expect(err).NotTo(HaveOccurred())
+
+			attrs := r.RedactAttributes([]attribute.KeyValue{
+				attribute.String("http.url", "/?access_token=abc&lang=en"),
+				attribute.String("http.client_ip", "203.0.113.9:40000"),
+				attribute.String("user_agent", "Mozilla/5.0"),
+				attribute.String("http.route", "/"),
+				attribute.Int("http.status_code", 200),
+			})
+			Expect(attrs).To(ConsistOf(
+				attribute.String("http.url", "/?lang=en"),
+				attribute.String("http.client_ip", "203.0.113.0"),
+				attribute.String("user_agent", r.hash("Mozilla/5.0")),
+				attribute.String("http.route", "/"),
+				attribute.Int("http.status_code", 200),
+			))
+		})
+
+		It("should mask tokens in any value", func() {
+			r, err := NewRedactor(DefaultRedactionRules(), "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.RedactMessage("GET /?password=hunter2 with Authorization: Bearer eyJhbGciOi.x.y")).
+				To(Equal("GET /?password=[REDACTED] with Authorization: Bearer [REDACTED]"))
+		})
+	})
+
+	Describe("LoadRedactionConfig", func() {
+		It("should default to the default rules", func() {
+			Expect(LoadRedactionConfig()).To(Equal(DefaultRedactionRules()))
+		})
+
+		It("should read the YAML file", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "redaction.yaml")
+			Expect(os.WriteFile(path, []byte(`
+rules:
+  - action: strip_query
+    keys: [http.url]
+    params: [session]
+  - action: mask
+    keys: ["*"]
+    pattern: 'ssn=\d+'
+`), 0o600)).To(Succeed())
+			os.Setenv("REDACTION_CONFIG_FILE", path)
+			DeferCleanup(os.Unsetenv, "REDACTION_CONFIG_FILE")
+
+			Expect(LoadRedactionConfig()).To(Equal([]RedactionRule{
+				{Action: RedactStripQuery, Keys: []string{"http.url"}, Params: []string{"session"}},
+				{Action: RedactMask, Keys: []string{RedactAllKeys}, Pattern: `ssn=\d+`},
+			}))
+		})
+	})
+
+	Describe("log pipeline", func() {
+		var (
+			processor *recordingProcessor
+			out       *bytes.Buffer
+			ctx       context.Context
+		)
+
+		BeforeEach(func() {
+			processor, out = useRecordingLogger()
+			ctx = context.Background()
+		})
+
+		It("should redact attributes, child logger attributes and the body on both paths", func() {
+			Logger().With(slog.String(ClientAddressKey, "198.51.100.23:5000")).InfoContext(ctx,
+				"Fetched /?token=abc",
+				slog.String("url.full", "http://app/?token=abc&x=1"),
+				slog.Any(ErrorKey, errors.New("bad api_key=k123")),
+			)
+
+			record := processor.Records()[0]
+			Expect(record.Body().AsString()).To(Equal("Fetched /?token=[REDACTED]"))
+			attrs := recordAttrs(record)
+			Expect(attrs[LogMessageKey].AsString()).To(Equal("Fetched /?token=[REDACTED]"))
+			Expect(attrs[ClientAddressKey].AsString()).To(Equal("198.51.100.0"))
+			Expect(attrs["url.full"].AsString()).To(Equal("http://app/?x=1"))
+			Expect(attrs[ErrorKey].AsString()).To(Equal("bad api_key=[REDACTED]"))
+
+			Expect(out.String()).NotTo(ContainSubstring("abc"))
+			Expect(out.String()).NotTo(ContainSubstring("198.51.100.23"))
+			Expect(out.String()).NotTo(ContainSubstring("k123"))
+		})
+
+		It("should drop attributes inside groups", func() {
+			useRules([]RedactionRule{{Action: RedactDrop, Keys: []string{"enduser.id"}}})
+
+			Logger().WithGroup("request").InfoContext(ctx, "grouped",
+				slog.String("enduser.id", "alice"), slog.String("id", "1"))
+
+			Expect(out.String()).To(ContainSubstring("[INFO] grouped request.id=1"))
+			Expect(out.String()).NotTo(ContainSubstring("alice"))
+		})
+
+		It("should pass records through without rules", func() {
+			useRules(nil)
+
+			Logger().InfoContext(ctx, "token=abc", slog.String(ClientAddressKey, "198.51.100.23"))
+
+			Expect(out.String()).To(ContainSubstring("[INFO] token=abc client.address=198.51.100.23"))
+		})
+	})
+
+	Describe("span processor", func() {
+		It("should redact span and event attributes before export", func() {
+			exporter := tracetest.NewInMemoryExporter()
+			provider := sdktrace.NewTracerProvider(
+				sdktrace.WithSpanProcessor(newRedactingSpanProcessor(sdktrace.NewSimpleSpanProcessor(exporter))),
+			)
+			DeferCleanup(provider.Shutdown, context.Background())
+
+			_, span := provider.Tracer("test").Start(context.Background(), "GET /")
+			span.SetAttributes(
+				attribute.String("http.url", "/?token=abc&page=1"),
+				attribute.String("http.client_ip", "192.0.2.77:1234"),
+			)
+			span.AddEvent("redirect", trace.WithAttributes(attribute.String("url.full", "https://idp/?id_token=xyz")))
+			span.End()
+
+			spans := exporter.GetSpans()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Attributes).To(ConsistOf(
+				attribute.String("http.url", "/?page=1"),
+				attribute.String("http.client_ip", "192.0.2.0"),
+			))
+			Expect(spans[0].Events[0].Attributes).To(ConsistOf(attribute.String("url.full", "https://idp/")))
+		})
+	})
+})
@@ -0,0 +1,214 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// Log routing policies accepted by LOG_ROUTING
+const (
+	// LogRoutingBoth writes every record to stdout/stderr and OTLP (the default)
+	LogRoutingBoth = "both"
+	// LogRoutingOTLP sends records via OTLP only. Records go to stdout/stderr
+	// while OTLP logging is disabled or not initialized, so they are never lost silently.
+	LogRoutingOTLP = "otlp"
+	// LogRoutingStdout writes records to stdout/stderr only
+	LogRoutingStdout = "stdout"
+	// LogRoutingFallback sends records via OTLP and writes them to stdout/stderr
+	// as well while OTLP export is failing
+	LogRoutingFallback = "fallback"
+)
+
+// LogRecordUIDKey is set on records that are written to both stdout/stderr and
+// OTLP, with the same value on both copies, so downstream pipelines can dedupe
+// them (semantic convention log.record.uid)
+const LogRecordUIDKey = "log.record.uid"
+
+var (
+	logRouting atomic.Value // string
+	// scopeRouting holds the per-scope overrides (scope -> policy)
+	scopeRouting sync.Map
+	// otlpExportFailing is set while the last OTLP log export failed
+	otlpExportFailing atomic.Bool
+)
+
+// ParseLogRouting parses both, otlp, stdout or fallback, case-insensitively
+func ParseLogRouting(s string) (string, error) {
+	switch routing := strings.ToLower(strings.TrimSpace(s)); routing {
+	case LogRoutingBoth, LogRoutingOTLP, LogRoutingStdout, LogRoutingFallback:
+		return routing, nil
+	default:
+		return "", fmt.Errorf("invalid log routing %q (want %s, %s, %s or %s)",
+			s, LogRoutingBoth, LogRoutingOTLP, LogRoutingStdout, LogRoutingFallback)
+	}
+}
+
+// SetLogRouting selects where records are written
+func SetLogRouting(routing string) error {
+	routing, err := ParseLogRouting(routing)
+	if err != nil {
+		return err
+	}
+	logRouting.Store(routing)
+	return nil
+}
+
+// SetScopeLogRouting overrides the routing policy for the records of a logger
+// scope (see ScopedLogger). An empty routing removes the override.
+func SetScopeLogRouting(scope, routing string) error {
+	if routing == "" {
+		scopeRouting.Delete(scope)
+		return nil
+	}
+	routing, err := ParseLogRouting(routing)
+	if err != nil {
+		return fmt.Errorf("scope %s: %w", scope, err)
+	}
+	scopeRouting.Store(scope, routing)
+	return nil
+}
+
+// LoadLogRouting reads LOG_ROUTING and the per-scope LOG_ROUTING_<SCOPE>
+// variables (e.g. LOG_ROUTING_ACCESS=stdout). Invalid values are reported and
+// leave the default in place.
+func LoadLogRouting() error {
+	var errs []error
+	if err := SetLogRouting(getEnv("LOG_ROUTING", LogRoutingBoth)); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_ROUTING: %w", err))
+		_ = SetLogRouting(LogRoutingBoth)
+	}
+	for _, scope := range LogScopes() {
+		key := "LOG_ROUTING_" + strings.ToUpper(scope)
+		if err := SetScopeLogRouting(scope, os.Getenv(key)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func currentLogRouting() string {
+	if routing, ok := logRouting.Load().(string); ok {
+		return routing
+	}
+	return LogRoutingBoth
+}
+
+// routingHandler writes each record to stdout/stderr, OTLP or both according to
+// the routing policy of its scope, or the global one
+type routingHandler struct {
+	stdout slog.Handler
+	otlp   slog.Handler
+	scope  string // the log.scope attribute added with WithAttrs, if any
+}
+
+func newRoutingHandler(stdout, otlp slog.Handler) *routingHandler {
+	return &routingHandler{stdout: stdout, otlp: otlp}
+}
+
+// route returns the destinations of a record at level
+func (h *routingHandler) route(ctx context.Context, level slog.Level) (toStdout, toOTLP bool) {
+	otlpOn := h.otlp.Enabled(ctx, level)
+	routing := currentLogRouting()
+	if scoped, ok := scopeRouting.Load(h.scope); ok && h.scope != "" {
+		routing = scoped.(string)
+	}
+	switch routing {
+	case LogRoutingStdout:
+		return true, false
+	case LogRoutingOTLP:
+		return !otlpOn, otlpOn
+	case LogRoutingFallback:
+		return !otlpOn || otlpExportFailing.Load(), otlpOn
+	default:
+		return true, otlpOn
+	}
+}
+
+func (h *routingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	toStdout, toOTLP := h.route(ctx, level)
+	return (toStdout && h.stdout.Enabled(ctx, level)) || toOTLP
+}
+
+func (h *routingHandler) Handle(ctx context.Context, r slog.Record) error {
+	toStdout, toOTLP := h.route(ctx, r.Level)
+	toStdout = toStdout && h.stdout.Enabled(ctx, r.Level)
+	if toStdout && toOTLP {
+		// Passed through the context rather than as a record attribute so that it
+		// stays a top-level attribute inside WithGroup
+		ctx = context.WithValue(ctx, recordUIDKey{}, newRecordUID())
+	}
+
+	var errs []error
+	if toStdout {
+		if err := h.stdout.Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if toOTLP {
+		if err := h.otlp.Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *routingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	scope := h.scope
+	for _, a := range attrs {
+		if a.Key == LogScopeKey && a.Value.Kind() == slog.KindString {
+			scope = a.Value.String()
+		}
+	}
+	return &routingHandler{stdout: h.stdout.WithAttrs(attrs), otlp: h.otlp.WithAttrs(attrs), scope: scope}
+}
+
+func (h *routingHandler) WithGroup(name string) slog.Handler {
+	return &routingHandler{stdout: h.stdout.WithGroup(name), otlp: h.otlp.WithGroup(name), scope: h.scope}
+}
+
+type recordUIDKey struct{}
+
+// recordUIDAttrs returns the log.record.uid attribute of a record written to both paths
+func recordUIDAttrs(ctx context.Context) []slog.Attr {
+	if uid, ok := ctx.Value(recordUIDKey{}).(string); ok {
+		return []slog.Attr{slog.String(LogRecordUIDKey, uid)}
+	}
+	return nil
+}
+
+// newRecordUID returns a random 128-bit hex ID
+func newRecordUID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// healthExporter tracks whether OTLP log export is failing, for LogRoutingFallback
+type healthExporter struct {
+	sdklog.Exporter
+}
+
+func (e healthExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	err := e.Exporter.Export(ctx, records)
+	failing := err != nil
+	if otlpExportFailing.Swap(failing) != failing {
+		// Written to stdout/stderr only: OTLP is the path that is failing
+		logger := slog.New(newStdoutHandler(stdLogger))
+		if failing {
+			logger.Warn("OTLP log export failing", slog.String(ErrorKey, err.Error()), slog.String("log.routing", currentLogRouting()))
+		} else {
+			logger.Info("OTLP log export recovered")
+		}
+	}
+	return err
+}
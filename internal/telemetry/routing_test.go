@@ -0,0 +1,140 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// stubExporter fails every export while err is set
+type stubExporter struct {
+	err error
+}
+
+func (e *stubExporter) Export(context.Context, []sdklog.Record) error { return e.err }
+func (e *stubExporter) Shutdown(context.Context) error                { return nil }
+func (e *stubExporter) ForceFlush(context.Context) error              { return nil }
+
+var _ = Describe("Log routing", func() {
+	var (
+		processor *recordingProcessor
+		out       *bytes.Buffer
+		ctx       context.Context
+	)
+
+	useRouting := func(routing string) {
+		Expect(SetLogRouting(routing)).To(Succeed())
+		DeferCleanup(SetLogRouting, LogRoutingBoth)
+	}
+
+	BeforeEach(func() {
+		processor, out = useRecordingLogger()
+		ctx = context.Background()
+		otlpExportFailing.Store(false)
+		DeferCleanup(otlpExportFailing.Store, false)
+	})
+
+	It("should reject unknown policies", func() {
+		Expect(SetLogRouting("kafka")).To(MatchError(ContainSubstring("invalid log routing")))
+		Expect(currentLogRouting()).To(Equal(LogRoutingBoth))
+	})
+
+	It("should write to both paths with the same log.record.uid", func() {
+		Logger().WithGroup("request").InfoContext(ctx, "both", slog.String("id", "1"))
+
+		Expect(processor.Records()).To(HaveLen(1))
+		uid := recordAttrs(processor.Records()[0])[LogRecordUIDKey].AsString()
+		Expect(uid).To(HaveLen(32))
+		Expect(out.String()).To(ContainSubstring("[INFO] both request.id=1 log.record.uid=" + uid))
+	})
+
+	It("should write to stdout only", func() {
+		useRouting(LogRoutingStdout)
+
+		Logger().InfoContext(ctx, "stdout")
+
+		Expect(processor.Records()).To(BeEmpty())
+		Expect(out.String()).To(ContainSubstring("[INFO] stdout"))
+		Expect(out.String()).NotTo(ContainSubstring(LogRecordUIDKey))
+	})
+
+	It("should send via OTLP only", func() {
+		useRouting(LogRoutingOTLP)
+
+		Logger().InfoContext(ctx, "otlp")
+
+		Expect(processor.Records()).To(HaveLen(1))
+		Expect(recordAttrs(processor.Records()[0])).NotTo(HaveKey(LogRecordUIDKey))
+		Expect(out.String()).To(BeEmpty())
+	})
+
+	It("should apply a scope's own policy", func() {
+		Expect(SetScopeLogRouting(ScopeAccess, LogRoutingStdout)).To(Succeed())
+		DeferCleanup(SetScopeLogRouting, ScopeAccess, "")
+
+		ScopedLogger(ScopeAccess).InfoContext(ctx, "access")
+		ScopedLogger(ScopeServer).InfoContext(ctx, "server")
+
+		Expect(processor.Records()).To(HaveLen(1))
+		Expect(processor.Records()[0].Body().AsString()).To(Equal("server"))
+		Expect(out.String()).To(ContainSubstring("[INFO] access log.scope=access\n"))
+	})
+
+	It("should load per-scope policies from the environment", func() {
+		os.Setenv("LOG_ROUTING_ACCESS", "otlp")
+		os.Setenv("LOG_ROUTING_METRICS", "syslog")
+		DeferCleanup(os.Unsetenv, "LOG_ROUTING_ACCESS")
+		DeferCleanup(os.Unsetenv, "LOG_ROUTING_METRICS")
+		DeferCleanup(SetScopeLogRouting, ScopeAccess, "")
+
+		Expect(LoadLogRouting()).To(MatchError(ContainSubstring(`LOG_ROUTING_METRICS: scope metrics: invalid log routing "syslog"`)))
+		routing, ok := scopeRouting.Load(ScopeAccess)
+		Expect(ok).To(BeTrue())
+		Expect(routing).To(Equal(LogRoutingOTLP))
+		Expect(currentLogRouting()).To(Equal(LogRoutingBoth))
+	})
+
+	It("should write to stdout when OTLP is disabled, whatever the policy", func() {
+		mu.Lock()
+		useOTLP = false
+		mu.Unlock()
+
+		for _, routing := range []string{LogRoutingOTLP, LogRoutingFallback} {
+			useRouting(routing)
+			Logger().InfoContext(ctx, "no otlp "+routing)
+			Expect(out.String()).To(ContainSubstring("[INFO] no otlp " + routing))
+		}
+	})
+
+	It("should fall back to stdout while OTLP export is failing", func() {
+		useRouting(LogRoutingFallback)
+		stub := &stubExporter{}
+		exporter := healthExporter{stub}
+
+		Logger().InfoContext(ctx, "healthy")
+		Expect(out.String()).To(BeEmpty())
+
+		stub.err = errors.New("connection refused")
+		Expect(exporter.Export(ctx, nil)).To(HaveOccurred())
+		Expect(out.String()).To(ContainSubstring("[WARN] OTLP log export failing error=\"connection refused\" log.routing=fallback"))
+		out.Reset()
+
+		Logger().InfoContext(ctx, "failing")
+		Expect(out.String()).To(ContainSubstring("[INFO] failing log.record.uid="))
+
+		stub.err = nil
+		Expect(exporter.Export(ctx, nil)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("[INFO] OTLP log export recovered"))
+		out.Reset()
+
+		Logger().InfoContext(ctx, "recovered")
+		Expect(out.String()).To(BeEmpty())
+		Expect(processor.Records()).To(HaveLen(3))
+	})
+})
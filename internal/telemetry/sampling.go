@@ -0,0 +1,345 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// samplingBuckets is the number of per-key counters. Keys are hashed into them, so
+// memory stays bounded however many distinct messages are logged; a collision only
+// makes two keys share a budget.
+const samplingBuckets = 4096
+
+// samplingSummaryTop bounds how many messages the periodic summary lists
+const samplingSummaryTop = 10
+
+// samplingSummaryKeys bounds the messages counted between two summaries; records
+// of further messages are counted under samplingOtherKey
+const (
+	samplingSummaryKeys = 1000
+	samplingOtherKey    = "other"
+)
+
+// Attribute keys of the sampling summary record
+const (
+	SamplingSuppressedKey = "log.sampling.suppressed"
+	SamplingMessagesKey   = "log.sampling.messages"
+)
+
+// SamplingConfig configures log sampling. Within every Interval, the first Initial
+// records with the same level and message are kept, then 1 in Thereafter.
+type SamplingConfig struct {
+	// Initial is the number of records kept per key and interval; 0 disables sampling
+	Initial int
+	// Thereafter keeps every Thereafter-th record after the first Initial; 0 drops them all
+	Thereafter int
+	Interval   time.Duration
+	// BypassLevel is the level at and above which records are always kept
+	BypassLevel slog.Level
+	// SummaryInterval is how often the suppressed counts are logged
+	SummaryInterval time.Duration
+}
+
+// DefaultSamplingConfig returns sampling disabled, with the defaults used once
+// LOG_SAMPLING_INITIAL enables it
+func DefaultSamplingConfig() SamplingConfig {
+	return SamplingConfig{
+		Initial:         0,
+		Thereafter:      100,
+		Interval:        time.Second,
+		BypassLevel:     slog.LevelWarn,
+		SummaryInterval: time.Minute,
+	}
+}
+
+// LoadSamplingConfig reads LOG_SAMPLING_INITIAL, LOG_SAMPLING_THEREAFTER,
+// LOG_SAMPLING_INTERVAL, LOG_SAMPLING_BYPASS_LEVEL and LOG_SAMPLING_SUMMARY_INTERVAL
+func LoadSamplingConfig() (SamplingConfig, error) {
+	cfg := DefaultSamplingConfig()
+	var err error
+	if cfg.Initial, err = envInt("LOG_SAMPLING_INITIAL", cfg.Initial); err != nil {
+		return cfg, err
+	}
+	if cfg.Thereafter, err = envInt("LOG_SAMPLING_THEREAFTER", cfg.Thereafter); err != nil {
+		return cfg, err
+	}
+	if cfg.Interval, err = envDuration("LOG_SAMPLING_INTERVAL", cfg.Interval); err != nil {
+		return cfg, err
+	}
+	if cfg.SummaryInterval, err = envDuration("LOG_SAMPLING_SUMMARY_INTERVAL", cfg.SummaryInterval); err != nil {
+		return cfg, err
+	}
+	if value := os.Getenv("LOG_SAMPLING_BYPASS_LEVEL"); value != "" {
+		if cfg.BypassLevel, err = ParseLogLevel(value); err != nil {
+			return cfg, fmt.Errorf("LOG_SAMPLING_BYPASS_LEVEL: %w", err)
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate checks the configuration
+func (c SamplingConfig) Validate() error {
+	var errs []error
+	if c.Initial < 0 {
+		errs = append(errs, fmt.Errorf("initial must not be negative, got %d", c.Initial))
+	}
+	if c.Thereafter < 0 {
+		errs = append(errs, fmt.Errorf("thereafter must not be negative, got %d", c.Thereafter))
+	}
+	if c.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("interval must be positive, got %s", c.Interval))
+	}
+	if c.SummaryInterval <= 0 {
+		errs = append(errs, fmt.Errorf("summary interval must be positive, got %s", c.SummaryInterval))
+	}
+	return errors.Join(errs...)
+}
+
+// samplingCounter counts the records of the keys hashed into it in the current interval
+type samplingCounter struct {
+	resetAt atomic.Int64
+	count   atomic.Uint64
+}
+
+func (c *samplingCounter) inc(now time.Time, interval time.Duration) uint64 {
+	tn := now.UnixNano()
+	resetAt := c.resetAt.Load()
+	if resetAt > tn {
+		return c.count.Add(1)
+	}
+	// Only the goroutine that starts the new interval resets the count, so
+	// concurrent records don't wipe each other's increments
+	if c.resetAt.CompareAndSwap(resetAt, tn+interval.Nanoseconds()) {
+		c.count.Store(1)
+		return 1
+	}
+	return c.count.Add(1)
+}
+
+// sampler holds the counters of one sampling configuration
+type sampler struct {
+	cfg      SamplingConfig
+	counters [samplingBuckets]samplingCounter
+
+	mu      sync.Mutex
+	pending map[string]int64 // suppressed per message (or sampling key) since the last summary, at most samplingSummaryKeys
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+var (
+	activeSampler atomic.Pointer[sampler]
+
+	// suppressedCounts are the cumulative suppressed records per level name
+	suppressedMu     sync.Mutex
+	suppressedCounts = map[string]int64{}
+)
+
+// keep reports whether a record passes the sampler, counting it if not
+func (s *sampler) keep(ctx context.Context, r slog.Record) bool {
+	if r.Level >= s.cfg.BypassLevel {
+		return true
+	}
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	key := r.Message
+	if k, ok := ctx.Value(samplingKeyKey{}).(string); ok {
+		key = k
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(r.Level.String()))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	n := s.counters[h.Sum32()%samplingBuckets].inc(now, s.cfg.Interval)
+
+	initial := uint64(s.cfg.Initial)
+	if n <= initial || (s.cfg.Thereafter > 0 && (n-initial)%uint64(s.cfg.Thereafter) == 0) {
+		return true
+	}
+
+	suppressedMu.Lock()
+	suppressedCounts[levelName(r.Level)]++
+	suppressedMu.Unlock()
+	s.mu.Lock()
+	if _, ok := s.pending[key]; !ok && len(s.pending) >= samplingSummaryKeys {
+		key = samplingOtherKey
+	}
+	s.pending[key]++
+	s.mu.Unlock()
+	return false
+}
+
+// summarize logs the records suppressed since the last summary, if any
+func (s *sampler) summarize() {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = map[string]int64{}
+	s.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	messages := make([]string, 0, len(pending))
+	var total int64
+	for message, n := range pending {
+		messages = append(messages, message)
+		total += n
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if pending[messages[i]] != pending[messages[j]] {
+			return pending[messages[i]] > pending[messages[j]]
+		}
+		return messages[i] < messages[j]
+	})
+	if len(messages) > samplingSummaryTop {
+		messages = messages[:samplingSummaryTop]
+	}
+	top := make([]string, 0, len(messages))
+	for _, message := range messages {
+		top = append(top, strconv.Quote(message)+"="+strconv.FormatInt(pending[message], 10))
+	}
+
+	// The summary itself must not be sampled away, nor dropped by the level of
+	// the telemetry scope: it is handed to the handler without the level check
+	ctx := context.WithValue(context.Background(), samplingBypassKey{}, true)
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "Log records suppressed by sampling", 0)
+	record.AddAttrs(
+		slog.Int64(SamplingSuppressedKey, total),
+		slog.String(SamplingMessagesKey, strings.Join(top, ", ")),
+		slog.Duration("interval", s.cfg.SummaryInterval),
+	)
+	_ = ScopedLogger(ScopeTelemetry).Handler().Handle(ctx, record)
+}
+
+func (s *sampler) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.SummaryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.summarize()
+		case <-s.stop:
+			s.summarize()
+			return
+		}
+	}
+}
+
+// SetLogSampling replaces the sampling configuration. The previous sampler logs
+// its last summary. A zero Initial disables sampling.
+func SetLogSampling(cfg SamplingConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	var next *sampler
+	if cfg.Initial > 0 {
+		next = &sampler{cfg: cfg, pending: map[string]int64{}, stop: make(chan struct{}), done: make(chan struct{})}
+	}
+	swapSampler(next)
+	if next != nil {
+		go next.run()
+	}
+	return nil
+}
+
+// StopLogSampling disables sampling after logging the last summary
+func StopLogSampling() {
+	swapSampler(nil)
+}
+
+func swapSampler(next *sampler) {
+	if prev := activeSampler.Swap(next); prev != nil {
+		close(prev.stop)
+		<-prev.done
+	}
+}
+
+// SuppressedLogCounts returns the number of records dropped by sampling since
+// startup for every level name. The metrics package exports it as log_records_suppressed_total.
+func SuppressedLogCounts() map[string]int64 {
+	suppressedMu.Lock()
+	defer suppressedMu.Unlock()
+	// Every level is reported, so the metric's series exist before anything is suppressed
+	counts := make(map[string]int64, len(LogLevels()))
+	for _, level := range LogLevels() {
+		counts[level] = suppressedCounts[level]
+	}
+	return counts
+}
+
+type (
+	samplingBypassKey struct{}
+	samplingKeyKey    struct{}
+)
+
+// ContextWithSamplingKey makes the sampler count the records logged with the
+// returned context under key instead of their message. Records whose message
+// is unique per call, such as access log lines, are sampled per key instead.
+func ContextWithSamplingKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, samplingKeyKey{}, key)
+}
+
+// samplingHandler drops records the active sampler doesn't keep
+type samplingHandler struct {
+	next slog.Handler
+}
+
+func newSamplingHandler(next slog.Handler) *samplingHandler {
+	return &samplingHandler{next: next}
+}
+
+func (h *samplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if s := activeSampler.Load(); s != nil && ctx.Value(samplingBypassKey{}) == nil && !s.keep(ctx, r) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{next: h.next.WithGroup(name)}
+}
+
+func envInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid integer %q", key, value)
+	}
+	return n, nil
+}
+
+func envDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid duration %q", key, value)
+	}
+	return d, nil
+}
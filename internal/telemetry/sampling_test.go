@@ -0,0 +1,216 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Log sampling", func() {
+	var (
+		processor *recordingProcessor
+		ctx       context.Context
+	)
+
+	useSampling := func(cfg SamplingConfig) {
+		Expect(SetLogSampling(cfg)).To(Succeed())
+		DeferCleanup(StopLogSampling)
+	}
+
+	bodies := func() []string {
+		var out []string
+		for _, r := range processor.Records() {
+			out = append(out, r.Body().AsString())
+		}
+		return out
+	}
+
+	BeforeEach(func() {
+		processor, _ = useRecordingLogger()
+		ctx = context.Background()
+	})
+
+	It("should keep every record while disabled", func() {
+		for i := 0; i < 10; i++ {
+			Logger().InfoContext(ctx, "hot path")
+		}
+		Expect(processor.Records()).To(HaveLen(10))
+	})
+
+	It("should keep the first N per key, then 1 in M", func() {
+		cfg := DefaultSamplingConfig()
+		cfg.Initial, cfg.Thereafter, cfg.Interval = 3, 5, time.Hour
+		useSampling(cfg)
+
+		for i := 0; i < 20; i++ {
+			Logger().InfoContext(ctx, "hot path")
+		}
+		Logger().InfoContext(ctx, "other message")
+
+		// Records 1-3, then 8, 13 and 18
+		Expect(bodies()).To(HaveLen(7))
+		Expect(bodies()).To(ContainElement("other message"))
+	})
+
+	It("should count levels of the same message separately", func() {
+		cfg := DefaultSamplingConfig()
+		cfg.Initial, cfg.Thereafter, cfg.Interval = 1, 0, time.Hour
+		cfg.BypassLevel = slog.LevelError
+		useSampling(cfg)
+
+		for i := 0; i < 3; i++ {
+			Logger().InfoContext(ctx, "same")
+			Logger().WarnContext(ctx, "same")
+		}
+
+		Expect(processor.Records()).To(HaveLen(2))
+	})
+
+	It("should start over every interval", func() {
+		cfg := DefaultSamplingConfig()
+		cfg.Initial, cfg.Thereafter, cfg.Interval = 1, 0, 50*time.Millisecond
+		useSampling(cfg)
+
+		Logger().InfoContext(ctx, "tick")
+		Logger().InfoContext(ctx, "tick")
+		time.Sleep(60 * time.Millisecond)
+		Logger().InfoContext(ctx, "tick")
+
+		Expect(processor.Records()).To(HaveLen(2))
+	})
+
+	It("should count records by their sampling key", func() {
+		cfg := DefaultSamplingConfig()
+		cfg.Initial, cfg.Thereafter, cfg.Interval = 1, 0, time.Hour
+		useSampling(cfg)
+
+		keyed := ContextWithSamplingKey(ctx, "GET / 200")
+		for i := 0; i < 3; i++ {
+			Logger().InfoContext(keyed, fmt.Sprintf("line %d", i))
+		}
+		Logger().InfoContext(ContextWithSamplingKey(ctx, "GET / 500"), "line 3")
+
+		Expect(bodies()).To(Equal([]string{"line 0", "line 3"}))
+	})
+
+	It("should always keep records at or above the bypass level", func() {
+		cfg := DefaultSamplingConfig()
+		cfg.Initial, cfg.Thereafter, cfg.Interval = 1, 0, time.Hour
+		useSampling(cfg)
+
+		for i := 0; i < 5; i++ {
+			Logger().WarnContext(ctx, "warning")
+			Logger().ErrorContext(ctx, "failure")
+		}
+
+		Expect(processor.Records()).To(HaveLen(10))
+	})
+
+	It("should count suppressed records and summarize them", func() {
+		before := SuppressedLogCounts()["info"]
+		cfg := DefaultSamplingConfig()
+		cfg.Initial, cfg.Thereafter, cfg.Interval = 1, 0, time.Hour
+		cfg.SummaryInterval = time.Hour
+		Expect(SetLogSampling(cfg)).To(Succeed())
+
+		for i := 0; i < 4; i++ {
+			Logger().InfoContext(ctx, "Received request")
+		}
+		Logger().InfoContext(ctx, "Request completed")
+		Logger().InfoContext(ctx, "Request completed")
+
+		Expect(SuppressedLogCounts()["info"] - before).To(Equal(int64(4)))
+		Expect(SuppressedLogCounts()).To(HaveKey("debug"))
+
+		// Stopping logs the final summary, bypassing the sampler
+		StopLogSampling()
+		records := processor.Records()
+		summary := records[len(records)-1]
+		Expect(summary.Body().AsString()).To(Equal("Log records suppressed by sampling"))
+		attrs := recordAttrs(summary)
+		Expect(attrs[SamplingSuppressedKey].AsInt64()).To(Equal(int64(4)))
+		Expect(attrs[SamplingMessagesKey].AsString()).To(Equal(`"Received request"=3, "Request completed"=1`))
+		Expect(attrs[LogScopeKey].AsString()).To(Equal(ScopeTelemetry))
+	})
+
+	It("should summarize periodically", func() {
+		cfg := DefaultSamplingConfig()
+		cfg.Initial, cfg.Thereafter, cfg.Interval = 1, 0, time.Hour
+		cfg.SummaryInterval = 20 * time.Millisecond
+		useSampling(cfg)
+
+		Logger().InfoContext(ctx, "noisy")
+		Logger().InfoContext(ctx, "noisy")
+
+		Eventually(bodies).Should(ContainElement("Log records suppressed by sampling"))
+	})
+
+	It("should summarize even when the telemetry scope is above info", func() {
+		_, err := SetLogLevels(ctx, "", map[string]string{ScopeTelemetry: "error"}, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(ResetLogLevels, ctx)
+		cfg := DefaultSamplingConfig()
+		cfg.Initial, cfg.Thereafter, cfg.Interval = 1, 0, time.Hour
+		cfg.SummaryInterval = time.Hour
+		Expect(SetLogSampling(cfg)).To(Succeed())
+
+		Logger().InfoContext(ctx, "noisy")
+		Logger().InfoContext(ctx, "noisy")
+		StopLogSampling()
+
+		Expect(bodies()).To(ContainElement("Log records suppressed by sampling"))
+	})
+
+	It("should count messages beyond the summary's bound as other", func() {
+		cfg := DefaultSamplingConfig()
+		cfg.Initial, cfg.Thereafter, cfg.Interval = 1, 0, time.Hour
+		s := &sampler{cfg: cfg, pending: map[string]int64{}}
+
+		for i := 0; i < 2*samplingSummaryKeys; i++ {
+			for range 3 {
+				s.keep(ctx, slog.NewRecord(time.Now(), slog.LevelInfo, fmt.Sprintf("message %d", i), 0))
+			}
+		}
+
+		Expect(len(s.pending)).To(BeNumerically("<=", samplingSummaryKeys+1))
+		Expect(s.pending[samplingOtherKey]).To(BeNumerically(">=", 2*samplingSummaryKeys))
+	})
+
+	Describe("LoadSamplingConfig", func() {
+		It("should read the environment", func() {
+			for key, value := range map[string]string{
+				"LOG_SAMPLING_INITIAL": "100", "LOG_SAMPLING_THEREAFTER": "10", "LOG_SAMPLING_INTERVAL": "2s",
+				"LOG_SAMPLING_BYPASS_LEVEL": "error", "LOG_SAMPLING_SUMMARY_INTERVAL": "30s",
+			} {
+				os.Setenv(key, value)
+				DeferCleanup(os.Unsetenv, key)
+			}
+
+			cfg, err := LoadSamplingConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg).To(Equal(SamplingConfig{
+				Initial: 100, Thereafter: 10, Interval: 2 * time.Second,
+				BypassLevel: slog.LevelError, SummaryInterval: 30 * time.Second,
+			}))
+		})
+
+		It("should default to sampling disabled", func() {
+			cfg, err := LoadSamplingConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Initial).To(BeZero())
+		})
+
+		It("should reject invalid values", func() {
+			os.Setenv("LOG_SAMPLING_INTERVAL", "0s")
+			DeferCleanup(os.Unsetenv, "LOG_SAMPLING_INTERVAL")
+
+			_, err := LoadSamplingConfig()
+			Expect(err).To(MatchError(ContainSubstring("interval must be positive")))
+		})
+	})
+})
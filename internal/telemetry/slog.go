@@ -0,0 +1,386 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+)
+
+// stdLogger writes the stdout/stderr half of every log record. It is separate from
+// the log package's default logger so that bridging that logger into OTLP can't loop.
+var stdLogger = log.New(os.Stderr, "", log.LstdFlags)
+
+// appHandler samples records (see LOG_SAMPLING_*), redacts the ones it keeps
+// (see REDACTION_CONFIG_FILE) and routes them to stdout/stderr, OTLP or both (see LOG_ROUTING)
+var appHandler slog.Handler = newSamplingHandler(newRedactingHandler(newRoutingHandler(newStdoutHandler(stdLogger), newOTelHandler())))
+
+// appLogger applies the global level; scopedLoggers apply their scope's level
+var (
+	appLogger     = slog.New(newLevelHandler("", appHandler))
+	scopedLoggers = newScopedLoggers()
+)
+
+type loggerKey struct{}
+
+// Logger returns the application's structured logger
+func Logger() *slog.Logger {
+	return appLogger
+}
+
+// ScopedLogger returns the logger of a scope (ScopeServer, ScopeMetrics, ScopeTelemetry).
+// Its records carry the log.scope attribute and are filtered by the scope's level.
+func ScopedLogger(scope string) *slog.Logger {
+	if logger, ok := scopedLoggers[scope]; ok {
+		return logger
+	}
+	return newScopedLogger(scope)
+}
+
+func newScopedLoggers() map[string]*slog.Logger {
+	loggers := make(map[string]*slog.Logger, len(LogScopes()))
+	for _, scope := range LogScopes() {
+		loggers[scope] = newScopedLogger(scope)
+	}
+	return loggers
+}
+
+func newScopedLogger(scope string) *slog.Logger {
+	return slog.New(newLevelHandler(scope, appHandler)).With(slog.String(LogScopeKey, scope))
+}
+
+// ContextWithLogger returns a context carrying the logger, typically a child
+// created with With, so that everything logged down the call chain shares its attributes
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger stored by ContextWithLogger, or Logger()
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok && logger != nil {
+			return logger
+		}
+	}
+	return appLogger
+}
+
+// levelName is the value of the log.level attribute for a slog level
+func levelName(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return "debug"
+	case level < slog.LevelWarn:
+		return "info"
+	case level < slog.LevelError:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// severity maps a slog level to the OTel severity number: DEBUG=5, INFO=9, WARN=13,
+// ERROR=17, with levels in between (e.g. slog.LevelInfo+2) landing on INFO2..INFO4
+func severity(level slog.Level) otellog.Severity {
+	s := int(level) + int(otellog.SeverityInfo)
+	if s < int(otellog.SeverityTrace1) {
+		s = int(otellog.SeverityTrace1)
+	}
+	if s > int(otellog.SeverityFatal4) {
+		s = int(otellog.SeverityFatal4)
+	}
+	return otellog.Severity(s)
+}
+
+// groupOrAttrs is one WithGroup or WithAttrs call on a handler
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// attrState implements the WithAttrs/WithGroup bookkeeping shared by the handlers
+type attrState struct {
+	goas []groupOrAttrs
+}
+
+func (s attrState) withAttrs(attrs []slog.Attr) attrState {
+	if len(attrs) == 0 {
+		return s
+	}
+	return attrState{goas: append(s.goas[:len(s.goas):len(s.goas)], groupOrAttrs{attrs: attrs})}
+}
+
+func (s attrState) withGroup(name string) attrState {
+	if name == "" {
+		return s
+	}
+	return attrState{goas: append(s.goas[:len(s.goas):len(s.goas)], groupOrAttrs{group: name})}
+}
+
+// collect returns the handler's and the record's attributes with LogValuers
+// resolved and open groups turned into nested group attributes
+func (s attrState) collect(r slog.Record) []slog.Attr {
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		recordAttrs = append(recordAttrs, a)
+		return true
+	})
+	goas := append(s.goas[:len(s.goas):len(s.goas)], groupOrAttrs{attrs: recordAttrs})
+	return nest(goas)
+}
+
+func nest(goas []groupOrAttrs) []slog.Attr {
+	var attrs []slog.Attr
+	for i, goa := range goas {
+		if goa.group != "" {
+			if nested := nest(goas[i+1:]); len(nested) > 0 {
+				attrs = append(attrs, slog.Attr{Key: goa.group, Value: slog.GroupValue(nested...)})
+			}
+			return attrs
+		}
+		for _, a := range goa.attrs {
+			if a = resolve(a); !a.Equal(slog.Attr{}) {
+				attrs = append(attrs, a)
+			}
+		}
+	}
+	return attrs
+}
+
+// resolve resolves LogValuers recursively and drops empty groups
+func resolve(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup {
+		return a
+	}
+	var group []slog.Attr
+	for _, ga := range a.Value.Group() {
+		if ga = resolve(ga); !ga.Equal(slog.Attr{}) {
+			group = append(group, ga)
+		}
+	}
+	if len(group) == 0 {
+		return slog.Attr{}
+	}
+	// Groups with an empty key are inlined by the handlers, as slog specifies
+	return slog.Attr{Key: a.Key, Value: slog.GroupValue(group...)}
+}
+
+// otelHandler emits slog records as OTel log records with typed attributes.
+// Records are dropped while OTLP logging is disabled.
+type otelHandler struct {
+	attrState
+}
+
+func newOTelHandler() *otelHandler {
+	return &otelHandler{}
+}
+
+func (h *otelHandler) Enabled(context.Context, slog.Level) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return useOTLP && otlpLogger != nil
+}
+
+func (h *otelHandler) Handle(ctx context.Context, r slog.Record) error {
+	mu.RLock()
+	enabled, logger := useOTLP, otlpLogger
+	mu.RUnlock()
+	if !enabled || logger == nil {
+		return nil
+	}
+
+	var record otellog.Record
+	record.SetTimestamp(r.Time)
+	record.SetObservedTimestamp(time.Now())
+	record.SetSeverity(severity(r.Level))
+	record.SetSeverityText(r.Level.String())
+	record.SetBody(otellog.StringValue(r.Message))
+	record.AddAttributes(
+		otellog.String(LogLevelKey, levelName(r.Level)),
+		otellog.String(LogMessageKey, r.Message),
+	)
+	record.AddAttributes(otelKeyValues(h.collect(r))...)
+	record.AddAttributes(otelKeyValues(recordUIDAttrs(ctx))...)
+
+	// The context carries the active span, which the SDK records as trace and span ID
+	logger.Emit(ctx, record)
+	return nil
+}
+
+func (h *otelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &otelHandler{attrState: h.withAttrs(attrs)}
+}
+
+func (h *otelHandler) WithGroup(name string) slog.Handler {
+	return &otelHandler{attrState: h.withGroup(name)}
+}
+
+func otelKeyValues(attrs []slog.Attr) []otellog.KeyValue {
+	kvs := make([]otellog.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		if a.Key == "" && a.Value.Kind() == slog.KindGroup {
+			kvs = append(kvs, otelKeyValues(a.Value.Group())...)
+			continue
+		}
+		kvs = append(kvs, otellog.KeyValue{Key: a.Key, Value: otelValue(a.Value)})
+	}
+	return kvs
+}
+
+// otelValue converts a resolved slog value, keeping its type
+func otelValue(v slog.Value) otellog.Value {
+	switch v.Kind() {
+	case slog.KindString:
+		return otellog.StringValue(v.String())
+	case slog.KindInt64:
+		return otellog.Int64Value(v.Int64())
+	case slog.KindUint64:
+		if u := v.Uint64(); u <= math.MaxInt64 {
+			return otellog.Int64Value(int64(u))
+		}
+		return otellog.StringValue(strconv.FormatUint(v.Uint64(), 10))
+	case slog.KindFloat64:
+		return otellog.Float64Value(v.Float64())
+	case slog.KindBool:
+		return otellog.BoolValue(v.Bool())
+	case slog.KindDuration:
+		return otellog.StringValue(v.Duration().String())
+	case slog.KindTime:
+		return otellog.StringValue(v.Time().Format(time.RFC3339Nano))
+	case slog.KindGroup:
+		return otellog.MapValue(otelKeyValues(v.Group())...)
+	default:
+		switch a := v.Any().(type) {
+		case error:
+			return otellog.StringValue(a.Error())
+		case []byte:
+			return otellog.BytesValue(a)
+		case fmt.Stringer:
+			return otellog.StringValue(a.String())
+		default:
+			return otellog.StringValue(fmt.Sprintf("%+v", a))
+		}
+	}
+}
+
+// stdoutHandler writes records to stdout/stderr in the format chosen by LOG_FORMAT:
+// "[LEVEL] message key=value" lines by default, or JSON or logfmt carrying trace
+// context and resource fields
+type stdoutHandler struct {
+	attrState
+	out *log.Logger
+}
+
+func newStdoutHandler(out *log.Logger) *stdoutHandler {
+	return &stdoutHandler{out: out}
+}
+
+func (h *stdoutHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *stdoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := append(h.collect(r), recordUIDAttrs(ctx)...)
+	format := currentLogFormat()
+	if format == LogFormatText {
+		h.out.Print(encodeText(r, attrs))
+		return nil
+	}
+
+	var line []byte
+	if format == LogFormatJSON {
+		line = encodeJSON(ctx, r, attrs, logResource())
+	} else {
+		line = encodeLogfmt(ctx, r, attrs, logResource())
+	}
+	// Structured lines carry their own timestamp, so they bypass the logger's prefix
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+	_, err := h.out.Writer().Write(append(line, '\n'))
+	return err
+}
+
+func (h *stdoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &stdoutHandler{attrState: h.withAttrs(attrs), out: h.out}
+}
+
+func (h *stdoutHandler) WithGroup(name string) slog.Handler {
+	return &stdoutHandler{attrState: h.withGroup(name), out: h.out}
+}
+
+// encodeText formats "[LEVEL] message key=value ...", the format the app has always
+// written to stdout/stderr. Groups become dotted keys.
+func encodeText(r slog.Record, attrs []slog.Attr) string {
+	var b strings.Builder
+	b.WriteString("[")
+	b.WriteString(strings.ToUpper(levelName(r.Level)))
+	b.WriteString("] ")
+	b.WriteString(r.Message)
+	writeTextAttrs(&b, "", attrs)
+	return b.String()
+}
+
+func writeTextAttrs(b *strings.Builder, prefix string, attrs []slog.Attr) {
+	for _, a := range attrs {
+		key := a.Key
+		if prefix != "" && key != "" {
+			key = prefix + "." + key
+		} else if key == "" {
+			key = prefix
+		}
+		if a.Value.Kind() == slog.KindGroup {
+			writeTextAttrs(b, key, a.Value.Group())
+			continue
+		}
+		b.WriteString(" ")
+		b.WriteString(key)
+		b.WriteString("=")
+		b.WriteString(textValue(a.Value))
+	}
+}
+
+// textValue formats a value, quoting strings that would be ambiguous unquoted
+func textValue(v slog.Value) string {
+	var s string
+	switch v.Kind() {
+	case slog.KindTime:
+		s = v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			s = err.Error()
+		} else {
+			s = v.String()
+		}
+	default:
+		s = v.String()
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// mapAttrs converts the legacy map attributes of the Log* functions, sorted by key
+func mapAttrs(maps []map[string]string) []slog.Attr {
+	var attrs []slog.Attr
+	for _, m := range maps {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			attrs = append(attrs, slog.String(k, m[k]))
+		}
+	}
+	return attrs
+}
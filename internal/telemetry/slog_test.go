@@ -0,0 +1,211 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// recordingProcessor keeps every emitted record in memory
+type recordingProcessor struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (p *recordingProcessor) Enabled(context.Context, sdklog.EnabledParameters) bool { return true }
+
+func (p *recordingProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records = append(p.records, r.Clone())
+	return nil
+}
+
+func (p *recordingProcessor) Shutdown(context.Context) error   { return nil }
+func (p *recordingProcessor) ForceFlush(context.Context) error { return nil }
+
+func (p *recordingProcessor) Records() []sdklog.Record {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]sdklog.Record(nil), p.records...)
+}
+
+// recordAttrs returns the attributes of a record by key
+func recordAttrs(r sdklog.Record) map[string]otellog.Value {
+	attrs := map[string]otellog.Value{}
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	return attrs
+}
+
+// useRecordingLogger routes OTLP logging to an in-memory processor and stdout/stderr
+// to a buffer for the duration of a test
+func useRecordingLogger() (*recordingProcessor, *bytes.Buffer) {
+	processor := &recordingProcessor{}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(processor))
+	out := &bytes.Buffer{}
+
+	mu.Lock()
+	prevLogger, prevUseOTLP := otlpLogger, useOTLP
+	otlpLogger, useOTLP = provider.Logger("test"), true
+	mu.Unlock()
+	prevOut := stdLogger.Writer()
+	stdLogger.SetOutput(out)
+
+	DeferCleanup(func() {
+		mu.Lock()
+		otlpLogger, useOTLP = prevLogger, prevUseOTLP
+		mu.Unlock()
+		stdLogger.SetOutput(prevOut)
+	})
+	return processor, out
+}
+
+var _ = Describe("Structured logger", func() {
+	var (
+		processor *recordingProcessor
+		out       *bytes.Buffer
+		ctx       context.Context
+	)
+
+	BeforeEach(func() {
+		processor, out = useRecordingLogger()
+		ctx = context.Background()
+	})
+
+	It("should emit typed attributes", func() {
+		Logger().InfoContext(ctx, "Request completed",
+			slog.Int("http.response.status_code", 200),
+			slog.Float64("duration_ms", 1.5),
+			slog.Bool("cached", true),
+			slog.String("url.path", "/"),
+			slog.Duration("elapsed", 2*time.Second),
+			slog.Any("error", errors.New("boom")),
+		)
+
+		records := processor.Records()
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].Body().AsString()).To(Equal("Request completed"))
+
+		attrs := recordAttrs(records[0])
+		Expect(attrs["http.response.status_code"].Kind()).To(Equal(otellog.KindInt64))
+		Expect(attrs["http.response.status_code"].AsInt64()).To(Equal(int64(200)))
+		Expect(attrs["duration_ms"].AsFloat64()).To(Equal(1.5))
+		Expect(attrs["cached"].AsBool()).To(BeTrue())
+		Expect(attrs["url.path"].AsString()).To(Equal("/"))
+		Expect(attrs["elapsed"].AsString()).To(Equal("2s"))
+		Expect(attrs["error"].AsString()).To(Equal("boom"))
+		Expect(attrs[LogLevelKey].AsString()).To(Equal("info"))
+		Expect(attrs[LogMessageKey].AsString()).To(Equal("Request completed"))
+	})
+
+	It("should map slog levels to OTel severities", func() {
+		_, err := SetLogLevels(ctx, "debug", nil, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(ResetLogLevels, context.Background())
+
+		logger := Logger()
+		logger.DebugContext(ctx, "debug")
+		logger.InfoContext(ctx, "info")
+		logger.WarnContext(ctx, "warn")
+		logger.ErrorContext(ctx, "error")
+		logger.Log(ctx, slog.LevelInfo+2, "info3")
+
+		var severities []otellog.Severity
+		for _, r := range processor.Records()[1:] { // the first record is the level change
+			severities = append(severities, r.Severity())
+		}
+		Expect(severities).To(Equal([]otellog.Severity{
+			otellog.SeverityDebug, otellog.SeverityInfo, otellog.SeverityWarn, otellog.SeverityError, otellog.SeverityInfo3,
+		}))
+	})
+
+	It("should nest groups as map values", func() {
+		Logger().WithGroup("request").With(slog.String("id", "abc")).
+			InfoContext(ctx, "grouped", slog.Group("client", slog.String("address", "10.0.0.1")))
+
+		attrs := recordAttrs(processor.Records()[0])
+		Expect(attrs["request"].Kind()).To(Equal(otellog.KindMap))
+		request := map[string]otellog.Value{}
+		for _, kv := range attrs["request"].AsMap() {
+			request[kv.Key] = kv.Value
+		}
+		Expect(request["id"].AsString()).To(Equal("abc"))
+		Expect(request["client"].Kind()).To(Equal(otellog.KindMap))
+
+		Expect(out.String()).To(ContainSubstring("request.id=abc request.client.address=10.0.0.0"))
+	})
+
+	It("should carry child logger attributes through the context", func() {
+		child := Logger().With(slog.String("http.request.method", "GET"))
+		ctx = ContextWithLogger(ctx, child)
+
+		LoggerFromContext(ctx).InfoContext(ctx, "first")
+		LogInfo(ctx, "second")
+
+		for _, r := range processor.Records() {
+			Expect(recordAttrs(r)["http.request.method"].AsString()).To(Equal("GET"))
+		}
+		Expect(processor.Records()).To(HaveLen(2))
+		Expect(LoggerFromContext(context.Background())).To(BeIdenticalTo(Logger()))
+	})
+
+	It("should record the active span", func() {
+		tp := sdktrace.NewTracerProvider()
+		DeferCleanup(tp.Shutdown, context.Background())
+		spanCtx, span := tp.Tracer("test").Start(ctx, "op")
+		Logger().InfoContext(spanCtx, "in span")
+		span.End()
+
+		Expect(processor.Records()[0].TraceID()).To(Equal(span.SpanContext().TraceID()))
+	})
+
+	It("should write the text format to stdout/stderr", func() {
+		Logger().WarnContext(ctx, "disk almost full", slog.Int("percent", 91), slog.String("mount", "/var lib"))
+
+		Expect(out.String()).To(ContainSubstring(`[WARN] disk almost full percent=91 mount="/var lib"`))
+	})
+
+	It("should not emit to OTLP while OTLP logging is disabled", func() {
+		mu.Lock()
+		useOTLP = false
+		mu.Unlock()
+
+		Logger().InfoContext(ctx, "stdout only")
+
+		Expect(processor.Records()).To(BeEmpty())
+		Expect(out.String()).To(ContainSubstring("[INFO] stdout only"))
+	})
+
+	Describe("Log* wrappers", func() {
+		It("should use the attributes of every map", func() {
+			LogInfo(ctx, "merged", map[string]string{"b": "2", "a": "1"}, map[string]string{"c": "3"})
+
+			attrs := recordAttrs(processor.Records()[0])
+			Expect(attrs).To(HaveKey("a"))
+			Expect(attrs).To(HaveKey("b"))
+			Expect(attrs).To(HaveKey("c"))
+			Expect(out.String()).To(ContainSubstring("[INFO] merged a=1 b=2 c=3"))
+		})
+
+		It("should add the exception attributes", func() {
+			LogError(ctx, "failed", errors.New("timeout"))
+
+			r := processor.Records()[0]
+			Expect(r.Severity()).To(Equal(otellog.SeverityError))
+			Expect(recordAttrs(r)[ExceptionMessageKey].AsString()).To(Equal("timeout"))
+			Expect(recordAttrs(r)).NotTo(HaveKey(ErrorKey))
+		})
+	})
+})
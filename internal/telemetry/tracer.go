@@ -45,11 +45,12 @@ func InitializeTracer() error {
 		return fmt.Errorf("failed to create trace exporter: %w", err)
 	}
 
-	// Create tracer provider
+	// Create tracer provider. Span attributes are redacted (client IPs, tokens in
+	// query strings) before they are batched for export.
 	tracerProvider = sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(traceExporter,
-			sdktrace.WithBatchTimeout(5*time.Second),
-		),
+		sdktrace.WithSpanProcessor(newRedactingSpanProcessor(
+			sdktrace.NewBatchSpanProcessor(traceExporter, sdktrace.WithBatchTimeout(5*time.Second)),
+		)),
 		sdktrace.WithResource(res),
 		sdktrace.WithSampler(sdktrace.AlwaysSample()), // For simplicity, sample all traces
 	)
@@ -21,7 +21,7 @@ var _ = Describe("Server Integration", func() {
 
 	BeforeEach(func() {
 		baseURL = "http://localhost:8080"
-		srv = server.New("8080")
+		srv = server.New(server.WithPort("8080"))
 
 		go func() {
 			if err := srv.Start(); err != nil && err != http.ErrServerClosed {